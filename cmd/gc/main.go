@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/config"
+	"github.com/AlexMickh/shop-backend/internal/file_storage"
+	file_repository "github.com/AlexMickh/shop-backend/internal/repository/postgres/file"
+	files_service "github.com/AlexMickh/shop-backend/internal/services/files"
+	"github.com/AlexMickh/shop-backend/pkg/clients/postgresql"
+	"github.com/AlexMickh/shop-backend/pkg/logger"
+)
+
+// gc deletes image files which aren't referenced by products and categories
+func main() {
+	// flags are parsed by config loading
+	minAge := flag.Duration("min-age", 24*time.Hour, "keep files modified later, they can belong to image being uploaded")
+	dryRun := flag.Bool("dry-run", false, "only print files which would be deleted")
+
+	cfg := config.MustLoad()
+
+	log := logger.New(cfg.Env, os.Stdout)
+
+	ctx := logger.ContextWithLogger(context.Background(), log)
+
+	db, err := postgresql.New(
+		ctx,
+		cfg.DB.User,
+		cfg.DB.Password,
+		cfg.DB.Host,
+		cfg.DB.Port,
+		cfg.DB.Name,
+		cfg.DB.MinPools,
+		cfg.DB.MaxPools,
+	)
+	if err != nil {
+		log.Error("failed to init postgres", logger.Err(err))
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	fileStorage, err := file_storage.New(ctx, cfg.Storage, cfg.Static.Addr)
+	if err != nil {
+		log.Error("failed to init file storage", logger.Err(err))
+		os.Exit(1)
+	}
+
+	fileService := files_service.New(file_repository.New(db), fileStorage)
+
+	deleted, err := fileService.CollectGarbage(ctx, *minAge, *dryRun)
+	for _, v := range deleted {
+		log.Info("not used file", slog.String("name", v.Name), slog.Bool("deleted", !*dryRun))
+	}
+	if err != nil {
+		log.Error("failed to collect garbage", logger.Err(err))
+		os.Exit(1)
+	}
+
+	log.Info("garbage collected", slog.Int("files", len(deleted)), slog.Bool("dry_run", *dryRun))
+}
@@ -0,0 +1,31 @@
+CREATE TYPE order_status AS ENUM(
+    'pending_payment',
+    'paid',
+    'shipped',
+    'delivered',
+    'cancelled'
+);
+
+CREATE TABLE IF NOT EXISTS orders(
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    status order_status DEFAULT 'pending_payment' NOT NULL,
+    price INTEGER CHECK (price >= 0) NOT NULL, -- stores kopeck
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS orders_user_id_idx ON orders(user_id);
+
+-- items are a snapshot of the cart at the moment of purchase,
+-- so they must not change when the product is edited or deleted
+CREATE TABLE IF NOT EXISTS order_items(
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    order_id UUID REFERENCES orders(id) ON DELETE CASCADE NOT NULL,
+    product_id UUID REFERENCES products(id) ON DELETE SET NULL,
+    name VARCHAR(100) NOT NULL,
+    price INTEGER CHECK (price > 0) NOT NULL, -- stores kopeck
+    discount INTEGER CHECK (discount >= 0 AND discount < 100) DEFAULT 0,
+    size TEXT,
+    quantity INTEGER CHECK (quantity > 0) NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE orders DROP COLUMN payment_id;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE orders ADD COLUMN payment_id TEXT UNIQUE;
\ No newline at end of file
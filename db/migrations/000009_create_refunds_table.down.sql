@@ -0,0 +1,20 @@
+DROP TABLE IF EXISTS refund_items;
+DROP TABLE IF EXISTS refunds;
+
+ALTER TABLE order_items DROP COLUMN refunded_quantity;
+
+-- postgres can't drop enum values, so refunded orders are moved back to paid
+-- and the type is recreated without refund statuses
+UPDATE orders SET status = 'paid' WHERE status IN ('partially_refunded', 'refunded');
+ALTER TYPE order_status RENAME TO order_status_old;
+CREATE TYPE order_status AS ENUM(
+    'pending_payment',
+    'paid',
+    'shipped',
+    'delivered',
+    'cancelled'
+);
+ALTER TABLE orders ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE orders ALTER COLUMN status TYPE order_status USING status::text::order_status;
+ALTER TABLE orders ALTER COLUMN status SET DEFAULT 'pending_payment';
+DROP TYPE order_status_old;
\ No newline at end of file
@@ -0,0 +1,27 @@
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'partially_refunded';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'refunded';
+
+ALTER TABLE order_items ADD COLUMN refunded_quantity INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE order_items ADD CONSTRAINT order_items_refunded_quantity_check
+    CHECK (refunded_quantity >= 0 AND refunded_quantity <= quantity);
+
+CREATE TABLE IF NOT EXISTS refunds(
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    order_id UUID REFERENCES orders(id) ON DELETE CASCADE NOT NULL,
+    payment_refund_id TEXT UNIQUE, -- refund id in the payment provider, null until provider answers
+    status TEXT DEFAULT 'pending' NOT NULL,
+    price INTEGER CHECK (price > 0) NOT NULL, -- stores kopeck
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS refunds_order_id_idx ON refunds(order_id);
+
+CREATE TABLE IF NOT EXISTS refund_items(
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    refund_id UUID REFERENCES refunds(id) ON DELETE CASCADE NOT NULL,
+    order_item_id UUID REFERENCES order_items(id) ON DELETE CASCADE NOT NULL,
+    quantity INTEGER CHECK (quantity > 0) NOT NULL,
+    price INTEGER CHECK (price >= 0) NOT NULL -- stores kopeck
+);
\ No newline at end of file
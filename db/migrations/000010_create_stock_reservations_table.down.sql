@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS stock_reservations;
+DROP TYPE IF EXISTS reservation_status;
\ No newline at end of file
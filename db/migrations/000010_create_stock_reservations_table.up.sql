@@ -0,0 +1,22 @@
+CREATE TYPE reservation_status AS ENUM(
+    'active',
+    'committed',
+    'released'
+);
+
+-- reserved pieces are already subtracted from products.quantity,
+-- released reservations return them back
+CREATE TABLE IF NOT EXISTS stock_reservations(
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    order_id UUID REFERENCES orders(id) ON DELETE CASCADE NOT NULL,
+    product_id UUID REFERENCES products(id) ON DELETE CASCADE NOT NULL,
+    quantity INTEGER CHECK (quantity > 0) NOT NULL,
+    status reservation_status DEFAULT 'active' NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS stock_reservations_order_id_idx ON stock_reservations(order_id);
+CREATE INDEX IF NOT EXISTS stock_reservations_expires_at_idx ON stock_reservations(expires_at)
+    WHERE status = 'active';
\ No newline at end of file
@@ -0,0 +1,12 @@
+ALTER TABLE products ADD COLUMN quantity INTEGER CHECK (quantity >= 0) DEFAULT 0 NOT NULL;
+ALTER TABLE products ADD COLUMN existing_sizes TEXT[] DEFAULT '{}' NOT NULL;
+
+UPDATE products SET
+    quantity = COALESCE((SELECT SUM(stock) FROM product_variants WHERE product_id = products.id), 0),
+    existing_sizes = COALESCE((SELECT array_agg(size ORDER BY id) FROM product_variants WHERE product_id = products.id), '{}');
+
+ALTER TABLE order_items DROP COLUMN variant_id;
+ALTER TABLE stock_reservations DROP COLUMN variant_id;
+ALTER TABLE carts DROP COLUMN variant_id;
+
+DROP TABLE IF EXISTS product_variants;
\ No newline at end of file
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS product_variants(
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    product_id UUID REFERENCES products(id) ON DELETE CASCADE NOT NULL,
+    size TEXT NOT NULL,
+    stock INTEGER CHECK (stock >= 0) DEFAULT 0 NOT NULL, -- pieces available to buy, reserved ones are not counted
+    sku VARCHAR(64) UNIQUE,
+    barcode VARCHAR(64) UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP,
+    UNIQUE (product_id, size)
+);
+
+-- quantity was not tracked per size, so it's split between sizes evenly
+INSERT INTO product_variants (product_id, size, stock)
+SELECT p.id, s.size, p.quantity / cardinality(p.existing_sizes)
+    + CASE WHEN s.position <= p.quantity % cardinality(p.existing_sizes) THEN 1 ELSE 0 END
+FROM products AS p, unnest(p.existing_sizes) WITH ORDINALITY AS s(size, position)
+ON CONFLICT (product_id, size) DO NOTHING;
+
+ALTER TABLE carts ADD COLUMN variant_id UUID REFERENCES product_variants(id) ON DELETE CASCADE;
+UPDATE carts SET variant_id = (
+    SELECT id FROM product_variants WHERE product_id = carts.product_id ORDER BY id LIMIT 1
+);
+DELETE FROM carts WHERE variant_id IS NULL;
+ALTER TABLE carts ALTER COLUMN variant_id SET NOT NULL;
+
+ALTER TABLE stock_reservations ADD COLUMN variant_id UUID REFERENCES product_variants(id) ON DELETE CASCADE;
+UPDATE stock_reservations SET variant_id = (
+    SELECT id FROM product_variants WHERE product_id = stock_reservations.product_id ORDER BY id LIMIT 1
+);
+DELETE FROM stock_reservations WHERE variant_id IS NULL;
+ALTER TABLE stock_reservations ALTER COLUMN variant_id SET NOT NULL;
+
+ALTER TABLE order_items ADD COLUMN variant_id UUID REFERENCES product_variants(id) ON DELETE SET NULL;
+UPDATE order_items SET variant_id = product_variants.id
+FROM product_variants
+WHERE product_variants.product_id = order_items.product_id AND product_variants.size = order_items.size;
+
+ALTER TABLE products DROP COLUMN quantity;
+ALTER TABLE products DROP COLUMN existing_sizes;
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE carts DROP CONSTRAINT IF EXISTS carts_user_id_variant_id_key;
+
+INSERT INTO carts (user_id, product_id, variant_id)
+SELECT user_id, product_id, variant_id FROM carts, generate_series(2, carts.quantity);
+
+ALTER TABLE carts DROP COLUMN updated_at;
+ALTER TABLE carts DROP COLUMN created_at;
+ALTER TABLE carts DROP COLUMN quantity;
\ No newline at end of file
@@ -0,0 +1,18 @@
+ALTER TABLE carts ADD COLUMN quantity INTEGER CHECK (quantity > 0) DEFAULT 1 NOT NULL;
+ALTER TABLE carts ADD COLUMN created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE carts ADD COLUMN updated_at TIMESTAMP;
+
+-- every click on "add" was a separate row, first row of each size keeps the count
+UPDATE carts SET quantity = duplicates.quantity
+FROM (
+    SELECT DISTINCT ON (user_id, variant_id) id, COUNT(*) OVER (PARTITION BY user_id, variant_id) AS quantity
+    FROM carts
+    ORDER BY user_id, variant_id, id
+) AS duplicates
+WHERE carts.id = duplicates.id;
+
+DELETE FROM carts AS a
+USING carts AS b
+WHERE a.user_id = b.user_id AND a.variant_id = b.variant_id AND a.id > b.id;
+
+ALTER TABLE carts ADD CONSTRAINT carts_user_id_variant_id_key UNIQUE (user_id, variant_id);
\ No newline at end of file
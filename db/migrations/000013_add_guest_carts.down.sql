@@ -0,0 +1,6 @@
+DELETE FROM carts WHERE guest_id IS NOT NULL;
+
+ALTER TABLE carts DROP CONSTRAINT IF EXISTS carts_guest_id_variant_id_key;
+ALTER TABLE carts DROP CONSTRAINT IF EXISTS carts_owner_check;
+ALTER TABLE carts DROP COLUMN guest_id;
+ALTER TABLE carts ALTER COLUMN user_id SET NOT NULL;
\ No newline at end of file
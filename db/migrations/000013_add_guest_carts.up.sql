@@ -0,0 +1,5 @@
+-- guest cart lines have guest_id from signed cookie instead of user_id
+ALTER TABLE carts ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE carts ADD COLUMN guest_id UUID;
+ALTER TABLE carts ADD CONSTRAINT carts_owner_check CHECK ((user_id IS NULL) <> (guest_id IS NULL));
+ALTER TABLE carts ADD CONSTRAINT carts_guest_id_variant_id_key UNIQUE (guest_id, variant_id);
\ No newline at end of file
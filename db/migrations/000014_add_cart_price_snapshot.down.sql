@@ -0,0 +1,2 @@
+ALTER TABLE carts DROP COLUMN discount_snapshot;
+ALTER TABLE carts DROP COLUMN price_snapshot;
\ No newline at end of file
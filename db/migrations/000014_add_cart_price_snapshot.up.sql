@@ -0,0 +1,14 @@
+-- price and discount of product when it was added, cart is compared with them to warn about changes
+ALTER TABLE carts ADD COLUMN price_snapshot INTEGER;
+ALTER TABLE carts ADD COLUMN discount_snapshot INTEGER DEFAULT 0 NOT NULL;
+
+UPDATE carts SET
+    price_snapshot = products.price,
+    discount_snapshot = CASE
+        WHEN products.discount_expires_at IS NULL OR products.discount_expires_at > CURRENT_TIMESTAMP THEN COALESCE(products.discount, 0)
+        ELSE 0
+    END
+FROM products
+WHERE products.id = carts.product_id;
+
+ALTER TABLE carts ALTER COLUMN price_snapshot SET NOT NULL;
\ No newline at end of file
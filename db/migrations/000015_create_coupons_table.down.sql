@@ -0,0 +1,8 @@
+ALTER TABLE order_items DROP COLUMN coupon_discount;
+DROP INDEX IF EXISTS orders_coupon_id_idx;
+ALTER TABLE orders DROP COLUMN coupon_discount;
+ALTER TABLE orders DROP COLUMN coupon_id;
+DROP TABLE IF EXISTS cart_coupons;
+DROP TABLE IF EXISTS coupon_categories;
+DROP TABLE IF EXISTS coupon_products;
+DROP TABLE IF EXISTS coupons;
\ No newline at end of file
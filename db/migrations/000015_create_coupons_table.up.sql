@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS coupons(
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    code VARCHAR(32) UNIQUE NOT NULL, -- stored in upper case
+    type TEXT CHECK (type IN ('percent', 'fixed')) NOT NULL,
+    value INTEGER CHECK (value > 0) NOT NULL, -- percent or kopeck, depends on type
+    min_order_price INTEGER CHECK (min_order_price >= 0) DEFAULT 0 NOT NULL, -- stores kopeck
+    usage_limit INTEGER CHECK (usage_limit > 0), -- null means unlimited
+    usage_limit_per_user INTEGER CHECK (usage_limit_per_user > 0),
+    starts_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP,
+    CONSTRAINT coupons_percent_check CHECK (type <> 'percent' OR value <= 100)
+);
+
+-- coupon without products and categories covers the whole cart
+CREATE TABLE IF NOT EXISTS coupon_products(
+    coupon_id UUID REFERENCES coupons(id) ON DELETE CASCADE NOT NULL,
+    product_id UUID REFERENCES products(id) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (coupon_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS coupon_categories(
+    coupon_id UUID REFERENCES coupons(id) ON DELETE CASCADE NOT NULL,
+    category_id UUID REFERENCES categories(id) ON DELETE CASCADE NOT NULL,
+    PRIMARY KEY (coupon_id, category_id)
+);
+
+-- coupon applied to the cart, cart can have only one
+CREATE TABLE IF NOT EXISTS cart_coupons(
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE UNIQUE,
+    guest_id UUID UNIQUE,
+    coupon_id UUID REFERENCES coupons(id) ON DELETE CASCADE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT cart_coupons_owner_check CHECK ((user_id IS NULL) <> (guest_id IS NULL))
+);
+
+-- usage of coupon is counted by not cancelled orders
+ALTER TABLE orders ADD COLUMN coupon_id UUID REFERENCES coupons(id) ON DELETE SET NULL;
+ALTER TABLE orders ADD COLUMN coupon_discount INTEGER CHECK (coupon_discount >= 0) DEFAULT 0 NOT NULL; -- stores kopeck
+CREATE INDEX IF NOT EXISTS orders_coupon_id_idx ON orders(coupon_id);
+
+-- part of order coupon discount that falls on the item, refunds are reduced by it
+ALTER TABLE order_items ADD COLUMN coupon_discount INTEGER CHECK (coupon_discount >= 0) DEFAULT 0 NOT NULL; -- stores kopeck
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS products_discount_expires_at_idx;
+DROP INDEX IF EXISTS products_discount_starts_at_idx;
+ALTER TABLE products DROP COLUMN discount_starts_at;
\ No newline at end of file
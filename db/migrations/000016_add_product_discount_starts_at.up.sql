@@ -0,0 +1,6 @@
+-- discount is stored in advance and applied only after discount_starts_at,
+-- scheduler clears discount_starts_at when discount starts and discount when it expires
+ALTER TABLE products ADD COLUMN discount_starts_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS products_discount_starts_at_idx ON products(discount_starts_at) WHERE discount_starts_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS products_discount_expires_at_idx ON products(discount_expires_at) WHERE discount_expires_at IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,25 @@
+-- every price product had, new row is written on every price change
+CREATE TABLE IF NOT EXISTS price_history(
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    product_id UUID REFERENCES products(id) ON DELETE CASCADE NOT NULL,
+    price INTEGER CHECK (price > 0) NOT NULL, -- stores kopeck
+    changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS price_history_product_id_changed_at_idx ON price_history(product_id, changed_at);
+
+-- earlier changes weren't recorded, so current price is known only since last update
+INSERT INTO price_history (product_id, price, changed_at)
+SELECT id, price, COALESCE(updated_at, created_at, CURRENT_TIMESTAMP) FROM products;
+
+-- future price changes, scheduler applies them when starts_at comes
+CREATE TABLE IF NOT EXISTS scheduled_prices(
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    product_id UUID REFERENCES products(id) ON DELETE CASCADE NOT NULL,
+    price INTEGER CHECK (price > 0) NOT NULL, -- stores kopeck
+    starts_at TIMESTAMP NOT NULL,
+    applied_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_prices_starts_at_idx ON scheduled_prices(starts_at) WHERE applied_at IS NULL;
\ No newline at end of file
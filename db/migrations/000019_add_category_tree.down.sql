@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS products_category_id_idx;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_category_id_fkey;
+ALTER TABLE products ADD CONSTRAINT products_category_id_fkey
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS categories_parent_id_name_idx;
+ALTER TABLE categories ADD CONSTRAINT categories_name_key UNIQUE (name);
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_slug_key;
+ALTER TABLE categories DROP COLUMN position;
+ALTER TABLE categories DROP COLUMN slug;
+ALTER TABLE categories DROP COLUMN parent_id;
\ No newline at end of file
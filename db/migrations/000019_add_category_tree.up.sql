@@ -0,0 +1,18 @@
+-- categories form a tree, name is unique among children of one parent
+ALTER TABLE categories ADD COLUMN parent_id UUID REFERENCES categories(id) ON DELETE RESTRICT;
+ALTER TABLE categories ADD COLUMN slug VARCHAR(100);
+ALTER TABLE categories ADD COLUMN position INTEGER DEFAULT 0 NOT NULL; -- order among children of one parent
+
+-- existing categories get id as slug, it's unique and valid in url
+UPDATE categories SET slug = id::text;
+
+ALTER TABLE categories ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE categories ADD CONSTRAINT categories_slug_key UNIQUE (slug);
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS categories_parent_id_name_idx ON categories(parent_id, name) NULLS NOT DISTINCT;
+
+-- category with products can't be deleted, products are moved to other category first
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_category_id_fkey;
+ALTER TABLE products ADD CONSTRAINT products_category_id_fkey
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE RESTRICT;
+CREATE INDEX IF NOT EXISTS products_category_id_idx ON products(category_id);
\ No newline at end of file
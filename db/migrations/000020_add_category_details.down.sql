@@ -0,0 +1,3 @@
+ALTER TABLE categories DROP COLUMN updated_at;
+ALTER TABLE categories DROP COLUMN image_url;
+ALTER TABLE categories DROP COLUMN description;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE categories ADD COLUMN description TEXT DEFAULT '' NOT NULL;
+ALTER TABLE categories ADD COLUMN image_url TEXT;
+ALTER TABLE categories ADD COLUMN updated_at TIMESTAMP;
\ No newline at end of file
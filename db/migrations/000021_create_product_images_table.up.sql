@@ -0,0 +1,14 @@
+-- gallery of product, the first image by position is the main one and its url is copied to products.image_url
+CREATE TABLE IF NOT EXISTS product_images(
+    id UUID PRIMARY KEY DEFAULT uuidv7(),
+    product_id UUID REFERENCES products(id) ON DELETE CASCADE NOT NULL,
+    url TEXT NOT NULL,
+    position INTEGER CHECK (position >= 0) DEFAULT 0 NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS product_images_product_id_position_idx ON product_images(product_id, position);
+
+-- image file of existing product is named by product id, so image gets the same id
+INSERT INTO product_images (id, product_id, url)
+SELECT id, id, image_url FROM products WHERE image_url IS NOT NULL AND image_url <> '';
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE products DROP COLUMN thumb_url;
+ALTER TABLE product_images DROP COLUMN thumb_url;
+ALTER TABLE product_images DROP COLUMN medium_url;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- images are saved in several sizes, url is the full one
+ALTER TABLE product_images
+    ADD COLUMN medium_url TEXT,
+    ADD COLUMN thumb_url TEXT;
+
+-- images uploaded before resizing have only one size
+UPDATE product_images SET medium_url = url, thumb_url = url;
+
+ALTER TABLE product_images
+    ALTER COLUMN medium_url SET NOT NULL,
+    ALTER COLUMN thumb_url SET NOT NULL;
+
+-- thumbnail of main image is shown in catalog cards and cart
+ALTER TABLE products ADD COLUMN thumb_url TEXT;
+
+UPDATE products SET thumb_url = image_url;
\ No newline at end of file
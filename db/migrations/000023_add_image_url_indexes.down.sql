@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS categories_image_url_idx;
+DROP INDEX IF EXISTS product_images_thumb_url_idx;
+DROP INDEX IF EXISTS product_images_medium_url_idx;
+DROP INDEX IF EXISTS product_images_url_idx;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- files are named by hash of content and shared by images with the same content,
+-- file is deleted only if no image references it
+CREATE INDEX IF NOT EXISTS product_images_url_idx ON product_images(url);
+CREATE INDEX IF NOT EXISTS product_images_medium_url_idx ON product_images(medium_url);
+CREATE INDEX IF NOT EXISTS product_images_thumb_url_idx ON product_images(thumb_url);
+CREATE INDEX IF NOT EXISTS categories_image_url_idx ON categories(image_url);
\ No newline at end of file
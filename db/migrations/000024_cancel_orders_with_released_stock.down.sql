@@ -0,0 +1 @@
+-- cancelled orders aren't moved back to pending_payment, their stock is already on sale
\ No newline at end of file
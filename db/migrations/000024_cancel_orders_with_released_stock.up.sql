@@ -0,0 +1,5 @@
+-- reservations released by ttl used to leave their orders waiting for payment
+UPDATE orders SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP
+WHERE status = 'pending_payment' AND EXISTS (
+    SELECT 1 FROM stock_reservations WHERE order_id = orders.id AND status = 'released'
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS guests;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- guest cart is kept while visitor comes back, visits are saved here because
+-- cart lines are changed not on every visit
+CREATE TABLE IF NOT EXISTS guests(
+    id UUID PRIMARY KEY,
+    seen_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS guests_seen_at_idx ON guests(seen_at);
+
+INSERT INTO guests (id, seen_at)
+SELECT guest_id, COALESCE(MAX(COALESCE(updated_at, created_at)), CURRENT_TIMESTAMP) FROM carts
+WHERE guest_id IS NOT NULL
+GROUP BY guest_id;
+
+INSERT INTO guests (id, seen_at)
+SELECT guest_id, COALESCE(MAX(created_at), CURRENT_TIMESTAMP) FROM cart_coupons
+WHERE guest_id IS NOT NULL
+GROUP BY guest_id
+ON CONFLICT (id) DO NOTHING;
\ No newline at end of file
@@ -0,0 +1 @@
+-- discounted prices can't be told apart from list prices in history, so they stay
\ No newline at end of file
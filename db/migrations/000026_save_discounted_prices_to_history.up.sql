@@ -0,0 +1,7 @@
+-- price history keeps prices with discounts now, discounts which are active already are written
+-- as started at migration time, their real start wasn't recorded
+INSERT INTO price_history (product_id, price, changed_at)
+SELECT id, GREATEST((price::bigint * (100 - discount) + 50) / 100, 1), CURRENT_TIMESTAMP FROM products
+WHERE COALESCE(discount, 0) > 0
+  AND discount_starts_at IS NULL
+  AND (discount_expires_at IS NULL OR discount_expires_at > CURRENT_TIMESTAMP);
\ No newline at end of file
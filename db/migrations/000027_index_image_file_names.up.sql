@@ -0,0 +1,11 @@
+-- images in use are found by file name, it's hash of content, so server address in urls doesn't matter.
+-- Indexes are on the same expression as in queries, otherwise they aren't used.
+DROP INDEX IF EXISTS product_images_url_idx;
+DROP INDEX IF EXISTS product_images_medium_url_idx;
+DROP INDEX IF EXISTS product_images_thumb_url_idx;
+DROP INDEX IF EXISTS categories_image_url_idx;
+
+CREATE INDEX IF NOT EXISTS product_images_url_name_idx ON product_images(regexp_replace(url, '^.*/', ''));
+CREATE INDEX IF NOT EXISTS product_images_medium_url_name_idx ON product_images(regexp_replace(medium_url, '^.*/', ''));
+CREATE INDEX IF NOT EXISTS product_images_thumb_url_name_idx ON product_images(regexp_replace(thumb_url, '^.*/', ''));
+CREATE INDEX IF NOT EXISTS categories_image_url_name_idx ON categories(regexp_replace(image_url, '^.*/', ''));
\ No newline at end of file
@@ -6,27 +6,39 @@ import (
 	"os"
 
 	"github.com/AlexMickh/shop-backend/internal/config"
-	file_storage "github.com/AlexMickh/shop-backend/internal/file_storage/fs"
+	"github.com/AlexMickh/shop-backend/internal/file_storage"
+	"github.com/AlexMickh/shop-backend/internal/lib/images"
+	"github.com/AlexMickh/shop-backend/internal/lib/payment"
 	"github.com/AlexMickh/shop-backend/internal/models"
 	session_repository "github.com/AlexMickh/shop-backend/internal/repository/inmemory/session"
 	cart_repository "github.com/AlexMickh/shop-backend/internal/repository/postgres/cart"
 	category_repository "github.com/AlexMickh/shop-backend/internal/repository/postgres/category"
+	coupon_repository "github.com/AlexMickh/shop-backend/internal/repository/postgres/coupon"
+	file_repository "github.com/AlexMickh/shop-backend/internal/repository/postgres/file"
+	order_repository "github.com/AlexMickh/shop-backend/internal/repository/postgres/order"
 	product_repository "github.com/AlexMickh/shop-backend/internal/repository/postgres/product"
 	token_repository "github.com/AlexMickh/shop-backend/internal/repository/postgres/token"
 	user_repository "github.com/AlexMickh/shop-backend/internal/repository/postgres/user"
 	"github.com/AlexMickh/shop-backend/internal/server"
+	"github.com/AlexMickh/shop-backend/internal/server/middlewares"
 	"github.com/AlexMickh/shop-backend/internal/server/routers"
 	admin_router "github.com/AlexMickh/shop-backend/internal/server/routers/admin"
 	auth_router "github.com/AlexMickh/shop-backend/internal/server/routers/auth"
 	cart_router "github.com/AlexMickh/shop-backend/internal/server/routers/cart"
 	category_router "github.com/AlexMickh/shop-backend/internal/server/routers/category"
+	order_router "github.com/AlexMickh/shop-backend/internal/server/routers/order"
+	payment_router "github.com/AlexMickh/shop-backend/internal/server/routers/payment"
 	product_router "github.com/AlexMickh/shop-backend/internal/server/routers/product"
 	user_router "github.com/AlexMickh/shop-backend/internal/server/routers/user"
 	auth_service "github.com/AlexMickh/shop-backend/internal/services/auth"
 	cart_service "github.com/AlexMickh/shop-backend/internal/services/cart"
 	category_service "github.com/AlexMickh/shop-backend/internal/services/category"
+	coupon_service "github.com/AlexMickh/shop-backend/internal/services/coupon"
+	files_service "github.com/AlexMickh/shop-backend/internal/services/files"
+	order_service "github.com/AlexMickh/shop-backend/internal/services/order"
 	product_service "github.com/AlexMickh/shop-backend/internal/services/product"
 	session_service "github.com/AlexMickh/shop-backend/internal/services/session"
+	suggest_service "github.com/AlexMickh/shop-backend/internal/services/suggest"
 	token_service "github.com/AlexMickh/shop-backend/internal/services/token"
 	user_service "github.com/AlexMickh/shop-backend/internal/services/user"
 	"github.com/AlexMickh/shop-backend/pkg/cash"
@@ -53,8 +65,8 @@ func New(ctx context.Context, cfg *config.Config) *App {
 	sessionCash := cash.New[string, models.Session](ctx, cfg.Jwt.RefreshTokenTtl)
 	sessionRepository := session_repository.New(sessionCash)
 
-	log.Info("initing file storage")
-	fileStorage, err := file_storage.New("./public", cfg.Server.FileServerAddr)
+	log.Info("initing file storage", slog.String("backend", cfg.Storage.Backend))
+	fileStorage, err := file_storage.New(ctx, cfg.Storage, cfg.Static.Addr)
 	if err != nil {
 		log.Error("failed to init file storage", logger.Err(err))
 		os.Exit(1)
@@ -81,6 +93,9 @@ func New(ctx context.Context, cfg *config.Config) *App {
 	categoryRepository := category_repository.New(db)
 	productRepository := product_repository.New(db)
 	cartRepository := cart_repository.New(db)
+	orderRepository := order_repository.New(db)
+	couponRepository := coupon_repository.New(db)
+	fileRepository := file_repository.New(db)
 
 	log.Info("initing service layer")
 
@@ -90,9 +105,83 @@ func New(ctx context.Context, cfg *config.Config) *App {
 	userService := user_service.New(userRepository, tokenService)
 	jwtManager := jwt.New(cfg.Jwt.Secret, cfg.Jwt.AccessTokenTtl)
 	sessionService := session_service.New(sessionRepository, jwtManager, cfg.Jwt.RefreshTokenTtl, validator)
-	categoryService := category_service.New(categoryRepository, validator)
-	productService := product_service.New(productRepository, fileStorage, validator)
-	cartService := cart_service.New(cartRepository)
+	suggestService := suggest_service.New(
+		productRepository,
+		categoryRepository,
+		validator,
+		cfg.Suggest.Limit,
+		cfg.Suggest.Budget,
+	)
+	imageProcessor := images.New(cfg.Image)
+	fileService := files_service.New(fileRepository, fileStorage)
+	categoryService := category_service.New(
+		categoryRepository,
+		fileStorage,
+		imageProcessor,
+		fileService,
+		suggestService,
+		validator,
+	)
+	productService := product_service.New(
+		productRepository,
+		fileStorage,
+		imageProcessor,
+		fileService,
+		suggestService,
+		validator,
+		cfg.Stock.ReservationTtl,
+		cfg.Catalog.PageSize,
+		cfg.Catalog.MaxPageSize,
+	)
+
+	log.Info("building suggestions index")
+	if err := suggestService.Load(ctx); err != nil {
+		log.Error("failed to build suggestions index", logger.Err(err))
+		os.Exit(1)
+	}
+
+	log.Info("initing payment provider", slog.String("provider", cfg.Payment.Provider))
+	paymentProvider, paymentNotifications, err := payment.New(ctx, cfg.Payment)
+	if err != nil {
+		log.Error("failed to init payment provider", logger.Err(err))
+		os.Exit(1)
+	}
+
+	orderService := order_service.New(
+		orderRepository,
+		productService,
+		paymentProvider,
+		validator,
+		cfg.Payment.RefundRetryInterval,
+	)
+	couponService := coupon_service.New(couponRepository, validator)
+	cartService := cart_service.New(
+		cartRepository,
+		userService,
+		orderService,
+		paymentProvider,
+		couponService,
+		validator,
+		cfg.Cart.GuestTtl,
+	)
+
+	log.Info("initing scheduler")
+	scheduler := newScheduler()
+	// cancels orders that weren't paid in time and returns their stock
+	scheduler.add("cancel expired orders", cfg.Stock.ReleaseInterval, orderService.CancelExpiredOrders)
+	// sends again refunds which provider hasn't answered
+	scheduler.add("retry refunds", cfg.Payment.RefundRetryInterval, orderService.RetryRefunds)
+	// deletes carts of visitors who haven't logged in and haven't come back
+	scheduler.add("delete old guest carts", cfg.Cart.GuestCleanupInterval, cartService.DeleteOldGuestCarts)
+	scheduler.add("expire discounts", cfg.Discount.ScheduleInterval, productService.ExpireDiscounts)
+	scheduler.add("start discounts", cfg.Discount.ScheduleInterval, productService.StartDiscounts)
+	scheduler.add("apply scheduled prices", cfg.Price.ScheduleInterval, productService.ApplyScheduledPrices)
+	scheduler.add("update suggestions popularity", cfg.Suggest.PopularityInterval, suggestService.UpdatePopularity)
+	scheduler.start(ctx)
+
+	if paymentNotifications != nil {
+		go processPayments(ctx, orderService, paymentNotifications)
+	}
 
 	emailQueue, err := email.New(ctx, email.EmailConfig{
 		Host:     cfg.Mail.Host,
@@ -105,26 +194,52 @@ func New(ctx context.Context, cfg *config.Config) *App {
 		os.Exit(1)
 	}
 
-	authService := auth_service.New(userService, tokenService, emailQueue, sessionService, validator)
+	authService := auth_service.New(userService, tokenService, emailQueue, sessionService, cartService, validator)
 
 	log.Info("init server")
 
-	authRouter := auth_router.New(authService, sessionService, cfg.Jwt.RefreshTokenTtl)
+	authRouter := auth_router.New(authService, sessionService, cfg.Jwt.RefreshTokenTtl, cfg.Cart.GuestSecret)
 	userRouter := user_router.New(userService)
 	categoryRouter := category_router.New(categoryService)
-	productRouter := product_router.New(productService)
-	cartRouter := cart_router.New(cartService, sessionService)
+	productRouter := product_router.New(productService, suggestService)
+	cartRouter := cart_router.New(cartService, sessionService, cfg.Cart.GuestSecret, cfg.Cart.GuestTtl)
+	orderRouter := order_router.New(orderService, sessionService)
+	yookassaNetworks, err := middlewares.ParseNetworks(cfg.Payment.Yookassa.AllowedNetworks)
+	if err != nil {
+		log.Error("failed to parse yookassa networks", logger.Err(err))
+		os.Exit(1)
+	}
+	paymentRouter := payment_router.New(orderService, yookassaNetworks, cfg.Payment.Yookassa.TrustProxy)
 	adminRouter := admin_router.New(
 		cfg.Server.AdminLogin,
 		cfg.Server.AdminPassword,
 		categoryService,
 		productService,
+		orderService,
+		couponService,
 	)
 
+	// files of s3 backend are served by s3 itself
+	filesPath := ""
+	if cfg.Storage.Backend == file_storage.BackendFs {
+		filesPath = cfg.Storage.Path
+	}
+
 	server, err := server.New(
 		ctx,
 		cfg.Server,
-		[]routers.Router{authRouter, userRouter, categoryRouter, productRouter, cartRouter, adminRouter},
+		cfg.Static,
+		filesPath,
+		[]routers.Router{
+			authRouter,
+			userRouter,
+			categoryRouter,
+			productRouter,
+			cartRouter,
+			orderRouter,
+			paymentRouter,
+			adminRouter,
+		},
 	)
 	if err != nil {
 		log.Error("failed to init server", logger.Err(err))
@@ -138,6 +253,24 @@ func New(ctx context.Context, cfg *config.Config) *App {
 	}
 }
 
+// processPayments does the same as payment webhook for providers without webhooks
+func processPayments(ctx context.Context, orderService *order_service.OrderService, paymentIds <-chan string) {
+	const op = "app.processPayments"
+
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-paymentIds:
+			if err := orderService.ProcessPayment(ctx, id); err != nil {
+				log.Error("failed to process payment", slog.String("payment_id", id), logger.Err(err))
+			}
+		}
+	}
+}
+
 func (a *App) Run(ctx context.Context) {
 	const op = "app.Run"
 
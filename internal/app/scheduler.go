@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/pkg/logger"
+)
+
+// job is a periodic task, it returns how many rows were affected
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) (int64, error)
+}
+
+// scheduler runs every job in its own goroutine until ctx is done
+type scheduler struct {
+	jobs []job
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{}
+}
+
+func (s *scheduler) add(name string, interval time.Duration, run func(ctx context.Context) (int64, error)) {
+	s.jobs = append(s.jobs, job{
+		name:     name,
+		interval: interval,
+		run:      run,
+	})
+}
+
+func (s *scheduler) start(ctx context.Context) {
+	for _, j := range s.jobs {
+		go s.loop(ctx, j)
+	}
+}
+
+func (s *scheduler) loop(ctx context.Context, j job) {
+	const op = "app.scheduler.loop"
+
+	log := logger.FromCtx(ctx).With(slog.String("op", op), slog.String("job", j.name))
+
+	t := time.NewTicker(j.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		count, err := j.run(ctx)
+		if err != nil {
+			log.Error("job failed", logger.Err(err))
+			continue
+		}
+
+		if count != 0 {
+			log.Info("job done", slog.Int64("count", count))
+		}
+	}
+}
@@ -10,21 +10,30 @@ import (
 )
 
 type Config struct {
-	Env    string `env:"ENV" env-default:"prod"`
-	Server ServerConfig
-	DB     DBConfig
-	Jwt    JwtConfig
-	Tokens TokensConfig
-	Mail   MailConfig
+	Env      string `env:"ENV" env-default:"prod"`
+	Server   ServerConfig
+	DB       DBConfig
+	Jwt      JwtConfig
+	Tokens   TokensConfig
+	Mail     MailConfig
+	Payment  PaymentConfig
+	Stock    StockConfig
+	Cart     CartConfig
+	Discount DiscountConfig
+	Price    PriceConfig
+	Catalog  CatalogConfig
+	Suggest  SuggestConfig
+	Image    ImageConfig
+	Storage  StorageConfig
+	Static   StaticConfig
 }
 
 type ServerConfig struct {
-	Addr           string        `env:"SERVER_ADDR" env-default:"0.0.0.0:50070"`
-	FileServerAddr string        `env:"FILESERVER_ADDR" env-default:"0.0.0.0:50071"`
-	Timeout        time.Duration `env:"SERVER_TIMEOUT" env-default:"4s"`
-	IdleTimeout    time.Duration `env:"SERVER_IDLE_TIMEOUT" env-default:"60s"`
-	AdminLogin     string        `env:"ADMIN_LOGIN" env-required:"true"`
-	AdminPassword  string        `env:"ADMIN_PASSWORD" env-required:"true"`
+	Addr          string        `env:"SERVER_ADDR" env-default:"0.0.0.0:50070"`
+	Timeout       time.Duration `env:"SERVER_TIMEOUT" env-default:"4s"`
+	IdleTimeout   time.Duration `env:"SERVER_IDLE_TIMEOUT" env-default:"60s"`
+	AdminLogin    string        `env:"ADMIN_LOGIN" env-required:"true"`
+	AdminPassword string        `env:"ADMIN_PASSWORD" env-required:"true"`
 }
 
 type DBConfig struct {
@@ -54,6 +63,125 @@ type MailConfig struct {
 	Password string `env:"MAIL_PASSWORD" yaml:"password" env-required:"true"`
 }
 
+type StockConfig struct {
+	// how long stock is held for not paid order
+	ReservationTtl time.Duration `env:"STOCK_RESERVATION_TTL" env-default:"15m"`
+	// how often orders with expired reservations are cancelled
+	ReleaseInterval time.Duration `env:"STOCK_RELEASE_INTERVAL" env-default:"1m"`
+}
+
+type CartConfig struct {
+	// signs guest cart cookie
+	GuestSecret string `env:"CART_GUEST_SECRET" env-required:"true"`
+	// guest cart is deleted if visitor doesn't come back for this time
+	GuestTtl time.Duration `env:"CART_GUEST_TTL" env-default:"720h"`
+	// how often old guest carts are deleted
+	GuestCleanupInterval time.Duration `env:"CART_GUEST_CLEANUP_INTERVAL" env-default:"1h"`
+}
+
+type DiscountConfig struct {
+	// how often expired discounts are cleared and scheduled ones are started
+	ScheduleInterval time.Duration `env:"DISCOUNT_SCHEDULE_INTERVAL" env-default:"1m"`
+}
+
+type PriceConfig struct {
+	// how often scheduled price changes are applied
+	ScheduleInterval time.Duration `env:"PRICE_SCHEDULE_INTERVAL" env-default:"1m"`
+}
+
+type CatalogConfig struct {
+	// products on page if client doesn't set limit
+	PageSize int `env:"CATALOG_PAGE_SIZE" env-default:"10"`
+	// greater limit is cut to it
+	MaxPageSize int `env:"CATALOG_MAX_PAGE_SIZE" env-default:"100"`
+}
+
+type SuggestConfig struct {
+	// max products and max categories in suggestions
+	Limit int `env:"SUGGEST_LIMIT" env-default:"5"`
+	// search is stopped after this time and already found names are returned
+	Budget time.Duration `env:"SUGGEST_BUDGET" env-default:"20ms"`
+	// how often popularity of products is taken from sales
+	PopularityInterval time.Duration `env:"SUGGEST_POPULARITY_INTERVAL" env-default:"10m"`
+}
+
+type ImageConfig struct {
+	// uploaded file size limit in bytes
+	MaxBytes  int `env:"IMAGE_MAX_BYTES" env-default:"10485760"`
+	MaxWidth  int `env:"IMAGE_MAX_WIDTH" env-default:"8000"`
+	MaxHeight int `env:"IMAGE_MAX_HEIGHT" env-default:"8000"`
+	// width * height limit, decoded image takes 4 bytes per pixel
+	MaxPixels int `env:"IMAGE_MAX_PIXELS" env-default:"40000000"`
+	// jpeg quality of saved sizes, from 1 to 100
+	Quality int `env:"IMAGE_QUALITY" env-default:"85"`
+	// max side in pixels of every size, thumb is shown in catalog cards
+	ThumbSize  int `env:"IMAGE_THUMB_SIZE" env-default:"300"`
+	MediumSize int `env:"IMAGE_MEDIUM_SIZE" env-default:"800"`
+	FullSize   int `env:"IMAGE_FULL_SIZE" env-default:"1600"`
+}
+
+type StorageConfig struct {
+	// fs or s3
+	Backend string `env:"STORAGE_BACKEND" env-default:"fs"`
+	// directory served by file server, used only by fs backend
+	Path string `env:"STORAGE_PATH" env-default:"./public"`
+	S3   S3Config
+}
+
+// StaticConfig is config of file server, it's started only with fs storage backend
+type StaticConfig struct {
+	Addr        string        `env:"FILESERVER_ADDR" env-default:"0.0.0.0:50071"`
+	ReadTimeout time.Duration `env:"FILESERVER_READ_TIMEOUT" env-default:"5s"`
+	// big images are sent slowly to mobile clients, so it's longer than api timeout
+	WriteTimeout time.Duration `env:"FILESERVER_WRITE_TIMEOUT" env-default:"60s"`
+	IdleTimeout  time.Duration `env:"FILESERVER_IDLE_TIMEOUT" env-default:"120s"`
+	// cache time of files which names aren't content hashes, they're revalidated by etag after it
+	MaxAge time.Duration `env:"FILESERVER_MAX_AGE" env-default:"1h"`
+	// files of text types are compressed if their size is in these bounds
+	CompressMinSize int64 `env:"FILESERVER_COMPRESS_MIN_SIZE" env-default:"1024"`
+	CompressMaxSize int64 `env:"FILESERVER_COMPRESS_MAX_SIZE" env-default:"1048576"`
+}
+
+// S3Config is checked only if s3 backend is selected
+type S3Config struct {
+	Endpoint  string `env:"S3_ENDPOINT" env-default:"localhost:9000"`
+	AccessKey string `env:"S3_ACCESS_KEY"`
+	SecretKey string `env:"S3_SECRET_KEY"`
+	Bucket    string `env:"S3_BUCKET" env-default:"shop"`
+	Region    string `env:"S3_REGION" env-default:"us-east-1"`
+	UseSSL    bool   `env:"S3_USE_SSL" env-default:"false"`
+	// urls are saved in database, so they aren't presigned and bucket must allow anonymous read.
+	// Set it to cdn address, by default it's endpoint/bucket
+	PublicUrl string `env:"S3_PUBLIC_URL"`
+}
+
+type PaymentConfig struct {
+	// yookassa or sandbox
+	Provider  string `env:"PAYMENT_PROVIDER" env-default:"yookassa"`
+	ReturnUrl string `env:"PAYMENT_RETURN_URL" env-required:"true"`
+	// refunds not answered by provider are sent again after this time
+	RefundRetryInterval time.Duration `env:"PAYMENT_REFUND_RETRY_INTERVAL" env-default:"5m"`
+	Yookassa            YookassaConfig
+	Sandbox             SandboxConfig
+}
+
+// YookassaConfig is checked only if yookassa is selected
+type YookassaConfig struct {
+	ShopId    string `env:"YOOKASSA_SHOP_ID"`
+	SecretKey string `env:"YOOKASSA_SECRET_KEY"`
+	ApiUrl    string `env:"YOOKASSA_API_URL" env-default:"https://api.yookassa.ru/v3/"`
+	// networks yookassa sends notifications from, see https://yookassa.ru/developers/using-api/webhooks
+	AllowedNetworks []string `env:"YOOKASSA_ALLOWED_NETWORKS" env-default:"185.71.76.0/27,185.71.77.0/27,77.75.153.0/25,77.75.156.11,77.75.156.35,77.75.154.128/25,2a02:5180::/32"`
+	// take client ip from X-Real-IP, enable only behind nginx
+	TrustProxy bool `env:"YOOKASSA_TRUST_PROXY" env-default:"false"`
+}
+
+type SandboxConfig struct {
+	// payments with this price in kopecks or higher are declined
+	DeclineFrom  int64         `env:"PAYMENT_SANDBOX_DECLINE_FROM" env-default:"10000000"`
+	ConfirmDelay time.Duration `env:"PAYMENT_SANDBOX_CONFIRM_DELAY" env-default:"1s"`
+}
+
 func MustLoad() *Config {
 	path := fetchPath()
 	cfg, err := Load(path)
@@ -1,8 +1,12 @@
 package dtos
 
+import "github.com/AlexMickh/shop-backend/internal/models"
+
 type AddToCartRequest struct {
-	UserId    string `validate:"required,uuid"`
+	Owner     models.CartOwner
 	ProductId string `path:"product_id" validate:"required,uuid"`
+	Size      string `validate:"required,oneof=xs s m l xl 52 54"`
+	Quantity  int    `validate:"gt=0"`
 }
 
 type AddToCartResponse struct {
@@ -0,0 +1,8 @@
+package dtos
+
+import "github.com/AlexMickh/shop-backend/internal/models"
+
+type ApplyCouponRequest struct {
+	Owner models.CartOwner `json:"-"`
+	Code  string           `json:"code" validate:"required,max=32"`
+}
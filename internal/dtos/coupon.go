@@ -0,0 +1,93 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+)
+
+// CreateCouponRequest has Value in percent for percent coupons and in kopecks for fixed ones
+type CreateCouponRequest struct {
+	Code              string     `json:"code" validate:"required,alphanum,min=3,max=32"`
+	Type              string     `json:"type" validate:"required,oneof=percent fixed"`
+	Value             int        `json:"value" validate:"required,gt=0,max=10000000"`
+	MinOrderPrice     int        `json:"min_order_price" validate:"gte=0"`
+	ProductIDs        []string   `json:"product_ids" validate:"unique,dive,uuid"`
+	CategoryIDs       []string   `json:"category_ids" validate:"unique,dive,uuid"`
+	UsageLimit        *int       `json:"usage_limit" validate:"omitempty,gt=0"`
+	UsageLimitPerUser *int       `json:"usage_limit_per_user" validate:"omitempty,gt=0"`
+	StartsAt          *time.Time `json:"starts_at"`
+	ExpiresAt         *time.Time `json:"expires_at"`
+}
+
+// UpdateCouponRequest changes only not nil fields, limits and dates can't be reset to null
+type UpdateCouponRequest struct {
+	ID                string     `validate:"required,uuid"`
+	Code              *string    `json:"code" validate:"omitempty,alphanum,min=3,max=32"`
+	Type              *string    `json:"type" validate:"omitempty,oneof=percent fixed"`
+	Value             *int       `json:"value" validate:"omitempty,gt=0,max=10000000"`
+	MinOrderPrice     *int       `json:"min_order_price" validate:"omitempty,gte=0"`
+	ProductIDs        *[]string  `json:"product_ids" validate:"omitempty,unique,dive,uuid"`
+	CategoryIDs       *[]string  `json:"category_ids" validate:"omitempty,unique,dive,uuid"`
+	UsageLimit        *int       `json:"usage_limit" validate:"omitempty,gt=0"`
+	UsageLimitPerUser *int       `json:"usage_limit_per_user" validate:"omitempty,gt=0"`
+	StartsAt          *time.Time `json:"starts_at"`
+	ExpiresAt         *time.Time `json:"expires_at"`
+}
+
+type Coupon struct {
+	ID                string      `json:"id"`
+	Code              string      `json:"code"`
+	Type              string      `json:"type"`
+	Value             int         `json:"value"`
+	MinOrderPrice     money.Money `json:"min_order_price"`
+	ProductIDs        []string    `json:"product_ids"`
+	CategoryIDs       []string    `json:"category_ids"`
+	UsageLimit        *int        `json:"usage_limit,omitempty"`
+	UsageLimitPerUser *int        `json:"usage_limit_per_user,omitempty"`
+	StartsAt          *time.Time  `json:"starts_at,omitempty"`
+	ExpiresAt         *time.Time  `json:"expires_at,omitempty"`
+	CreatedAt         time.Time   `json:"created_at"`
+}
+
+type GetCouponsResponse struct {
+	Coupons []Coupon `json:"coupons"`
+}
+
+func ToCoupon(coupon models.Coupon) Coupon {
+	resp := Coupon{
+		ID:                coupon.ID.String(),
+		Code:              coupon.Code,
+		Type:              string(coupon.Type),
+		Value:             coupon.Value,
+		MinOrderPrice:     coupon.MinOrderPrice,
+		ProductIDs:        make([]string, 0, len(coupon.ProductIDs)),
+		CategoryIDs:       make([]string, 0, len(coupon.CategoryIDs)),
+		UsageLimit:        coupon.UsageLimit,
+		UsageLimitPerUser: coupon.UsageLimitPerUser,
+		StartsAt:          coupon.StartsAt,
+		ExpiresAt:         coupon.ExpiresAt,
+		CreatedAt:         coupon.CreatedAt,
+	}
+
+	for _, v := range coupon.ProductIDs {
+		resp.ProductIDs = append(resp.ProductIDs, v.String())
+	}
+	for _, v := range coupon.CategoryIDs {
+		resp.CategoryIDs = append(resp.CategoryIDs, v.String())
+	}
+
+	return resp
+}
+
+func ToGetCouponsResponse(coupons []models.Coupon) GetCouponsResponse {
+	resp := make([]Coupon, 0, len(coupons))
+	for _, v := range coupons {
+		resp = append(resp, ToCoupon(v))
+	}
+
+	return GetCouponsResponse{
+		Coupons: resp,
+	}
+}
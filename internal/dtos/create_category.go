@@ -1,9 +1,13 @@
 package dtos
 
 type CreateCategoryRequest struct {
-	Name string `json:"name" validate:"required,min=4"`
+	Name     string `json:"name" validate:"required,min=4,max=100"`
+	ParentID string `json:"parent_id" validate:"omitempty,uuid"` // empty for top level category
+	Slug     string `json:"slug" validate:"omitempty,max=100"`   // made from name if empty
+	Position int    `json:"position" validate:"gte=0"`
 }
 
 type CreateCategoryResponse struct {
-	ID string `json:"id"`
+	ID   string `json:"id"`
+	Slug string `json:"slug"`
 }
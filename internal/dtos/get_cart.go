@@ -1,18 +1,88 @@
 package dtos
 
-import "time"
+import (
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+)
 
 type CartItem struct {
-	ID                string     `json:"id"`
-	Name              string     `json:"name"`
-	Price             int        `json:"price"`
-	ImageUrl          string     `json:"image_url"`
-	Discount          int        `json:"discount"`
-	DiscountExpiresAt *time.Time `json:"discount_expires_at"`
-	Quantity          int        `json:"quantity"`
+	ID                string      `json:"id"`
+	ProductID         string      `json:"product_id"`
+	VariantID         string      `json:"variant_id"`
+	Name              string      `json:"name"`
+	Price             money.Money `json:"price"`
+	ImageUrl          string      `json:"image_url"`
+	Discount          int         `json:"discount"`
+	DiscountExpiresAt *time.Time  `json:"discount_expires_at"`
+	UnitPrice         money.Money `json:"unit_price"`
+	Size              string      `json:"size"`
+	Quantity          int         `json:"quantity"`
+	InStock           int         `json:"in_stock"`
+	PriceChanged      bool        `json:"price_changed"`
+	// unit price user saw when added product, set only if price changed
+	OldUnitPrice *money.Money `json:"old_unit_price,omitempty"`
+	StockChanged bool         `json:"stock_changed"`
+}
+
+type CartCoupon struct {
+	Code     string      `json:"code"`
+	Discount money.Money `json:"discount"`
+	// why coupon isn't applied anymore, cart can't be bought until coupon is removed
+	Error string `json:"error,omitempty"`
 }
 
 type GetCartResponse struct {
-	Products []*CartItem `json:"products"`
-	Price    int         `json:"price"`
+	Products   []*CartItem `json:"products"`
+	Subtotal   money.Money `json:"subtotal"`
+	Coupon     *CartCoupon `json:"coupon,omitempty"`
+	Price      money.Money `json:"price"`
+	HasChanges bool        `json:"has_changes"`
+}
+
+func ToGetCartResponse(cart models.Cart) GetCartResponse {
+	cartItems := make([]*CartItem, 0, len(cart.Products))
+	for _, v := range cart.Products {
+		cartItem := &CartItem{
+			ID:                v.ID.String(),
+			ProductID:         v.ProductID.String(),
+			VariantID:         v.VariantID.String(),
+			Name:              v.Name,
+			Price:             v.Price,
+			ImageUrl:          v.ImageUrl,
+			Discount:          v.Discount,
+			DiscountExpiresAt: v.DiscountExpiresAt,
+			UnitPrice:         v.UnitPrice(),
+			Size:              string(v.Size),
+			Quantity:          v.Quantity,
+			InStock:           v.Stock,
+			PriceChanged:      v.PriceChanged,
+			StockChanged:      v.StockChanged,
+		}
+		if v.PriceChanged {
+			oldUnitPrice := v.SnapshotUnitPrice()
+			cartItem.OldUnitPrice = &oldUnitPrice
+		}
+
+		cartItems = append(cartItems, cartItem)
+	}
+
+	resp := GetCartResponse{
+		Products:   cartItems,
+		Subtotal:   cart.Subtotal,
+		Price:      cart.Price,
+		HasChanges: cart.HasChanges,
+	}
+	if cart.Coupon != nil {
+		resp.Coupon = &CartCoupon{
+			Code:     cart.Coupon.Code,
+			Discount: cart.CouponDiscount,
+		}
+		if cart.CouponError != nil {
+			resp.Coupon.Error = cart.CouponError.Error()
+		}
+	}
+
+	return resp
 }
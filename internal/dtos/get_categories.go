@@ -2,26 +2,37 @@ package dtos
 
 import "github.com/AlexMickh/shop-backend/internal/models"
 
+// GetCategoriesResponse is tree of categories, children are ordered by position
 type GetCategoriesResponse struct {
-	Categories []category `json:"categories"`
+	Categories []Category `json:"categories"`
 }
 
-type category struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+type Category struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Slug     string     `json:"slug"`
+	Position int        `json:"position"`
+	Children []Category `json:"children"`
 }
 
 func ToGetCategoriesResponse(categories []models.Category) GetCategoriesResponse {
-	resp := make([]category, 0, len(categories))
+	return GetCategoriesResponse{
+		Categories: toCategories(categories),
+	}
+}
+
+func toCategories(categories []models.Category) []Category {
+	resp := make([]Category, 0, len(categories))
 
 	for _, v := range categories {
-		resp = append(resp, category{
-			ID:   v.ID.String(),
-			Name: v.Name,
+		resp = append(resp, Category{
+			ID:       v.ID.String(),
+			Name:     v.Name,
+			Slug:     v.Slug,
+			Position: v.Position,
+			Children: toCategories(v.Children),
 		})
 	}
 
-	return GetCategoriesResponse{
-		Categories: resp,
-	}
+	return resp
 }
@@ -0,0 +1,53 @@
+package dtos
+
+import "github.com/AlexMickh/shop-backend/internal/models"
+
+type GetCategoryResponse struct {
+	ID           string          `json:"id"`
+	ParentID     string          `json:"parent_id,omitempty"`
+	Name         string          `json:"name"`
+	Slug         string          `json:"slug"`
+	Description  string          `json:"description"`
+	ImageUrl     string          `json:"image_url,omitempty"`
+	Position     int             `json:"position"`
+	ProductCount int             `json:"product_count"` // including products of subcategories
+	Children     []CategoryChild `json:"children"`
+}
+
+type CategoryChild struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Slug         string `json:"slug"`
+	ImageUrl     string `json:"image_url,omitempty"`
+	Position     int    `json:"position"`
+	ProductCount int    `json:"product_count"`
+}
+
+func ToGetCategoryResponse(category *models.Category) GetCategoryResponse {
+	resp := GetCategoryResponse{
+		ID:           category.ID.String(),
+		Name:         category.Name,
+		Slug:         category.Slug,
+		Description:  category.Description,
+		ImageUrl:     category.ImageUrl,
+		Position:     category.Position,
+		ProductCount: category.ProductCount,
+		Children:     make([]CategoryChild, 0, len(category.Children)),
+	}
+	if category.ParentID != nil {
+		resp.ParentID = category.ParentID.String()
+	}
+
+	for _, v := range category.Children {
+		resp.Children = append(resp.Children, CategoryChild{
+			ID:           v.ID.String(),
+			Name:         v.Name,
+			Slug:         v.Slug,
+			ImageUrl:     v.ImageUrl,
+			Position:     v.Position,
+			ProductCount: v.ProductCount,
+		})
+	}
+
+	return resp
+}
@@ -0,0 +1,77 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+)
+
+type GetOrdersResponse struct {
+	Orders []Order `json:"orders"`
+}
+
+type Order struct {
+	ID     string      `json:"id"`
+	Status string      `json:"status"`
+	Price  money.Money `json:"price"`
+	// already subtracted from price
+	CouponDiscount *money.Money `json:"coupon_discount,omitempty"`
+	Items          []OrderItem  `json:"items"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+type OrderItem struct {
+	ID        string      `json:"id"`
+	ProductID string      `json:"product_id"`
+	Name      string      `json:"name"`
+	Price     money.Money `json:"price"`
+	Discount  int         `json:"discount,omitempty"`
+	Size      string      `json:"size,omitempty"`
+	Quantity  int         `json:"quantity"`
+	Refunded  int         `json:"refunded_quantity,omitempty"`
+}
+
+func ToOrder(order models.Order) Order {
+	resp := Order{
+		ID:        order.ID.String(),
+		Status:    string(order.Status),
+		Price:     order.Price,
+		Items:     make([]OrderItem, 0, len(order.Items)),
+		CreatedAt: order.CreatedAt,
+	}
+	if !order.CouponDiscount.IsZero() {
+		resp.CouponDiscount = &order.CouponDiscount
+	}
+
+	for _, v := range order.Items {
+		item := OrderItem{
+			ID:        v.ID.String(),
+			ProductID: v.ProductID.String(),
+			Name:      v.Name,
+			Price:     v.Price,
+			Discount:  v.Discount,
+			Quantity:  v.Quantity,
+			Refunded:  v.RefundedQuantity,
+		}
+		if v.Size != nil {
+			item.Size = string(*v.Size)
+		}
+
+		resp.Items = append(resp.Items, item)
+	}
+
+	return resp
+}
+
+func ToGetOrdersResponse(orders []models.Order) GetOrdersResponse {
+	resp := make([]Order, 0, len(orders))
+
+	for _, v := range orders {
+		resp = append(resp, ToOrder(v))
+	}
+
+	return GetOrdersResponse{
+		Orders: resp,
+	}
+}
@@ -3,26 +3,102 @@ package dtos
 import (
 	"time"
 
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
 	"github.com/google/uuid"
 )
 
+// GetProductsRequest is one page of catalogue, first page is requested without cursor
 type GetProductsRequest struct {
-	Page       int
-	Popularity bool
-	Price      int
-	CategoryID string
+	Limit      int `validate:"gte=0"`
+	Cursor     string
+	Sort       string `validate:"omitempty,oneof=newest popular price_asc price_desc discount relevance"`
+	CategoryID string `validate:"omitempty,uuid"`
 	Search     string
+	MinPrice   int      `validate:"gte=0"` // in kopecks with discount, 0 is not set
+	MaxPrice   int      `validate:"gte=0"`
+	Sizes      []string `validate:"dive,oneof=xs s m l xl 52 54"`
+	InStock    bool
+	OnSale     bool
 }
 
 type GetProductsResponse struct {
-	Products []Product `json:"products"`
+	Products   []Product      `json:"products"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	HasMore    bool           `json:"has_more"`
+	Facets     *ProductFacets `json:"facets,omitempty"`
+}
+
+// ProductFacets counts products for filter values, every filter is counted without itself
+type ProductFacets struct {
+	Sizes      []SizeFacet     `json:"sizes"`
+	Categories []CategoryFacet `json:"categories"`
+}
+
+type SizeFacet struct {
+	Size  string `json:"size"`
+	Count int    `json:"count"`
+}
+
+type CategoryFacet struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
 }
 
 type Product struct {
-	ID                uuid.UUID  `json:"id"`
-	Name              string     `json:"name"`
-	Price             int        `json:"price"`
-	ImageUrl          string     `json:"image_url"`
-	Discount          int        `json:"discount,omitempty"`
-	DiscountExpiresAt *time.Time `json:"discount_expires_at,omitempty"`
+	ID                uuid.UUID   `json:"id"`
+	Name              string      `json:"name"`
+	Price             money.Money `json:"price"`
+	ImageUrl          string      `json:"image_url"` // thumbnail of main image
+	Discount          int         `json:"discount,omitempty"`
+	DiscountExpiresAt *time.Time  `json:"discount_expires_at,omitempty"`
+	Snippet           string      `json:"snippet,omitempty"` // found words are wrapped in <b>
+}
+
+func ToGetProductsResponse(page models.ProductCardsPage) GetProductsResponse {
+	resp := GetProductsResponse{
+		Products: make([]Product, 0, len(page.Cards)),
+		HasMore:  page.HasMore,
+	}
+
+	for _, v := range page.Cards {
+		resp.Products = append(resp.Products, Product{
+			ID:                v.ID,
+			Name:              v.Name,
+			Price:             v.Price,
+			ImageUrl:          v.ImageUrl,
+			Discount:          v.Discount,
+			DiscountExpiresAt: v.DiscountExpiresAt,
+			Snippet:           v.Snippet,
+		})
+	}
+
+	if page.Next != nil {
+		resp.NextCursor = page.Next.Encode()
+	}
+
+	if page.Facets != nil {
+		resp.Facets = &ProductFacets{
+			Sizes:      make([]SizeFacet, 0, len(page.Facets.Sizes)),
+			Categories: make([]CategoryFacet, 0, len(page.Facets.Categories)),
+		}
+
+		for _, v := range page.Facets.Sizes {
+			resp.Facets.Sizes = append(resp.Facets.Sizes, SizeFacet{
+				Size:  string(v.Size),
+				Count: v.Count,
+			})
+		}
+
+		for _, v := range page.Facets.Categories {
+			resp.Facets.Categories = append(resp.Facets.Categories, CategoryFacet{
+				ID:    v.Category.ID.String(),
+				Name:  v.Category.Name,
+				Count: v.Count,
+			})
+		}
+	}
+
+	return resp
 }
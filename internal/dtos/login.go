@@ -3,6 +3,8 @@ package dtos
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=4"`
+	// id of guest cart from cookie, it's merged to the user cart
+	GuestCartId string `json:"-" validate:"omitempty,uuid"`
 }
 
 type LoginResponse struct {
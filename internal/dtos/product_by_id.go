@@ -1,19 +1,32 @@
 package dtos
 
-import "time"
+import (
+	"time"
+
+	"github.com/AlexMickh/shop-backend/pkg/money"
+)
 
 type ProductByIdResponse struct {
-	ID                string     `json:"id"`
-	Name              string     `json:"name"`
-	Description       string     `json:"description"`
-	Price             int        `json:"price"`
-	Quantity          int        `json:"quantity"`
-	ExistingSizes     []string   `json:"existing_sizes"`
-	ImageUrl          string     `json:"image_url"`
-	Discount          int        `json:"discount,omitempty"`
-	DiscountExpiresAt *time.Time `json:"discount_expires_at,omitempty"`
+	ID                string         `json:"id"`
+	Name              string         `json:"name"`
+	Description       string         `json:"description"`
+	Price             money.Money    `json:"price"`
+	LowestPrice30d    money.Money    `json:"lowest_price_30d"` // lowest price with discounts over 30 days before current price was set
+	Quantity          int            `json:"quantity"`
+	ExistingSizes     []string       `json:"existing_sizes"`
+	Sizes             []SizeStock    `json:"sizes"`
+	ImageUrl          string         `json:"image_url"` // main image
+	Images            []ProductImage `json:"images"`    // gallery, the first is main
+	Discount          int            `json:"discount,omitempty"`
+	DiscountExpiresAt *time.Time     `json:"discount_expires_at,omitempty"`
 	Category          struct {
 		ID   string `json:"id"`
 		Name string `json:"name"`
 	} `json:"category"`
 }
+
+// SizeStock is stock of one product size, sold out sizes have zero stock
+type SizeStock struct {
+	Size  string `json:"size"`
+	Stock int    `json:"stock"`
+}
@@ -0,0 +1,48 @@
+package dtos
+
+import (
+	"mime/multipart"
+
+	"github.com/AlexMickh/shop-backend/internal/models"
+)
+
+// AddProductImageRequest adds image to the end of gallery, main image is put first
+type AddProductImageRequest struct {
+	ProductID string `validate:"required,uuid"`
+	Image     multipart.File
+	Main      bool
+}
+
+// ReorderImagesRequest has ids of all product images in new order, the first becomes main
+type ReorderImagesRequest struct {
+	ProductID string   `json:"-" validate:"required,uuid"`
+	ImageIDs  []string `json:"image_ids" validate:"required,min=1,dive,uuid"`
+}
+
+type ProductImage struct {
+	ID        string `json:"id"`
+	Url       string `json:"url"` // full size
+	MediumUrl string `json:"medium_url"`
+	ThumbUrl  string `json:"thumb_url"`
+	Position  int    `json:"position"`
+}
+
+func ToProductImage(image models.ProductImage) ProductImage {
+	return ProductImage{
+		ID:        image.ID.String(),
+		Url:       image.Url,
+		MediumUrl: image.MediumUrl,
+		ThumbUrl:  image.ThumbUrl,
+		Position:  image.Position,
+	}
+}
+
+func ToProductImages(images []models.ProductImage) []ProductImage {
+	resp := make([]ProductImage, 0, len(images))
+
+	for _, v := range images {
+		resp = append(resp, ToProductImage(v))
+	}
+
+	return resp
+}
@@ -0,0 +1,55 @@
+package dtos
+
+import "github.com/AlexMickh/shop-backend/internal/models"
+
+type CreateVariantRequest struct {
+	ProductID string  `validate:"required,uuid"`
+	Size      string  `json:"size" validate:"required,oneof=xs s m l xl 52 54"`
+	Stock     int     `json:"stock" validate:"gte=0"`
+	Sku       *string `json:"sku" validate:"omitempty,min=1,max=64"`
+	Barcode   *string `json:"barcode" validate:"omitempty,min=1,max=64"`
+}
+
+// UpdateVariantRequest changes only not nil fields
+type UpdateVariantRequest struct {
+	ID        string  `validate:"required,uuid"`
+	ProductID string  `validate:"required,uuid"`
+	Size      *string `json:"size" validate:"omitempty,oneof=xs s m l xl 52 54"`
+	Stock     *int    `json:"stock" validate:"omitempty,gte=0"`
+	Sku       *string `json:"sku" validate:"omitempty,min=1,max=64"`
+	Barcode   *string `json:"barcode" validate:"omitempty,min=1,max=64"`
+}
+
+type Variant struct {
+	ID      string  `json:"id"`
+	Size    string  `json:"size"`
+	Stock   int     `json:"stock"`
+	Sku     *string `json:"sku,omitempty"`
+	Barcode *string `json:"barcode,omitempty"`
+}
+
+type GetVariantsResponse struct {
+	Variants []Variant `json:"variants"`
+}
+
+func ToVariant(variant models.ProductVariant) Variant {
+	return Variant{
+		ID:      variant.ID.String(),
+		Size:    string(variant.Size),
+		Stock:   variant.Stock,
+		Sku:     variant.Sku,
+		Barcode: variant.Barcode,
+	}
+}
+
+func ToGetVariantsResponse(variants []models.ProductVariant) GetVariantsResponse {
+	resp := GetVariantsResponse{
+		Variants: make([]Variant, 0, len(variants)),
+	}
+
+	for _, v := range variants {
+		resp.Variants = append(resp.Variants, ToVariant(v))
+	}
+
+	return resp
+}
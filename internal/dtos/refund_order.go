@@ -0,0 +1,75 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+)
+
+// RefundOrderRequest refunds all not refunded items if Items is empty
+type RefundOrderRequest struct {
+	ID     string            `validate:"required,uuid"`
+	Items  []RefundOrderItem `json:"items" validate:"unique=ItemID,dive"`
+	Reason string            `json:"reason" validate:"max=500"`
+}
+
+type RefundOrderItem struct {
+	ItemID   string `json:"item_id" validate:"required,uuid"`
+	Quantity int    `json:"quantity" validate:"required,gt=0"`
+}
+
+type Refund struct {
+	ID              string       `json:"id"`
+	PaymentRefundID string       `json:"payment_refund_id,omitempty"`
+	Status          string       `json:"status"`
+	Price           money.Money  `json:"price"`
+	Reason          string       `json:"reason,omitempty"`
+	Items           []RefundItem `json:"items"`
+	CreatedAt       time.Time    `json:"created_at"`
+}
+
+type RefundItem struct {
+	ItemID   string      `json:"item_id"`
+	Quantity int         `json:"quantity"`
+	Price    money.Money `json:"price"`
+}
+
+type GetRefundsResponse struct {
+	Refunds []Refund `json:"refunds"`
+}
+
+func ToRefund(refund models.OrderRefund) Refund {
+	resp := Refund{
+		ID:        refund.ID.String(),
+		Status:    string(refund.Status),
+		Price:     refund.Price,
+		Reason:    refund.Reason,
+		Items:     make([]RefundItem, 0, len(refund.Items)),
+		CreatedAt: refund.CreatedAt,
+	}
+	if refund.PaymentRefundID != nil {
+		resp.PaymentRefundID = *refund.PaymentRefundID
+	}
+
+	for _, v := range refund.Items {
+		resp.Items = append(resp.Items, RefundItem{
+			ItemID:   v.OrderItemID.String(),
+			Quantity: v.Quantity,
+			Price:    v.Price,
+		})
+	}
+
+	return resp
+}
+
+func ToGetRefundsResponse(refunds []models.OrderRefund) GetRefundsResponse {
+	resp := make([]Refund, 0, len(refunds))
+	for _, v := range refunds {
+		resp = append(resp, ToRefund(v))
+	}
+
+	return GetRefundsResponse{
+		Refunds: resp,
+	}
+}
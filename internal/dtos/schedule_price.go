@@ -0,0 +1,30 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+)
+
+type SchedulePriceRequest struct {
+	ProductID string    `json:"-" validate:"required,uuid"`
+	Price     int       `json:"price" validate:"required,gt=0"`
+	StartsAt  time.Time `json:"starts_at" validate:"required"`
+}
+
+type ScheduledPrice struct {
+	ID        string      `json:"id"`
+	ProductID string      `json:"product_id"`
+	Price     money.Money `json:"price"`
+	StartsAt  time.Time   `json:"starts_at"`
+}
+
+func ToScheduledPrice(price models.ScheduledPrice) ScheduledPrice {
+	return ScheduledPrice{
+		ID:        price.ID.String(),
+		ProductID: price.ProductID.String(),
+		Price:     price.Price,
+		StartsAt:  price.StartsAt,
+	}
+}
@@ -0,0 +1,37 @@
+package dtos
+
+import "github.com/AlexMickh/shop-backend/internal/models"
+
+type SuggestRequest struct {
+	Query string `validate:"required,max=100"`
+}
+
+type SuggestResponse struct {
+	Products   []suggestion `json:"products"`
+	Categories []suggestion `json:"categories"`
+}
+
+type suggestion struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func ToSuggestResponse(suggestions models.Suggestions) SuggestResponse {
+	return SuggestResponse{
+		Products:   toSuggestions(suggestions.Products),
+		Categories: toSuggestions(suggestions.Categories),
+	}
+}
+
+func toSuggestions(suggestions []models.Suggestion) []suggestion {
+	resp := make([]suggestion, 0, len(suggestions))
+
+	for _, v := range suggestions {
+		resp = append(resp, suggestion{
+			ID:   v.ID.String(),
+			Name: v.Name,
+		})
+	}
+
+	return resp
+}
@@ -0,0 +1,9 @@
+package dtos
+
+import "github.com/AlexMickh/shop-backend/internal/models"
+
+type UpdateCartItemRequest struct {
+	Owner    models.CartOwner
+	ID       string `validate:"required,uuid"`
+	Quantity int    `json:"quantity" validate:"required,gt=0"`
+}
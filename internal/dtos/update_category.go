@@ -0,0 +1,13 @@
+package dtos
+
+import "mime/multipart"
+
+// UpdateCategoryRequest changes only set fields, nil Position means not changed
+type UpdateCategoryRequest struct {
+	ID          string `validate:"required,uuid"`
+	Name        string `validate:"omitempty,min=4,max=100"`
+	Slug        string `validate:"omitempty,max=100"`
+	Description string
+	Position    *int `validate:"omitempty,gte=0"`
+	Image       multipart.File
+}
@@ -0,0 +1,6 @@
+package dtos
+
+type UpdateOrderStatusRequest struct {
+	ID     string `validate:"required,uuid"`
+	Status string `json:"status" validate:"required,oneof=pending_payment paid shipped delivered cancelled"`
+}
@@ -5,14 +5,14 @@ import (
 	"time"
 )
 
+// UpdateProductRequest changes only set fields, zero Price and nil Discount mean not changed
 type UpdateProductRequest struct {
-	ID                string     `validate:"required"`
-	Name              string     `validate:"min=5"`
-	Description       string     `validate:"min=5"`
-	Price             int        `validate:"gt=0"`
-	Quantity          int        `validate:"gte=0"`
-	ExistingSizes     []string   `validate:"gt=0,dive,oneof=xs s m l xl 52 54"`
-	Discount          int        `validate:"gte=0"`
-	DiscountExpiresAt *time.Time `validate:"datetime"`
+	ID                string `validate:"required,uuid"`
+	Name              string `validate:"omitempty,min=5"`
+	Description       string `validate:"omitempty,min=5"`
+	Price             int    `validate:"gte=0"`
+	Discount          *int   `validate:"omitempty,gte=0,lt=100"`
+	DiscountStartsAt  *time.Time
+	DiscountExpiresAt *time.Time
 	Image             multipart.File
 }
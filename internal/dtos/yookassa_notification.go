@@ -0,0 +1,10 @@
+package dtos
+
+// YookassaNotification is a webhook body, only fields needed to find the payment are decoded
+type YookassaNotification struct {
+	Type   string `json:"type"`
+	Event  string `json:"event"`
+	Object struct {
+		ID string `json:"id"`
+	} `json:"object"`
+}
@@ -12,10 +12,36 @@ var (
 	ErrSessionNotFound       = errors.New("session not found")
 	ErrCategoryAlreadyExists = errors.New("category already exists")
 	ErrCategoryNotFound      = errors.New("category not found")
-	ErrUnsupportedImageType  = errors.New("unsupported image type (only png)")
+	ErrCategoryNotEmpty      = errors.New("category has products or subcategories, move them first")
+	ErrUnsupportedImageType  = errors.New("unsupported image type (only png, jpeg and webp)")
+	ErrImageTooLarge         = errors.New("image file or dimensions are too large")
 	ErrProductAlreadyExists  = errors.New("producct already exists")
 	ErrProductNotFound       = errors.New("product not found")
+	ErrVariantAlreadyExists  = errors.New("variant with this size, sku or barcode already exists")
+	ErrVariantNotFound       = errors.New("product size not found")
+	ErrImageNotFound         = errors.New("product image not found")
+	ErrFileNotFound          = errors.New("file not found")
+	ErrNotEnoughStock        = errors.New("not enough products in stock")
+	ErrReservationExpired    = errors.New("stock reservation of the order is expired")
 	ErrCartEmpty             = errors.New("cart is empty")
+	ErrCartItemNotFound      = errors.New("cart item not found")
+	ErrCartChanged           = errors.New("cart has changed, acknowledge changes before buying")
+	ErrCouponAlreadyExists   = errors.New("coupon with this code already exists")
+	ErrCouponNotFound        = errors.New("coupon not found")
+	ErrCouponNotActive       = errors.New("coupon is expired or not started yet")
+	ErrCouponNotApplicable   = errors.New("coupon isn't applicable to products in cart")
+	ErrCouponMinOrderPrice   = errors.New("order price is less than coupon minimum")
+	ErrCouponUsageLimit      = errors.New("coupon usage limit is reached")
+	ErrOrderNotFound         = errors.New("order not found")
+	ErrOrderStatusTransition = errors.New("order can't be moved to this status")
 	ErrCreatePayment         = errors.New("failed to create payment")
+	ErrPaymentNotFound       = errors.New("payment not found")
+	ErrCancelPayment         = errors.New("failed to cancel payment")
+	ErrCapturePayment        = errors.New("failed to capture payment")
+	ErrPaymentPriceMismatch  = errors.New("paid price differs from order price")
+	ErrRefundPayment         = errors.New("failed to refund payment")
+	ErrRefundPending         = errors.New("refund is pending, provider hasn't answered")
+	ErrPaymentRejected       = errors.New("payment provider rejected request")
+	ErrRefundQuantity        = errors.New("refund quantity is greater than not refunded quantity")
 	ErrInvalidRequest        = errors.New("failed to validate request")
 )
@@ -0,0 +1,61 @@
+package file_storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AlexMickh/shop-backend/internal/config"
+	fs_storage "github.com/AlexMickh/shop-backend/internal/file_storage/fs"
+	s3_storage "github.com/AlexMickh/shop-backend/internal/file_storage/s3"
+	"github.com/AlexMickh/shop-backend/internal/models"
+)
+
+const (
+	BackendFs = "fs"
+	BackendS3 = "s3"
+)
+
+type FileStorage interface {
+	SaveImage(ctx context.Context, image []byte) (string, error)
+	DeleteImage(ctx context.Context, url string) error
+	File(ctx context.Context, url string) (models.StoredFile, error)
+	Files(ctx context.Context) ([]models.StoredFile, error)
+}
+
+// New creates storage selected in config. Files of fs backend are served by file server
+// on fileServerAddr, so it works only with one app replica.
+func New(ctx context.Context, cfg config.StorageConfig, fileServerAddr string) (FileStorage, error) {
+	const op = "file_storage.New"
+
+	switch cfg.Backend {
+	case BackendFs:
+		storage, err := fs_storage.New(cfg.Path, fileServerAddr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return storage, nil
+	case BackendS3:
+		if cfg.S3.AccessKey == "" || cfg.S3.SecretKey == "" {
+			return nil, fmt.Errorf("%s: s3 access key and secret key are required", op)
+		}
+
+		storage, err := s3_storage.New(
+			ctx,
+			cfg.S3.Endpoint,
+			cfg.S3.AccessKey,
+			cfg.S3.SecretKey,
+			cfg.S3.Bucket,
+			cfg.S3.Region,
+			cfg.S3.UseSSL,
+			cfg.S3.PublicUrl,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return storage, nil
+	}
+
+	return nil, fmt.Errorf("%s: unknown storage backend %q", op, cfg.Backend)
+}
@@ -1,10 +1,18 @@
 package file_storage
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
+	"path"
+	"path/filepath"
+	"time"
 
-	"github.com/google/uuid"
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
 )
 
 type FileStorage struct {
@@ -26,24 +34,113 @@ func New(basePath string, serverAddr string) (*FileStorage, error) {
 	}, nil
 }
 
-func (f *FileStorage) SaveImage(id uuid.UUID, image []byte) (string, error) {
+// SaveImage writes image to file named by hash of its content, so changed image gets new url
+// and images with the same content share one file
+func (f *FileStorage) SaveImage(ctx context.Context, image []byte) (string, error) {
 	const op = "file_storage.fs.SaveImage"
 
-	err := os.WriteFile(fmt.Sprintf("%s/%s.png", f.basePath, id.String()), image, 0600)
+	sum := sha256.Sum256(image)
+	name := hex.EncodeToString(sum[:]) + ".jpg"
+	filePath := filepath.Join(f.basePath, name)
+
+	// file with the same content already exists, it's touched so garbage collector sees it as new
+	now := time.Now()
+	if err := os.Chtimes(filePath, now, now); err == nil {
+		return f.url(name), nil
+	}
+
+	// image is written to temporary file and renamed, so half written file is never served
+	tmp, err := os.CreateTemp(f.basePath, ".tmp-*")
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(image); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
 
-	return fmt.Sprintf("http://%s/%s.png", f.serverAddr, id.String()), nil
+	if err = os.Rename(tmp.Name(), filePath); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return f.url(name), nil
 }
 
-func (f *FileStorage) DeleteImage(id uuid.UUID) error {
+// DeleteImage removes file of image url, missing file isn't an error
+func (f *FileStorage) DeleteImage(ctx context.Context, url string) error {
 	const op = "file_storage.fs.DeleteImage"
 
-	err := os.Remove(fmt.Sprintf("%s/%s.png", f.basePath, id.String()))
-	if err != nil {
+	err := os.Remove(filepath.Join(f.basePath, path.Base(url)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
+
+// File returns file of image url
+func (f *FileStorage) File(ctx context.Context, url string) (models.StoredFile, error) {
+	const op = "file_storage.fs.File"
+
+	name := path.Base(url)
+	info, err := os.Stat(filepath.Join(f.basePath, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return models.StoredFile{}, fmt.Errorf("%s: %w", op, errs.ErrFileNotFound)
+		}
+
+		return models.StoredFile{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return models.StoredFile{
+		Name:       name,
+		Url:        f.url(name),
+		ModifiedAt: info.ModTime(),
+	}, nil
+}
+
+// Files returns all files in storage, including temporary ones left after crash
+func (f *FileStorage) Files(ctx context.Context) ([]models.StoredFile, error) {
+	const op = "file_storage.fs.Files"
+
+	entries, err := os.ReadDir(f.basePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	files := make([]models.StoredFile, 0, len(entries))
+	for _, v := range entries {
+		if !v.Type().IsRegular() {
+			continue
+		}
+
+		info, err := v.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		files = append(files, models.StoredFile{
+			Name:       v.Name(),
+			Url:        f.url(v.Name()),
+			ModifiedAt: info.ModTime(),
+		})
+	}
+
+	return files, nil
+}
+
+func (f *FileStorage) url(name string) string {
+	return fmt.Sprintf("http://%s/%s", f.serverAddr, name)
+}
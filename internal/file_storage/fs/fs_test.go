@@ -0,0 +1,43 @@
+package file_storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/AlexMickh/shop-backend/internal/file_storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.FileStorage {
+		return newTestStorage(t)
+	})
+}
+
+func TestFileStorage_NoTemporaryFiles(t *testing.T) {
+	storage := newTestStorage(t)
+
+	_, err := storage.SaveImage(context.Background(), []byte("image"))
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(storage.basePath)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.False(t, strings.HasPrefix(entries[0].Name(), ".tmp-"))
+}
+
+func newTestStorage(t *testing.T) *FileStorage {
+	dir := t.TempDir()
+
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(server.Close)
+
+	storage, err := New(dir, strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+
+	return storage
+}
@@ -0,0 +1,146 @@
+package file_storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// FileStorage keeps images in S3 compatible bucket, so every app replica sees the same files
+type FileStorage struct {
+	client    *minio.Client
+	bucket    string
+	publicUrl string
+}
+
+// New connects to endpoint and creates bucket if it doesn't exist.
+// Urls of images start with publicUrl, if it's empty they point to bucket on endpoint.
+func New(
+	ctx context.Context,
+	endpoint, accessKey, secretKey, bucket, region string,
+	useSSL bool,
+	publicUrl string,
+) (*FileStorage, error) {
+	const op = "file_storage.s3.New"
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure:       useSSL,
+		Region:       region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		err = client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: region})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if publicUrl == "" {
+		publicUrl = client.EndpointURL().JoinPath(bucket).String()
+	}
+
+	return &FileStorage{
+		client:    client,
+		bucket:    bucket,
+		publicUrl: strings.TrimSuffix(publicUrl, "/"),
+	}, nil
+}
+
+// SaveImage uploads image named by hash of its content, so changed image gets new url and
+// images with the same content share one object. Upload is atomic, half written object isn't visible.
+func (f *FileStorage) SaveImage(ctx context.Context, image []byte) (string, error) {
+	const op = "file_storage.s3.SaveImage"
+
+	sum := sha256.Sum256(image)
+	name := hex.EncodeToString(sum[:]) + ".jpg"
+
+	// object is uploaded even if it exists, so garbage collector sees it as new
+	_, err := f.client.PutObject(ctx, f.bucket, name, bytes.NewReader(image), int64(len(image)), minio.PutObjectOptions{
+		ContentType:  "image/jpeg",
+		CacheControl: "public, max-age=31536000, immutable",
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return f.url(name), nil
+}
+
+// DeleteImage removes object of image url, missing object isn't an error
+func (f *FileStorage) DeleteImage(ctx context.Context, url string) error {
+	const op = "file_storage.s3.DeleteImage"
+
+	err := f.client.RemoveObject(ctx, f.bucket, path.Base(url), minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// File returns object of image url
+func (f *FileStorage) File(ctx context.Context, url string) (models.StoredFile, error) {
+	const op = "file_storage.s3.File"
+
+	name := path.Base(url)
+	info, err := f.client.StatObject(ctx, f.bucket, name, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return models.StoredFile{}, fmt.Errorf("%s: %w", op, errs.ErrFileNotFound)
+		}
+
+		return models.StoredFile{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return models.StoredFile{
+		Name:       name,
+		Url:        f.url(name),
+		ModifiedAt: info.LastModified,
+	}, nil
+}
+
+// Files returns all objects in bucket
+func (f *FileStorage) Files(ctx context.Context) ([]models.StoredFile, error) {
+	const op = "file_storage.s3.Files"
+
+	// listing is stopped by cancel if it fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	files := make([]models.StoredFile, 0)
+	for object := range f.client.ListObjects(ctx, f.bucket, minio.ListObjectsOptions{Recursive: true}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("%s: %w", op, object.Err)
+		}
+
+		files = append(files, models.StoredFile{
+			Name:       object.Key,
+			Url:        f.url(object.Key),
+			ModifiedAt: object.LastModified,
+		})
+	}
+
+	return files, nil
+}
+
+func (f *FileStorage) url(name string) string {
+	return fmt.Sprintf("%s/%s", f.publicUrl, name)
+}
@@ -0,0 +1,218 @@
+package file_storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/file_storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.FileStorage {
+		server := httptest.NewServer(newFakeS3())
+		t.Cleanup(server.Close)
+
+		storage, err := New(
+			context.Background(),
+			strings.TrimPrefix(server.URL, "http://"),
+			"access",
+			"secret",
+			"images",
+			"us-east-1",
+			false,
+			"",
+		)
+		require.NoError(t, err)
+
+		return storage
+	})
+}
+
+// TestFileStorage_Minio runs the same tests against real MinIO, bucket must allow anonymous read
+func TestFileStorage_Minio(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT isn't set")
+	}
+
+	storagetest.Run(t, func(t *testing.T) storagetest.FileStorage {
+		storage, err := New(
+			context.Background(),
+			endpoint,
+			os.Getenv("S3_TEST_ACCESS_KEY"),
+			os.Getenv("S3_TEST_SECRET_KEY"),
+			os.Getenv("S3_TEST_BUCKET"),
+			"us-east-1",
+			false,
+			"",
+		)
+		require.NoError(t, err)
+
+		return storage
+	})
+}
+
+// fakeS3 is in memory stand-in for S3, it handles only requests made by FileStorage
+// and doesn't check signatures
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		buckets: make(map[string]map[string][]byte),
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	objects, exists := f.buckets[bucket]
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case key == "" && r.Method == http.MethodPut:
+		f.buckets[bucket] = make(map[string][]byte)
+	case !exists:
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+	case key == "" && r.Method == http.MethodGet:
+		writeObjectList(w, bucket, r.URL.Query().Get("prefix"), objects)
+	case r.Method == http.MethodPut:
+		body, err := readBody(r)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+
+		objects[key] = body
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodHead:
+		body, ok := objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	case r.Method == http.MethodGet:
+		body, ok := objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+
+		w.Write(body)
+	case r.Method == http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// readBody reads object, it's sent in aws-chunked encoding if payload is signed by chunks
+func readBody(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var body bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return body.Bytes(), nil
+		}
+
+		if _, err = io.CopyN(&body, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err = reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+type listBucketResult struct {
+	XMLName     xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name        string          `xml:"Name"`
+	Prefix      string          `xml:"Prefix"`
+	KeyCount    int             `xml:"KeyCount"`
+	MaxKeys     int             `xml:"MaxKeys"`
+	IsTruncated bool            `xml:"IsTruncated"`
+	Contents    []objectContent `xml:"Contents"`
+}
+
+type objectContent struct {
+	Key          string `xml:"Key"`
+	Size         int    `xml:"Size"`
+	ETag         string `xml:"ETag"`
+	LastModified string `xml:"LastModified"`
+}
+
+func writeObjectList(w http.ResponseWriter, bucket, prefix string, objects map[string][]byte) {
+	result := listBucketResult{
+		Name:    bucket,
+		Prefix:  prefix,
+		MaxKeys: 1000,
+	}
+
+	for key, body := range objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		result.Contents = append(result.Contents, objectContent{
+			Key:          key,
+			Size:         len(body),
+			ETag:         `"etag"`,
+			LastModified: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+		})
+	}
+	sort.Slice(result.Contents, func(i, j int) bool {
+		return result.Contents[i].Key < result.Contents[j].Key
+	})
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+	}{Code: code})
+}
@@ -0,0 +1,160 @@
+// Package storagetest has tests which every file storage backend must pass
+package storagetest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/stretchr/testify/require"
+)
+
+type FileStorage interface {
+	SaveImage(ctx context.Context, image []byte) (string, error)
+	DeleteImage(ctx context.Context, url string) error
+	File(ctx context.Context, url string) (models.StoredFile, error)
+	Files(ctx context.Context) ([]models.StoredFile, error)
+}
+
+// Run checks storage created by newStorage for every test. Urls returned by storage
+// must be downloadable without any credentials.
+func Run(t *testing.T, newStorage func(t *testing.T) FileStorage) {
+	ctx := context.Background()
+
+	t.Run("save case", func(t *testing.T) {
+		storage := newStorage(t)
+
+		thumbUrl, err := storage.SaveImage(ctx, []byte("thumb image"))
+		require.NoError(t, err)
+		fullUrl, err := storage.SaveImage(ctx, []byte("full image"))
+		require.NoError(t, err)
+
+		require.NotEqual(t, thumbUrl, fullUrl)
+		requireFile(t, thumbUrl, "thumb image")
+		requireFile(t, fullUrl, "full image")
+	})
+
+	t.Run("same content case", func(t *testing.T) {
+		storage := newStorage(t)
+
+		url, err := storage.SaveImage(ctx, []byte("image"))
+		require.NoError(t, err)
+		sameUrl, err := storage.SaveImage(ctx, []byte("image"))
+		require.NoError(t, err)
+
+		require.Equal(t, url, sameUrl)
+		requireFiles(t, storage, url)
+	})
+
+	t.Run("changed content case", func(t *testing.T) {
+		storage := newStorage(t)
+
+		oldUrl, err := storage.SaveImage(ctx, []byte("old image"))
+		require.NoError(t, err)
+		newUrl, err := storage.SaveImage(ctx, []byte("new image"))
+		require.NoError(t, err)
+
+		// old url isn't overwritten, so caches can't serve old image by new url
+		require.NotEqual(t, oldUrl, newUrl)
+		requireFile(t, oldUrl, "old image")
+		requireFile(t, newUrl, "new image")
+	})
+
+	t.Run("delete case", func(t *testing.T) {
+		storage := newStorage(t)
+
+		url, err := storage.SaveImage(ctx, []byte("image"))
+		require.NoError(t, err)
+		otherUrl, err := storage.SaveImage(ctx, []byte("other image"))
+		require.NoError(t, err)
+
+		require.NoError(t, storage.DeleteImage(ctx, url))
+
+		requireNoFile(t, url)
+		requireFile(t, otherUrl, "other image")
+		requireFiles(t, storage, otherUrl)
+	})
+
+	t.Run("delete not existing case", func(t *testing.T) {
+		storage := newStorage(t)
+
+		url, err := storage.SaveImage(ctx, []byte("image"))
+		require.NoError(t, err)
+		require.NoError(t, storage.DeleteImage(ctx, url))
+
+		require.NoError(t, storage.DeleteImage(ctx, url))
+	})
+
+	t.Run("files case", func(t *testing.T) {
+		storage := newStorage(t)
+		startedAt := time.Now().Add(-time.Minute)
+
+		url, err := storage.SaveImage(ctx, []byte("image"))
+		require.NoError(t, err)
+
+		files, err := storage.Files(ctx)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.Equal(t, url, files[0].Url)
+		require.True(t, files[0].ModifiedAt.After(startedAt))
+	})
+
+	t.Run("file case", func(t *testing.T) {
+		storage := newStorage(t)
+		startedAt := time.Now().Add(-time.Minute)
+
+		url, err := storage.SaveImage(ctx, []byte("image"))
+		require.NoError(t, err)
+
+		file, err := storage.File(ctx, url)
+		require.NoError(t, err)
+		require.Equal(t, url, file.Url)
+		require.True(t, file.ModifiedAt.After(startedAt))
+
+		require.NoError(t, storage.DeleteImage(ctx, url))
+
+		_, err = storage.File(ctx, url)
+		require.ErrorIs(t, err, errs.ErrFileNotFound)
+	})
+}
+
+func requireFile(t *testing.T, url string, want string) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, want, string(body))
+}
+
+func requireNoFile(t *testing.T, url string) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func requireFiles(t *testing.T, storage FileStorage, urls ...string) {
+	t.Helper()
+
+	files, err := storage.Files(context.Background())
+	require.NoError(t, err)
+
+	got := make([]string, 0, len(files))
+	for _, v := range files {
+		got = append(got, v.Url)
+	}
+	require.ElementsMatch(t, urls, got)
+}
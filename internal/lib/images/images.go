@@ -0,0 +1,124 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+
+	"github.com/AlexMickh/shop-backend/internal/config"
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	SizeThumb  = "thumb"
+	SizeMedium = "medium"
+	SizeFull   = "full"
+)
+
+// Image is one size of processed image encoded as jpeg
+type Image struct {
+	Size string
+	Data []byte
+}
+
+type size struct {
+	name    string
+	maxSide int
+}
+
+// Processor checks uploaded images and makes thumb, medium and full sizes of them
+type Processor struct {
+	maxBytes  int
+	maxWidth  int
+	maxHeight int
+	maxPixels int
+	quality   int
+	sizes     []size
+}
+
+func New(cfg config.ImageConfig) *Processor {
+	return &Processor{
+		maxBytes:  cfg.MaxBytes,
+		maxWidth:  cfg.MaxWidth,
+		maxHeight: cfg.MaxHeight,
+		maxPixels: cfg.MaxPixels,
+		quality:   cfg.Quality,
+		sizes: []size{
+			{name: SizeThumb, maxSide: cfg.ThumbSize},
+			{name: SizeMedium, maxSide: cfg.MediumSize},
+			{name: SizeFull, maxSide: cfg.FullSize},
+		},
+	}
+}
+
+// Process decodes png, jpeg or webp image and returns it in every size. Type is found
+// by content, not by file name. Images are only scaled down, transparent pixels become white.
+func (p *Processor) Process(data []byte) ([]Image, error) {
+	const op = "lib.images.Process"
+
+	if len(data) > p.maxBytes {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrImageTooLarge)
+	}
+
+	// dimensions are checked before decoding, so huge image isn't loaded into memory
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrUnsupportedImageType)
+	}
+	if format != "png" && format != "jpeg" && format != "webp" {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrUnsupportedImageType)
+	}
+	if cfg.Width > p.maxWidth || cfg.Height > p.maxHeight {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrImageTooLarge)
+	}
+	// both sides can be in limits, but together give too much memory to decode
+	if int64(cfg.Width)*int64(cfg.Height) > int64(p.maxPixels) {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrImageTooLarge)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrUnsupportedImageType)
+	}
+
+	images := make([]Image, 0, len(p.sizes))
+	for _, v := range p.sizes {
+		var buf bytes.Buffer
+		err = jpeg.Encode(&buf, resize(src, v.maxSide), &jpeg.Options{Quality: p.quality})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		images = append(images, Image{
+			Size: v.name,
+			Data: buf.Bytes(),
+		})
+	}
+
+	return images, nil
+}
+
+// resize fits image into maxSide x maxSide square keeping proportions
+func resize(src image.Image, maxSide int) image.Image {
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			height = max(height*maxSide/width, 1)
+			width = maxSide
+		} else {
+			width = max(width*maxSide/height, 1)
+			height = maxSide
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	return dst
+}
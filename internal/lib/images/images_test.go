@@ -0,0 +1,114 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/AlexMickh/shop-backend/internal/config"
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_Process(t *testing.T) {
+	processor := New(config.ImageConfig{
+		MaxBytes:   1 << 20,
+		MaxWidth:   1000,
+		MaxHeight:  1000,
+		MaxPixels:  400000,
+		Quality:    80,
+		ThumbSize:  50,
+		MediumSize: 200,
+		FullSize:   500,
+	})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    map[string]image.Point
+		wantErr error
+	}{
+		{
+			name: "png case",
+			data: encodePng(t, 400, 200),
+			want: map[string]image.Point{
+				SizeThumb:  {X: 50, Y: 25},
+				SizeMedium: {X: 200, Y: 100},
+				SizeFull:   {X: 400, Y: 200},
+			},
+		},
+		{
+			name: "jpeg case",
+			data: encodeJpeg(t, 300, 600),
+			want: map[string]image.Point{
+				SizeThumb:  {X: 25, Y: 50},
+				SizeMedium: {X: 100, Y: 200},
+				SizeFull:   {X: 250, Y: 500},
+			},
+		},
+		{
+			name:    "not image case",
+			data:    []byte("<svg></svg>"),
+			wantErr: errs.ErrUnsupportedImageType,
+		},
+		{
+			name:    "big dimensions case",
+			data:    encodePng(t, 1001, 10),
+			wantErr: errs.ErrImageTooLarge,
+		},
+		{
+			name:    "many pixels case",
+			data:    encodePng(t, 700, 700),
+			wantErr: errs.ErrImageTooLarge,
+		},
+		{
+			name:    "big file case",
+			data:    make([]byte, 1<<20+1),
+			wantErr: errs.ErrImageTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := processor.Process(tt.data)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, images, len(tt.want))
+
+			for _, v := range images {
+				cfg, format, err := image.DecodeConfig(bytes.NewReader(v.Data))
+				require.NoError(t, err)
+				require.Equal(t, "jpeg", format)
+				require.Equal(t, tt.want[v.Size], image.Point{X: cfg.Width, Y: cfg.Height}, v.Size)
+			}
+		})
+	}
+}
+
+func newImage(width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		img.Set(x, height/2, color.NRGBA{R: 200, A: 255})
+	}
+
+	return img
+}
+
+func encodePng(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, newImage(width, height)))
+
+	return buf.Bytes()
+}
+
+func encodeJpeg(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, newImage(width, height), nil))
+
+	return buf.Bytes()
+}
@@ -0,0 +1,53 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AlexMickh/shop-backend/internal/config"
+	"github.com/AlexMickh/shop-backend/internal/lib/payment/sandbox"
+	"github.com/AlexMickh/shop-backend/internal/lib/payment/yookassa"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+const (
+	ProviderYookassa = "yookassa"
+	ProviderSandbox  = "sandbox"
+)
+
+type Provider interface {
+	CreatePayment(ctx context.Context, orderId uuid.UUID, price money.Money) (models.Payment, error)
+	Payment(ctx context.Context, id string) (models.Payment, error)
+	Capture(ctx context.Context, id string) (models.Payment, error)
+	Cancel(ctx context.Context, id string) (models.Payment, error)
+	Refund(ctx context.Context, refundId uuid.UUID, paymentId string, price money.Money) (models.Refund, error)
+	RefundById(ctx context.Context, id string) (models.Refund, error)
+}
+
+// New creates provider selected in config. Returned channel gets ids of payments
+// that changed status, it's nil for providers that notify through webhooks.
+func New(ctx context.Context, cfg config.PaymentConfig) (Provider, <-chan string, error) {
+	const op = "lib.payment.New"
+
+	switch cfg.Provider {
+	case ProviderYookassa:
+		if cfg.Yookassa.ShopId == "" || cfg.Yookassa.SecretKey == "" {
+			return nil, nil, fmt.Errorf("%s: yookassa shop id and secret key are required", op)
+		}
+
+		return yookassa.New(
+			cfg.Yookassa.ShopId,
+			cfg.Yookassa.SecretKey,
+			cfg.Yookassa.ApiUrl,
+			cfg.ReturnUrl,
+		), nil, nil
+	case ProviderSandbox:
+		provider := sandbox.New(ctx, money.Rub(cfg.Sandbox.DeclineFrom), cfg.Sandbox.ConfirmDelay, cfg.ReturnUrl)
+
+		return provider, provider.Notifications(), nil
+	}
+
+	return nil, nil, fmt.Errorf("%s: unknown provider %q", op, cfg.Provider)
+}
@@ -0,0 +1,227 @@
+// Package sandbox is in-process payment provider for dev environments and tests.
+// Every payment is settled after confirmDelay without any network calls:
+// payments with price below declineFrom wait for capture, others are canceled.
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+type payment struct {
+	models.Payment
+	refunded money.Money
+}
+
+type Sandbox struct {
+	ctx          context.Context
+	declineFrom  money.Money
+	confirmDelay time.Duration
+	returnUrl    string
+
+	mu       sync.Mutex
+	payments map[string]*payment
+	// order id -> payment id, the same order can't get second payment
+	orders  map[uuid.UUID]string
+	refunds map[uuid.UUID]models.Refund
+
+	notifications chan string
+}
+
+// New starts sandbox, ids of settled payments are sent to Sandbox.Notifications().
+// Pending confirmations are dropped when ctx is done.
+func New(ctx context.Context, declineFrom money.Money, confirmDelay time.Duration, returnUrl string) *Sandbox {
+	return &Sandbox{
+		ctx:           ctx,
+		declineFrom:   declineFrom,
+		confirmDelay:  confirmDelay,
+		returnUrl:     returnUrl,
+		payments:      make(map[string]*payment),
+		orders:        make(map[uuid.UUID]string),
+		refunds:       make(map[uuid.UUID]models.Refund),
+		notifications: make(chan string, 20),
+	}
+}
+
+// Notifications works like provider webhooks, payment id is sent after its status changed
+func (s *Sandbox) Notifications() <-chan string {
+	return s.notifications
+}
+
+func (s *Sandbox) CreatePayment(ctx context.Context, orderId uuid.UUID, price money.Money) (models.Payment, error) {
+	const op = "lib.payment.sandbox.CreatePayment"
+
+	if !price.IsPositive() {
+		return models.Payment{}, fmt.Errorf("%s: %w: price must be positive", op, errs.ErrCreatePayment)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.orders[orderId]; ok {
+		return s.payments[id].Payment, nil
+	}
+
+	p := &payment{
+		Payment: models.Payment{
+			ID:              uuid.NewString(),
+			OrderID:         orderId,
+			Status:          models.PaymentStatusPending,
+			Price:           price,
+			ConfirmationUrl: s.returnUrl,
+		},
+	}
+
+	s.payments[p.ID] = p
+	s.orders[orderId] = p.ID
+
+	go s.settle(p.ID)
+
+	return p.Payment, nil
+}
+
+func (s *Sandbox) Payment(ctx context.Context, id string) (models.Payment, error) {
+	const op = "lib.payment.sandbox.Payment"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.payments[id]
+	if !ok {
+		return models.Payment{}, fmt.Errorf("%s: %w", op, errs.ErrPaymentNotFound)
+	}
+
+	return p.Payment, nil
+}
+
+// Capture takes money held by payment, like in yookassa it works only after buyer has paid
+func (s *Sandbox) Capture(ctx context.Context, id string) (models.Payment, error) {
+	const op = "lib.payment.sandbox.Capture"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.payments[id]
+	if !ok {
+		return models.Payment{}, fmt.Errorf("%s: %w", op, errs.ErrPaymentNotFound)
+	}
+
+	switch p.Status {
+	case models.PaymentStatusSucceeded:
+		return p.Payment, nil
+	case models.PaymentStatusPending, models.PaymentStatusCanceled:
+		return models.Payment{}, fmt.Errorf("%s: %w: payment is %s", op, errs.ErrCapturePayment, p.Status)
+	}
+
+	p.Status = models.PaymentStatusSucceeded
+
+	return p.Payment, nil
+}
+
+// Cancel cancels payment that is not captured yet
+func (s *Sandbox) Cancel(ctx context.Context, id string) (models.Payment, error) {
+	const op = "lib.payment.sandbox.Cancel"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.payments[id]
+	if !ok {
+		return models.Payment{}, fmt.Errorf("%s: %w", op, errs.ErrPaymentNotFound)
+	}
+
+	switch p.Status {
+	case models.PaymentStatusCanceled:
+		return p.Payment, nil
+	case models.PaymentStatusSucceeded:
+		return models.Payment{}, fmt.Errorf("%s: %w: payment already succeeded", op, errs.ErrCancelPayment)
+	}
+
+	p.Status = models.PaymentStatusCanceled
+
+	return p.Payment, nil
+}
+
+func (s *Sandbox) Refund(ctx context.Context, refundId uuid.UUID, paymentId string, price money.Money) (models.Refund, error) {
+	const op = "lib.payment.sandbox.Refund"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if refund, ok := s.refunds[refundId]; ok {
+		return refund, nil
+	}
+
+	p, ok := s.payments[paymentId]
+	if !ok {
+		return models.Refund{}, fmt.Errorf("%s: %w", op, errs.ErrPaymentNotFound)
+	}
+
+	if p.Status != models.PaymentStatusSucceeded {
+		return models.Refund{}, fmt.Errorf("%s: %w: %w: payment is not succeeded", op, errs.ErrRefundPayment, errs.ErrPaymentRejected)
+	}
+
+	if !price.IsPositive() || p.Price.Less(p.refunded.Add(price)) {
+		return models.Refund{}, fmt.Errorf("%s: %w: %w: refund is greater than payment", op, errs.ErrRefundPayment, errs.ErrPaymentRejected)
+	}
+
+	p.refunded = p.refunded.Add(price)
+
+	refund := models.Refund{
+		ID:        uuid.NewString(),
+		PaymentID: paymentId,
+		Status:    models.RefundStatusSucceeded,
+	}
+	s.refunds[refundId] = refund
+
+	return refund, nil
+}
+
+// RefundById returns refund by id given by Refund
+func (s *Sandbox) RefundById(ctx context.Context, id string) (models.Refund, error) {
+	const op = "lib.payment.sandbox.RefundById"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range s.refunds {
+		if v.ID == id {
+			return v, nil
+		}
+	}
+
+	return models.Refund{}, fmt.Errorf("%s: %w", op, errs.ErrPaymentNotFound)
+}
+
+func (s *Sandbox) settle(id string) {
+	select {
+	case <-s.ctx.Done():
+		return
+	case <-time.After(s.confirmDelay):
+	}
+
+	s.mu.Lock()
+	p := s.payments[id]
+	if p.Status != models.PaymentStatusPending {
+		s.mu.Unlock()
+		return
+	}
+
+	p.Status = models.PaymentStatusWaitingForCapture
+	if !p.Price.Less(s.declineFrom) {
+		p.Status = models.PaymentStatusCanceled
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.ctx.Done():
+	case s.notifications <- id:
+	}
+}
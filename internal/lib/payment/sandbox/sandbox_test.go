@@ -0,0 +1,145 @@
+package sandbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandbox_CreatePayment(t *testing.T) {
+	tests := []struct {
+		name  string
+		price money.Money
+		want  models.PaymentStatus
+	}{
+		{
+			name:  "confirmed case",
+			price: money.Rub(99999),
+			want:  models.PaymentStatusWaitingForCapture,
+		},
+		{
+			name:  "declined case",
+			price: money.Rub(100000),
+			want:  models.PaymentStatusCanceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			s := New(ctx, money.Rub(100000), 0, "http://localhost/return")
+
+			orderId := uuid.New()
+			payment, err := s.CreatePayment(ctx, orderId, tt.price)
+			require.NoError(t, err)
+			require.Equal(t, models.PaymentStatusPending, payment.Status)
+			require.Equal(t, orderId, payment.OrderID)
+			require.Equal(t, tt.price, payment.Price)
+			require.Equal(t, "http://localhost/return", payment.ConfirmationUrl)
+
+			again, err := s.CreatePayment(ctx, orderId, tt.price)
+			require.NoError(t, err)
+			require.Equal(t, payment.ID, again.ID)
+
+			select {
+			case id := <-s.Notifications():
+				require.Equal(t, payment.ID, id)
+			case <-time.After(time.Second):
+				t.Fatal("notification wasn't sent")
+			}
+
+			payment, err = s.Payment(ctx, payment.ID)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, payment.Status)
+		})
+	}
+}
+
+func TestSandbox_Capture(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := New(ctx, money.Rub(100000), 0, "")
+
+	payment, err := s.CreatePayment(ctx, uuid.New(), money.Rub(10000))
+	require.NoError(t, err)
+	<-s.Notifications()
+
+	payment, err = s.Capture(ctx, payment.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.PaymentStatusSucceeded, payment.Status)
+
+	// capture of captured payment does nothing
+	payment, err = s.Capture(ctx, payment.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.PaymentStatusSucceeded, payment.Status)
+
+	_, err = s.Cancel(ctx, payment.ID)
+	require.ErrorIs(t, err, errs.ErrCancelPayment)
+}
+
+func TestSandbox_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := New(ctx, money.Rub(100000), time.Hour, "")
+
+	payment, err := s.CreatePayment(ctx, uuid.New(), money.Rub(10000))
+	require.NoError(t, err)
+
+	payment, err = s.Cancel(ctx, payment.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.PaymentStatusCanceled, payment.Status)
+
+	_, err = s.Cancel(ctx, uuid.NewString())
+	require.ErrorIs(t, err, errs.ErrPaymentNotFound)
+}
+
+func TestSandbox_Refund(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := New(ctx, money.Rub(100000), 0, "")
+
+	payment, err := s.CreatePayment(ctx, uuid.New(), money.Rub(10000))
+	require.NoError(t, err)
+	<-s.Notifications()
+
+	_, err = s.Refund(ctx, uuid.New(), payment.ID, money.Rub(6000))
+	require.ErrorIs(t, err, errs.ErrRefundPayment)
+
+	_, err = s.Capture(ctx, payment.ID)
+	require.NoError(t, err)
+
+	refundId := uuid.New()
+	refund, err := s.Refund(ctx, refundId, payment.ID, money.Rub(6000))
+	require.NoError(t, err)
+	require.Equal(t, models.RefundStatusSucceeded, refund.Status)
+	require.Equal(t, payment.ID, refund.PaymentID)
+
+	// retry with the same id returns the same refund
+	again, err := s.Refund(ctx, refundId, payment.ID, money.Rub(6000))
+	require.NoError(t, err)
+	require.Equal(t, refund.ID, again.ID)
+
+	found, err := s.RefundById(ctx, refund.ID)
+	require.NoError(t, err)
+	require.Equal(t, refund, found)
+
+	_, err = s.RefundById(ctx, "unknown")
+	require.ErrorIs(t, err, errs.ErrPaymentNotFound)
+
+	_, err = s.Refund(ctx, uuid.New(), payment.ID, money.Rub(5000))
+	require.ErrorIs(t, err, errs.ErrRefundPayment)
+	require.ErrorIs(t, err, errs.ErrPaymentRejected)
+
+	_, err = s.Refund(ctx, uuid.New(), payment.ID, money.Rub(4000))
+	require.NoError(t, err)
+}
@@ -1,34 +1,61 @@
 package yookassa
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/AlexMickh/shop-backend/internal/errs"
-	"github.com/rvinnie/yookassa-sdk-go/yookassa"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/google/uuid"
 	yoocommon "github.com/rvinnie/yookassa-sdk-go/yookassa/common"
+	yooerror "github.com/rvinnie/yookassa-sdk-go/yookassa/errors"
 	yoopayment "github.com/rvinnie/yookassa-sdk-go/yookassa/payment"
+	yoorefund "github.com/rvinnie/yookassa-sdk-go/yookassa/refund"
 )
 
+const DefaultApiUrl = "https://api.yookassa.ru/v3/"
+
+// YookassaPayment talks to yookassa api directly instead of sdk client,
+// because sdk has hardcoded api url and can't be pointed to the fake server in tests.
+// Request and response bodies are still sdk types.
 type YookassaPayment struct {
-	paymentHandler *yookassa.PaymentHandler
-	redirectUrl    string
+	client      *http.Client
+	apiUrl      string
+	shopId      string
+	secretKey   string
+	redirectUrl string
 }
 
-func New(paymentHandler *yookassa.PaymentHandler, redirectUrl string) *YookassaPayment {
+func New(shopId, secretKey, apiUrl, redirectUrl string) *YookassaPayment {
 	return &YookassaPayment{
-		paymentHandler: paymentHandler,
-		redirectUrl:    redirectUrl,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		apiUrl:      apiUrl,
+		shopId:      shopId,
+		secretKey:   secretKey,
+		redirectUrl: redirectUrl,
 	}
 }
 
-func (y *YookassaPayment) CreatePayment(userId int64, price float32) (string, error) {
+func (y *YookassaPayment) CreatePayment(ctx context.Context, orderId uuid.UUID, price money.Money) (models.Payment, error) {
 	const op = "lib.payment.yookassa.CreatePayment"
 
-	payment, err := y.paymentHandler.CreatePayment(&yoopayment.Payment{
+	var payment yoopayment.Payment
+	err := y.do(ctx, http.MethodPost, "payments", orderId.String(), &yoopayment.Payment{
 		Amount: &yoocommon.Amount{
-			Value:    fmt.Sprint(price),
-			Currency: "RUB",
+			Value:    price.Decimal(),
+			Currency: string(price.Currency),
 		},
+		// money is only held until order is checked and captured, held payment can be canceled
+		Capture:       false,
 		PaymentMethod: yoopayment.PaymentTypeBankCard,
 		Confirmation: yoopayment.Redirect{
 			Type:      "redirect",
@@ -36,12 +63,180 @@ func (y *YookassaPayment) CreatePayment(userId int64, price float32) (string, er
 		},
 		Description: "Оплата в магазине 3",
 		Metadata: map[string]any{
-			"user_id": userId, //! this may make bugs, if user creates 2 orders in short time delta
+			"order_id": orderId.String(),
 		},
-	})
+	}, &payment)
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("%s: %w: %w", op, errs.ErrCreatePayment, err)
+	}
+
+	return toModel(&payment)
+}
+
+// Payment fetches actual payment state from yookassa. Id comes from notification body,
+// so it's escaped and can't point request to another endpoint
+func (y *YookassaPayment) Payment(ctx context.Context, id string) (models.Payment, error) {
+	const op = "lib.payment.yookassa.Payment"
+
+	var payment yoopayment.Payment
+	err := y.do(ctx, http.MethodGet, "payments/"+url.PathEscape(id), "", nil, &payment)
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return toModel(&payment)
+}
+
+// Cancel cancels payment, yookassa allows it only for payments in waiting_for_capture
+func (y *YookassaPayment) Cancel(ctx context.Context, id string) (models.Payment, error) {
+	const op = "lib.payment.yookassa.Cancel"
+
+	var payment yoopayment.Payment
+	err := y.do(ctx, http.MethodPost, "payments/"+url.PathEscape(id)+"/cancel", "cancel-"+id, struct{}{}, &payment)
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("%s: %w: %w", op, errs.ErrCancelPayment, err)
+	}
+
+	return toModel(&payment)
+}
+
+// Capture takes money held by payment in waiting_for_capture
+func (y *YookassaPayment) Capture(ctx context.Context, id string) (models.Payment, error) {
+	const op = "lib.payment.yookassa.Capture"
+
+	var payment yoopayment.Payment
+	err := y.do(ctx, http.MethodPost, "payments/"+url.PathEscape(id)+"/capture", "capture-"+id, struct{}{}, &payment)
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("%s: %w: %w", op, errs.ErrCapturePayment, err)
+	}
+
+	return toModel(&payment)
+}
+
+// Refund returns price to the buyer, refundId is used as idempotence key
+func (y *YookassaPayment) Refund(ctx context.Context, refundId uuid.UUID, paymentId string, price money.Money) (models.Refund, error) {
+	const op = "lib.payment.yookassa.Refund"
+
+	var refund yoorefund.Refund
+	err := y.do(ctx, http.MethodPost, "refunds", refundId.String(), &yoorefund.Refund{
+		PaymentId: paymentId,
+		Amount: &yoocommon.Amount{
+			Value:    price.Decimal(),
+			Currency: string(price.Currency),
+		},
+	}, &refund)
+	if err != nil {
+		return models.Refund{}, fmt.Errorf("%s: %w: %w", op, errs.ErrRefundPayment, err)
+	}
+
+	return models.Refund{
+		ID:        refund.Id,
+		PaymentID: refund.PaymentId,
+		Status:    models.RefundStatus(refund.Status),
+	}, nil
+}
+
+// RefundById fetches actual refund state, id is the one returned by Refund
+func (y *YookassaPayment) RefundById(ctx context.Context, id string) (models.Refund, error) {
+	const op = "lib.payment.yookassa.RefundById"
+
+	var refund yoorefund.Refund
+	err := y.do(ctx, http.MethodGet, "refunds/"+url.PathEscape(id), "", nil, &refund)
+	if err != nil {
+		return models.Refund{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return models.Refund{
+		ID:        refund.Id,
+		PaymentID: refund.PaymentId,
+		Status:    models.RefundStatus(refund.Status),
+	}, nil
+}
+
+func (y *YookassaPayment) do(ctx context.Context, method, endpoint, idempotenceKey string, body any, out any) error {
+	const op = "lib.payment.yookassa.do"
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, y.apiUrl+endpoint, reqBody)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, errs.ErrCreatePayment)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	req.SetBasicAuth(y.shopId, y.secretKey)
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+		// yookassa returns the same object for the same key, so retry can't create second payment
+		req.Header.Set("Idempotence-Key", idempotenceKey)
+	}
+
+	resp, err := y.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", op, errs.ErrPaymentNotFound)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		respErr, err := yooerror.GetError(resp.Body)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// request with 4xx answer isn't done, with 5xx answer it could be done
+		if resp.StatusCode < http.StatusInternalServerError {
+			return fmt.Errorf("%s: %w: %w", op, errs.ErrPaymentRejected, respErr)
+		}
+
+		return fmt.Errorf("%s: %w", op, respErr)
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func toModel(payment *yoopayment.Payment) (models.Payment, error) {
+	const op = "lib.payment.yookassa.toModel"
+
+	metadata, _ := payment.Metadata.(map[string]any)
+	orderId, _ := metadata["order_id"].(string)
+
+	id, err := uuid.Parse(orderId)
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("%s: payment %s has no order id: %w", op, payment.ID, err)
+	}
+
+	var price money.Money
+	if payment.Amount != nil {
+		price, err = money.Parse(payment.Amount.Value, money.Currency(payment.Amount.Currency))
+		if err != nil {
+			return models.Payment{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	var confirmationUrl string
+	if confirmation, ok := payment.Confirmation.(map[string]any); ok {
+		confirmationUrl, _ = confirmation["confirmation_url"].(string)
 	}
 
-	return payment.Confirmation.(map[string]any)["confirmation_url"].(string), nil
+	return models.Payment{
+		ID:              payment.ID,
+		OrderID:         id,
+		Status:          models.PaymentStatus(payment.Status),
+		Price:           price,
+		ConfirmationUrl: confirmationUrl,
+	}, nil
 }
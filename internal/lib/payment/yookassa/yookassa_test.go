@@ -0,0 +1,164 @@
+package yookassa
+
+import (
+	"context"
+	"testing"
+
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/lib/payment/yookassa/yookassatest"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/google/uuid"
+	yoopayment "github.com/rvinnie/yookassa-sdk-go/yookassa/payment"
+	"github.com/stretchr/testify/require"
+)
+
+func TestYookassaPayment_CreatePayment(t *testing.T) {
+	srv := yookassatest.NewServer()
+	defer srv.Close()
+
+	y := New("shop", "secret", srv.ApiUrl(), "http://localhost/return")
+	ctx := context.Background()
+
+	orderId := uuid.New()
+
+	payment, err := y.CreatePayment(ctx, orderId, money.Rub(150050))
+	require.NoError(t, err)
+	require.NotEmpty(t, payment.ID)
+	require.Equal(t, orderId, payment.OrderID)
+	require.Equal(t, models.PaymentStatusPending, payment.Status)
+	require.Equal(t, money.Rub(150050), payment.Price)
+	require.NotEmpty(t, payment.ConfirmationUrl)
+
+	stored, ok := srv.Payment(payment.ID)
+	require.True(t, ok)
+	require.Equal(t, "1500.50", stored.Amount.Value)
+	require.Equal(t, "RUB", stored.Amount.Currency)
+	require.False(t, stored.Capture)
+
+	// retry for the same order must not create second payment
+	again, err := y.CreatePayment(ctx, orderId, money.Rub(150050))
+	require.NoError(t, err)
+	require.Equal(t, payment.ID, again.ID)
+}
+
+func TestYookassaPayment_Payment(t *testing.T) {
+	srv := yookassatest.NewServer()
+	defer srv.Close()
+
+	y := New("shop", "secret", srv.ApiUrl(), "http://localhost/return")
+	ctx := context.Background()
+
+	orderId := uuid.New()
+	created, err := y.CreatePayment(ctx, orderId, money.Rub(10000))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		id      string
+		status  yoopayment.Status
+		want    models.PaymentStatus
+		wantErr error
+	}{
+		{
+			name:   "succeeded case",
+			id:     created.ID,
+			status: yoopayment.Succeeded,
+			want:   models.PaymentStatusSucceeded,
+		},
+		{
+			name:   "canceled case",
+			id:     created.ID,
+			status: yoopayment.Canceled,
+			want:   models.PaymentStatusCanceled,
+		},
+		{
+			name:    "not found case",
+			id:      uuid.NewString(),
+			wantErr: errs.ErrPaymentNotFound,
+		},
+		{
+			name:    "escaped id case",
+			id:      "../payments?limit=1",
+			wantErr: errs.ErrPaymentNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr == nil {
+				require.NoError(t, srv.SetStatus(tt.id, tt.status))
+			}
+
+			payment, err := y.Payment(ctx, tt.id)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, payment.Status)
+			require.Equal(t, orderId, payment.OrderID)
+		})
+	}
+}
+
+func TestYookassaPayment_Capture(t *testing.T) {
+	srv := yookassatest.NewServer()
+	defer srv.Close()
+
+	y := New("shop", "secret", srv.ApiUrl(), "http://localhost/return")
+	ctx := context.Background()
+
+	created, err := y.CreatePayment(ctx, uuid.New(), money.Rub(10000))
+	require.NoError(t, err)
+
+	// buyer hasn't paid yet
+	_, err = y.Capture(ctx, created.ID)
+	require.ErrorIs(t, err, errs.ErrCapturePayment)
+
+	require.NoError(t, srv.SetStatus(created.ID, yoopayment.WaitingForCapture))
+
+	payment, err := y.Capture(ctx, created.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.PaymentStatusSucceeded, payment.Status)
+
+	payment, err = y.Capture(ctx, created.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.PaymentStatusSucceeded, payment.Status)
+}
+
+func TestYookassaPayment_Refund(t *testing.T) {
+	srv := yookassatest.NewServer()
+	defer srv.Close()
+
+	y := New("shop", "secret", srv.ApiUrl(), "http://localhost/return")
+	ctx := context.Background()
+
+	payment, err := y.CreatePayment(ctx, uuid.New(), money.Rub(10000))
+	require.NoError(t, err)
+
+	_, err = y.Refund(ctx, uuid.New(), payment.ID, money.Rub(10000))
+	require.ErrorIs(t, err, errs.ErrRefundPayment)
+	require.ErrorIs(t, err, errs.ErrPaymentRejected)
+
+	require.NoError(t, srv.SetStatus(payment.ID, yoopayment.Succeeded))
+
+	refundId := uuid.New()
+	refund, err := y.Refund(ctx, refundId, payment.ID, money.Rub(7000))
+	require.NoError(t, err)
+	require.Equal(t, models.RefundStatusSucceeded, refund.Status)
+
+	again, err := y.Refund(ctx, refundId, payment.ID, money.Rub(7000))
+	require.NoError(t, err)
+	require.Equal(t, refund.ID, again.ID)
+
+	found, err := y.RefundById(ctx, refund.ID)
+	require.NoError(t, err)
+	require.Equal(t, refund, found)
+
+	_, err = y.RefundById(ctx, "../payments")
+	require.ErrorIs(t, err, errs.ErrPaymentNotFound)
+
+	_, err = y.Refund(ctx, uuid.New(), payment.ID, money.Rub(7000))
+	require.ErrorIs(t, err, errs.ErrRefundPayment)
+}
@@ -0,0 +1,305 @@
+// Package yookassatest provides in-memory yookassa api for tests.
+package yookassatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/google/uuid"
+	yoocommon "github.com/rvinnie/yookassa-sdk-go/yookassa/common"
+	yoopayment "github.com/rvinnie/yookassa-sdk-go/yookassa/payment"
+	yoorefund "github.com/rvinnie/yookassa-sdk-go/yookassa/refund"
+	yoowebhook "github.com/rvinnie/yookassa-sdk-go/yookassa/webhook"
+)
+
+const ConfirmationUrl = "https://yoomoney.ru/checkout/payments/v2/contract"
+
+// Server is a fake yookassa, pass Server.ApiUrl() to yookassa.New
+type Server struct {
+	srv      *httptest.Server
+	mu       sync.Mutex
+	payments map[string]*yoopayment.Payment
+	refunds  map[string]*yoorefund.Refund
+	// idempotence key -> payment or refund id
+	keys map[string]string
+}
+
+func NewServer() *Server {
+	s := &Server{
+		payments: make(map[string]*yoopayment.Payment),
+		refunds:  make(map[string]*yoorefund.Refund),
+		keys:     make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /payments", s.createPayment)
+	mux.HandleFunc("GET /payments/{id}", s.payment)
+	mux.HandleFunc("POST /payments/{id}/capture", s.capturePayment)
+	mux.HandleFunc("POST /payments/{id}/cancel", s.cancelPayment)
+	mux.HandleFunc("POST /refunds", s.createRefund)
+	mux.HandleFunc("GET /refunds/{id}", s.refund)
+
+	s.srv = httptest.NewServer(withBasicAuth(mux))
+
+	return s
+}
+
+func (s *Server) ApiUrl() string {
+	return s.srv.URL + "/"
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Payment returns copy of stored payment
+func (s *Server) Payment(id string) (yoopayment.Payment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, ok := s.payments[id]
+	if !ok {
+		return yoopayment.Payment{}, false
+	}
+
+	return *payment, true
+}
+
+// SetStatus imitates user actions on yookassa side
+func (s *Server) SetStatus(id string, status yoopayment.Status) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, ok := s.payments[id]
+	if !ok {
+		return fmt.Errorf("payment %s not found", id)
+	}
+
+	payment.Status = status
+	payment.Paid = status == yoopayment.WaitingForCapture || status == yoopayment.Succeeded
+
+	return nil
+}
+
+// Notify sends webhook notification with current payment state to webhookUrl
+func (s *Server) Notify(webhookUrl string, id string, event yoowebhook.WebhookEventType) (*http.Response, error) {
+	payment, ok := s.Payment(id)
+	if !ok {
+		return nil, fmt.Errorf("payment %s not found", id)
+	}
+
+	body, err := json.Marshal(yoowebhook.WebhookEvent[yoopayment.Payment]{
+		Type:   yoowebhook.WebhookTypeNotification,
+		Event:  event,
+		Object: payment,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return http.Post(webhookUrl, "application/json", bytes.NewReader(body))
+}
+
+func (s *Server) createPayment(w http.ResponseWriter, r *http.Request) {
+	var payment yoopayment.Payment
+	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	if payment.Amount == nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "amount is required")
+		return
+	}
+
+	// yookassa accepts only exact decimal amounts
+	if _, err := money.Parse(payment.Amount.Value, money.Currency(payment.Amount.Currency)); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	key := r.Header.Get("Idempotence-Key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Idempotence-Key is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.keys[key]; ok {
+		writeJSON(w, s.payments[id])
+		return
+	}
+
+	payment.ID = uuid.NewString()
+	payment.Status = yoopayment.Pending
+	payment.Confirmation = yoopayment.Redirect{
+		Type:            yoopayment.TypeRedirect,
+		ConfirmationURL: ConfirmationUrl + "?orderId=" + payment.ID,
+	}
+
+	s.payments[payment.ID] = &payment
+	s.keys[key] = payment.ID
+
+	writeJSON(w, &payment)
+}
+
+func (s *Server) payment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, ok := s.payments[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "payment not found")
+		return
+	}
+
+	writeJSON(w, payment)
+}
+
+func (s *Server) capturePayment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, ok := s.payments[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "payment not found")
+		return
+	}
+
+	if payment.Status != yoopayment.WaitingForCapture && payment.Status != yoopayment.Succeeded {
+		writeError(w, http.StatusBadRequest, "invalid_request", "payment can't be captured in status "+string(payment.Status))
+		return
+	}
+
+	payment.Status = yoopayment.Succeeded
+	payment.Paid = true
+
+	writeJSON(w, payment)
+}
+
+func (s *Server) cancelPayment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, ok := s.payments[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "payment not found")
+		return
+	}
+
+	if payment.Status != yoopayment.WaitingForCapture && payment.Status != yoopayment.Canceled {
+		writeError(w, http.StatusBadRequest, "invalid_request", "payment can't be canceled in status "+string(payment.Status))
+		return
+	}
+
+	payment.Status = yoopayment.Canceled
+
+	writeJSON(w, payment)
+}
+
+func (s *Server) createRefund(w http.ResponseWriter, r *http.Request) {
+	var refund yoorefund.Refund
+	if err := json.NewDecoder(r.Body).Decode(&refund); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	key := r.Header.Get("Idempotence-Key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Idempotence-Key is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.keys[key]; ok {
+		writeJSON(w, s.refunds[id])
+		return
+	}
+
+	payment, ok := s.payments[refund.PaymentId]
+	if !ok || payment.Status != yoopayment.Succeeded {
+		writeError(w, http.StatusBadRequest, "invalid_request", "only succeeded payment can be refunded")
+		return
+	}
+
+	if refund.Amount == nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "amount is required")
+		return
+	}
+
+	refunded := amount(refund.Amount).Add(amount(payment.RefundedAmount))
+	if amount(payment.Amount).Less(refunded) {
+		writeError(w, http.StatusBadRequest, "invalid_request", "refund amount is greater than payment amount")
+		return
+	}
+
+	payment.RefundedAmount = &yoocommon.Amount{
+		Value:    refunded.Decimal(),
+		Currency: string(refunded.Currency),
+	}
+
+	refund.Id = uuid.NewString()
+	refund.Status = yoorefund.Succeeded
+
+	s.refunds[refund.Id] = &refund
+	s.keys[key] = refund.Id
+
+	writeJSON(w, &refund)
+}
+
+func (s *Server) refund(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refund, ok := s.refunds[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "refund not found")
+		return
+	}
+
+	writeJSON(w, refund)
+}
+
+func amount(a *yoocommon.Amount) money.Money {
+	if a == nil {
+		return money.Money{}
+	}
+
+	m, _ := money.Parse(a.Value, money.Currency(a.Currency))
+	return m
+}
+
+func withBasicAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); !ok {
+			writeError(w, http.StatusUnauthorized, "invalid_credentials", "basic auth is required")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"type":        "error",
+		"code":        code,
+		"description": description,
+	})
+}
@@ -3,20 +3,66 @@ package models
 import (
 	"time"
 
+	"github.com/AlexMickh/shop-backend/pkg/money"
 	"github.com/google/uuid"
 )
 
+// CartItem is a line of cart, one per product size
 type CartItem struct {
 	ID                uuid.UUID
+	ProductID         uuid.UUID
+	VariantID         uuid.UUID
+	CategoryID        uuid.UUID
 	Name              string
-	Price             int
+	Price             money.Money
 	ImageUrl          string
 	Discount          int
+	DiscountStartsAt  *time.Time
 	DiscountExpiresAt *time.Time
+	Size              ProductSize
 	Quantity          int
+	// price and discount when product was added or changes were acknowledged
+	PriceSnapshot    money.Money
+	DiscountSnapshot int
+	// pieces of size in stock now
+	Stock int
+	// set by cart service if line differs from the snapshot
+	PriceChanged bool
+	StockChanged bool
+	// part of coupon discount that falls on the line
+	CouponDiscount money.Money
+}
+
+// UnitPrice is price of one piece with discount
+func (i CartItem) UnitPrice() money.Money {
+	return i.Price.ApplyDiscount(i.Discount)
+}
+
+// SnapshotUnitPrice is price of one piece user saw when added it
+func (i CartItem) SnapshotUnitPrice() money.Money {
+	return i.PriceSnapshot.ApplyDiscount(i.DiscountSnapshot)
 }
 
 type Cart struct {
 	Products []*CartItem
-	Price    int
+	// sum of lines before coupon
+	Subtotal money.Money
+	Coupon   *Coupon
+	// set if applied coupon can't be used anymore, its discount isn't counted then
+	CouponError    error
+	CouponDiscount money.Money
+	// price to pay
+	Price money.Money
+	// some lines have changed price or not enough stock, cart can't be bought until they're acknowledged
+	HasChanges bool
+}
+
+// CartOwner is a user or a guest without account, only one of ids is set
+type CartOwner struct {
+	UserID  uuid.UUID
+	GuestID uuid.UUID
+}
+
+func (o CartOwner) IsGuest() bool {
+	return o.UserID == uuid.Nil
 }
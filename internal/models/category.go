@@ -5,6 +5,13 @@ import (
 )
 
 type Category struct {
-	ID   uuid.UUID
-	Name string
+	ID           uuid.UUID
+	ParentID     *uuid.UUID // nil for top level category
+	Name         string
+	Slug         string // unique, used in urls
+	Description  string
+	ImageUrl     string
+	Position     int // order among children of one parent
+	ProductCount int // products of category and its subcategories, it's counted only for single category
+	Children     []Category
 }
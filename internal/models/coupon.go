@@ -0,0 +1,57 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+type CouponType string
+
+const (
+	CouponTypePercent CouponType = "percent"
+	CouponTypeFixed   CouponType = "fixed"
+)
+
+type Coupon struct {
+	ID   uuid.UUID
+	Code string
+	Type CouponType
+	// percent for percent coupons, kopecks for fixed ones
+	Value         int
+	MinOrderPrice money.Money
+	// coupon covers only these products and products of these categories,
+	// if both are empty it covers the whole cart
+	ProductIDs  []uuid.UUID
+	CategoryIDs []uuid.UUID
+	// nil means unlimited
+	UsageLimit        *int
+	UsageLimitPerUser *int
+	StartsAt          *time.Time
+	ExpiresAt         *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         *time.Time
+}
+
+// IsActive reports if coupon can be used at now
+func (c Coupon) IsActive(now time.Time) bool {
+	if c.StartsAt != nil && c.StartsAt.After(now) {
+		return false
+	}
+	if c.ExpiresAt != nil && !c.ExpiresAt.After(now) {
+		return false
+	}
+
+	return true
+}
+
+// Covers reports if coupon discount is applied to the cart item
+func (c Coupon) Covers(item *CartItem) bool {
+	if len(c.ProductIDs) == 0 && len(c.CategoryIDs) == 0 {
+		return true
+	}
+
+	return slices.Contains(c.ProductIDs, item.ProductID) || slices.Contains(c.CategoryIDs, item.CategoryID)
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// StoredFile is file in file storage, it's named by hash of its content
+type StoredFile struct {
+	Name       string
+	Url        string
+	ModifiedAt time.Time
+}
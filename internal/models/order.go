@@ -0,0 +1,82 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+type OrderStatus string
+
+const (
+	OrderStatusPendingPayment OrderStatus = "pending_payment"
+	OrderStatusPaid           OrderStatus = "paid"
+	OrderStatusShipped        OrderStatus = "shipped"
+	OrderStatusDelivered      OrderStatus = "delivered"
+	OrderStatusCancelled      OrderStatus = "cancelled"
+	// set only by refunds, not by admin directly
+	OrderStatusPartiallyRefunded OrderStatus = "partially_refunded"
+	OrderStatusRefunded          OrderStatus = "refunded"
+)
+
+// statuses order can be moved to from the current one. Paid order isn't cancelled directly,
+// it's reversed by refund, so money and stock are returned.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPendingPayment:    {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:              {OrderStatusShipped, OrderStatusPartiallyRefunded, OrderStatusRefunded},
+	OrderStatusShipped:           {OrderStatusDelivered, OrderStatusPartiallyRefunded, OrderStatusRefunded},
+	OrderStatusDelivered:         {OrderStatusPartiallyRefunded, OrderStatusRefunded},
+	OrderStatusPartiallyRefunded: {OrderStatusPartiallyRefunded, OrderStatusRefunded},
+}
+
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	return slices.Contains(orderTransitions[s], next)
+}
+
+type OrderItem struct {
+	ID        uuid.UUID
+	ProductID uuid.UUID
+	// nil for items bought before sizes had own stock or if variant is deleted
+	VariantID *uuid.UUID
+	Name      string
+	Price     money.Money
+	Discount  int
+	Size      *ProductSize
+	Quantity  int
+	// how many pieces of Quantity are already refunded
+	RefundedQuantity int
+	// part of order coupon discount that falls on all pieces of the item
+	CouponDiscount money.Money
+}
+
+// UnitPrice is price of one piece with discount
+func (i OrderItem) UnitPrice() money.Money {
+	return i.Price.ApplyDiscount(i.Discount)
+}
+
+// RefundPrice is price paid for the next quantity of not refunded pieces.
+// Coupon discount is split between pieces so that after all of them are refunded
+// exactly the paid sum is returned.
+func (i OrderItem) RefundPrice(quantity int) money.Money {
+	discount := i.CouponDiscount
+	discount.Amount = i.CouponDiscount.Amount*int64(i.RefundedQuantity+quantity)/int64(i.Quantity) -
+		i.CouponDiscount.Amount*int64(i.RefundedQuantity)/int64(i.Quantity)
+
+	return i.UnitPrice().Mul(quantity).Sub(discount)
+}
+
+type Order struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Status    OrderStatus
+	Price     money.Money
+	PaymentID *string
+	CouponID  *uuid.UUID
+	// already subtracted from Price
+	CouponDiscount money.Money
+	Items          []OrderItem
+	CreatedAt      time.Time
+	UpdatedAt      *time.Time
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+type OrderRefundItem struct {
+	ID          uuid.UUID
+	OrderItemID uuid.UUID
+	Quantity    int
+	Price       money.Money
+}
+
+// OrderRefund is a history record of money given back for the order
+type OrderRefund struct {
+	ID              uuid.UUID
+	OrderID         uuid.UUID
+	PaymentRefundID *string
+	Status          RefundStatus
+	Price           money.Money
+	Reason          string
+	Items           []OrderRefundItem
+	CreatedAt       time.Time
+	UpdatedAt       *time.Time
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/google/uuid"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending           PaymentStatus = "pending"
+	PaymentStatusWaitingForCapture PaymentStatus = "waiting_for_capture"
+	PaymentStatusSucceeded         PaymentStatus = "succeeded"
+	PaymentStatusCanceled          PaymentStatus = "canceled"
+)
+
+type Payment struct {
+	ID              string
+	OrderID         uuid.UUID
+	Status          PaymentStatus
+	Price           money.Money
+	ConfirmationUrl string
+}
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusCanceled  RefundStatus = "canceled"
+)
+
+type Refund struct {
+	ID        string
+	PaymentID string
+	Status    RefundStatus
+}
@@ -1,8 +1,11 @@
 package models
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"time"
 
+	"github.com/AlexMickh/shop-backend/pkg/money"
 	"github.com/google/uuid"
 )
 
@@ -23,22 +26,202 @@ type Product struct {
 	Category          Category
 	Name              string
 	Description       string
-	Price             int
-	Quantity          int
-	ExistingSizes     []ProductSize
-	ImageUrl          string
+	Price             money.Money
+	Quantity          int           // sum of variants stock
+	ExistingSizes     []ProductSize // sizes with stock
+	Variants          []ProductVariant
+	ImageUrl          string         // url of main image
+	ThumbUrl          string         // url of main image thumbnail
+	Images            []ProductImage // ordered by position, the first is main
 	PeicesSold        int
 	Discount          int
+	DiscountStartsAt  *time.Time // discount isn't applied before it
 	DiscountExpiresAt *time.Time
+	LowestPrice       money.Money // lowest price with discounts over 30 days before current price was set
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
 }
 
+// EffectiveDiscount returns discount if it has started and isn't expired at now, zero otherwise.
+// Scheduler clears started and expired dates only periodically, so they're checked on every read.
+func EffectiveDiscount(discount int, startsAt, expiresAt *time.Time, now time.Time) int {
+	if startsAt != nil && startsAt.After(now) {
+		return 0
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		return 0
+	}
+
+	return discount
+}
+
 type ProductCard struct {
 	ID                uuid.UUID
+	CreatedAt         time.Time
 	Name              string
-	Price             int
-	ImageUrl          string
+	Price             money.Money
+	ImageUrl          string // thumbnail of main image
+	PiecesSold        int
 	Discount          int
+	DiscountStartsAt  *time.Time
 	DiscountExpiresAt *time.Time
+	SearchRank        int64  // how good product matches search, bigger is better
+	Snippet           string // part of description with found words, they're wrapped in <b>
+}
+
+type ProductSort string
+
+const (
+	SortNewest    ProductSort = "newest"
+	SortPopular   ProductSort = "popular"
+	SortPriceAsc  ProductSort = "price_asc"
+	SortPriceDesc ProductSort = "price_desc"
+	SortDiscount  ProductSort = "discount"  // biggest discount first
+	SortRelevance ProductSort = "relevance" // best match of search first
+)
+
+// Desc reports if products are sorted in descending order, id is sorted in the same order
+// to make it unique
+func (s ProductSort) Desc() bool {
+	return s != SortPriceAsc
+}
+
+// ProductFilter selects products of catalogue, zero fields aren't filtered
+type ProductFilter struct {
+	CategoryID uuid.UUID // postgres selects products of subcategories too, other backends only of this category
+	Search     string
+	MinPrice   money.Money // price with discount
+	MaxPrice   money.Money
+	Sizes      []ProductSize // product has at least one of sizes
+	InStock    bool          // if Sizes are set, one of them is in stock
+	OnSale     bool
+	Now        time.Time // discounts active at this time are used for price and sorting
+}
+
+// ProductCardsQuery describes one page of catalogue
+type ProductCardsQuery struct {
+	ProductFilter
+	Sort  ProductSort
+	Limit int
+	After *ProductCursor // first page if nil
+}
+
+// ProductCursor points to the last product of previous page. Key is value product
+// is sorted by, newest products are sorted by CreatedAt instead.
+type ProductCursor struct {
+	Sort      ProductSort `json:"s"`
+	Key       int64       `json:"k,omitempty"`
+	CreatedAt time.Time   `json:"t,omitzero"`
+	ID        uuid.UUID   `json:"id"`
+}
+
+// NewProductCursor returns cursor pointing to card, now must be the same as in query of the page
+func NewProductCursor(card ProductCard, sort ProductSort, now time.Time) ProductCursor {
+	cursor := ProductCursor{Sort: sort, ID: card.ID}
+
+	discount := EffectiveDiscount(card.Discount, card.DiscountStartsAt, card.DiscountExpiresAt, now)
+
+	switch sort {
+	case SortPopular:
+		cursor.Key = int64(card.PiecesSold)
+	case SortPriceAsc, SortPriceDesc:
+		cursor.Key = card.Price.ApplyDiscount(discount).Amount
+	case SortDiscount:
+		cursor.Key = int64(discount)
+	case SortRelevance:
+		// backends without ranking page relevance by CreatedAt
+		cursor.Key = card.SearchRank
+		cursor.CreatedAt = card.CreatedAt
+	default:
+		cursor.CreatedAt = card.CreatedAt
+	}
+
+	return cursor
+}
+
+// Encode returns opaque cursor string for client
+func (c ProductCursor) Encode() string {
+	b, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeProductCursor parses cursor got from Encode
+func DecodeProductCursor(cursor string) (*ProductCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(ProductCursor)
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+type ProductCardsPage struct {
+	Cards   []ProductCard
+	HasMore bool
+	Next    *ProductCursor // set if HasMore
+	Facets  *ProductFacets // only first page has them
+}
+
+// ProductFacets counts products for every value of filter. Each filter is counted with
+// all other filters applied, but not with itself, so other values can be shown to choose from.
+type ProductFacets struct {
+	Sizes      []SizeFacet
+	Categories []CategoryFacet
+}
+
+type SizeFacet struct {
+	Size  ProductSize
+	Count int
+}
+
+type CategoryFacet struct {
+	Category Category
+	Count    int
+}
+
+// ScheduledPrice is price change planned in advance, scheduler applies it after StartsAt
+type ScheduledPrice struct {
+	ID        uuid.UUID
+	ProductID uuid.UUID
+	Price     money.Money
+	StartsAt  time.Time
+	AppliedAt *time.Time
+	CreatedAt time.Time
+}
+
+// ProductImage is one photo of product, images are shown in order of position
+type ProductImage struct {
+	ID        uuid.UUID
+	ProductID uuid.UUID
+	Url       string // full size
+	MediumUrl string
+	ThumbUrl  string
+	Position  int
+	CreatedAt time.Time
+}
+
+// ProductVariant is one size of product, stock is tracked per variant
+
+type ProductVariant struct {
+	ID        uuid.UUID
+	ProductID uuid.UUID
+	Size      ProductSize
+	Stock     int
+	Sku       *string
+	Barcode   *string
+	CreatedAt time.Time
+	UpdatedAt *time.Time
+}
+
+// StockReservation holds pieces of product variant for not paid order
+type StockReservation struct {
+	ProductID uuid.UUID
+	VariantID uuid.UUID
+	Quantity  int
 }
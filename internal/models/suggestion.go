@@ -0,0 +1,14 @@
+package models
+
+import "github.com/google/uuid"
+
+// Suggestion is product or category which name starts with typed words
+type Suggestion struct {
+	ID   uuid.UUID
+	Name string
+}
+
+type Suggestions struct {
+	Products   []Suggestion
+	Categories []Suggestion
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/AlexMickh/shop-backend/internal/errs"
 	"github.com/AlexMickh/shop-backend/internal/models"
@@ -101,3 +102,76 @@ func (c *CategoryRepository) AllCategories(ctx context.Context) ([]models.Catego
 
 	return categories, nil
 }
+
+// CategoryById returns category with count of its products, mongo categories don't have subcategories
+func (c *CategoryRepository) CategoryById(ctx context.Context, id bson.ObjectID) (*models.Category, error) {
+	const op = "repository.mongo.category.CategoryById"
+
+	var category models.Category
+	err := c.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&category)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("%s: %w", op, errs.ErrCategoryNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	count, err := c.collection.Database().Collection("products").CountDocuments(ctx, bson.M{"category_id": id})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	category.ProductCount = int(count)
+
+	return &category, nil
+}
+
+// UpdateCategory saves not empty fields of category, position -1 means not changed
+func (c *CategoryRepository) UpdateCategory(ctx context.Context, id bson.ObjectID, category *models.Category) error {
+	const op = "repository.mongo.category.UpdateCategory"
+
+	// categories are inserted as structs, so keys are lower case field names
+	update := bson.M{}
+
+	if category.Name != "" {
+		update["name"] = category.Name
+	}
+
+	if category.Slug != "" {
+		update["slug"] = category.Slug
+	}
+
+	if category.Description != "" {
+		update["description"] = category.Description
+	}
+
+	if category.ImageUrl != "" {
+		update["imageurl"] = category.ImageUrl
+	}
+
+	if category.Position != -1 {
+		update["position"] = category.Position
+	}
+
+	update["updatedat"] = time.Now()
+
+	result, err := c.collection.UpdateByID(ctx, id, bson.M{"$set": update})
+	if err != nil {
+		var mongoErr mongo.WriteException
+		if errors.As(err, &mongoErr) {
+			for _, writeErr := range mongoErr.WriteErrors {
+				if writeErr.Code == 11000 {
+					return fmt.Errorf("%s: %w", op, errs.ErrCategoryAlreadyExists)
+				}
+			}
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s: %w", op, errs.ErrCategoryNotFound)
+	}
+
+	return nil
+}
@@ -8,9 +8,9 @@ import (
 
 	"github.com/AlexMickh/shop-backend/internal/errs"
 	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
-	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type ProductRepository struct {
@@ -95,77 +95,170 @@ func (p *ProductRepository) ProductById(ctx context.Context, id bson.ObjectID) (
 	return product, nil
 }
 
-func (p *ProductRepository) ProductCards(
-	ctx context.Context,
-	page int,
-	popularity bool,
-	price int,
-	categoryId bson.ObjectID,
-	search string,
-) ([]models.ProductCard, error) {
+// ProductCards returns page of products in q.Sort order, page is empty if nothing is found
+func (p *ProductRepository) ProductCards(ctx context.Context, q models.ProductCardsQuery) (models.ProductCardsPage, error) {
 	const op = "repository.mongo.product.ProductCards"
 
+	// there is no ranking here, found products are shown from newest
+	if q.Sort == models.SortRelevance {
+		q.Sort = models.SortNewest
+	}
+
 	filter := bson.M{}
-	sort := bson.D{}
 
-	isCategoryIdSet := false
+	// products of subcategories aren't selected, categories here don't know their parents
+	if q.CategoryID != uuid.Nil {
+		categoryId, ok := objectId(q.CategoryID)
+		if !ok {
+			return models.ProductCardsPage{Cards: []models.ProductCard{}}, nil
+		}
 
-	if categoryId != bson.NilObjectID {
 		filter["category_id"] = categoryId
-		isCategoryIdSet = true
 	}
 
-	if search != "" {
-		if isCategoryIdSet {
-			delete(filter, "category_id")
-			filter["$set"] = bson.M{
-				"category_id": categoryId,
-				"$text":       bson.M{"$search": fmt.Sprintf(`"%s"`, search)},
-			}
-		} else {
-			filter["$text"] = bson.M{"$search": fmt.Sprintf(`"%s"`, search)}
+	if q.Search != "" {
+		filter["$text"] = bson.M{"$search": fmt.Sprintf(`"%s"`, q.Search)}
+	}
+
+	direction, cmp := 1, "$gt"
+	if q.Sort.Desc() {
+		direction, cmp = -1, "$lt"
+	}
+
+	if len(q.Sizes) != 0 {
+		filter["existing_sizes"] = bson.M{"$in": q.Sizes}
+	}
+
+	// stock isn't tracked per size here, so in stock means any size is in stock
+	if q.InStock {
+		filter["quantity"] = bson.M{"$gt": 0}
+	}
+
+	computed := bson.M{}
+	if !q.MinPrice.IsZero() {
+		computed["$gte"] = bson.A{effectivePrice(q.Now), q.MinPrice.Amount}
+	}
+	if !q.MaxPrice.IsZero() {
+		computed["$lte"] = bson.A{effectivePrice(q.Now), q.MaxPrice.Amount}
+	}
+	if q.OnSale {
+		computed["$gt"] = bson.A{effectiveDiscount(q.Now), 0}
+	}
+	if len(computed) != 0 {
+		conditions := bson.A{}
+		for k, v := range computed {
+			conditions = append(conditions, bson.M{k: v})
 		}
+		filter["$expr"] = bson.M{"$and": conditions}
 	}
 
-	if popularity {
-		sort = append(sort, bson.E{Key: "pieces_sold", Value: 1})
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$addFields", Value: bson.M{"sort_key": sortKey(q.Sort, q.Now)}}},
 	}
 
-	switch price {
-	case 1:
-		sort = append(sort, bson.E{Key: "price", Value: 1})
-	case 0:
-		sort = append(sort, bson.E{Key: "price", Value: -1})
+	if q.After != nil {
+		var after any = q.After.Key
+		if q.Sort == models.SortNewest {
+			after = q.After.CreatedAt
+		}
+
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"sort_key": bson.M{cmp: after}},
+			bson.M{"sort_key": after, "_id": bson.M{cmp: q.After.ID}},
+		}}}})
 	}
 
-	opts := options.Find().
-		SetSkip(int64(page * 10)).
-		SetLimit(10).
-		SetSort(sort)
+	pipeline = append(
+		pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "sort_key", Value: direction}, {Key: "_id", Value: direction}}}},
+		bson.D{{Key: "$limit", Value: q.Limit + 1}},
+	)
 
-	cursor, err := p.collection.Find(ctx, filter, opts)
+	cursor, err := p.collection.Aggregate(ctx, pipeline)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
 	}
 	defer cursor.Close(ctx)
 
-	cards := make([]models.ProductCard, 0)
+	cards := make([]models.ProductCard, 0, q.Limit+1)
 	for cursor.Next(ctx) {
 		var card models.ProductCard
 
 		err = cursor.Decode(&card)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
 		}
 
 		cards = append(cards, card)
 	}
+	if err = cursor.Err(); err != nil {
+		return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := models.ProductCardsPage{Cards: cards}
+	if len(cards) > q.Limit {
+		page.Cards = cards[:q.Limit]
+		page.HasMore = true
+	}
+
+	return page, nil
+}
+
+// objectId converts uuid of query to id of this backend. Object id is 12 bytes, it's passed
+// in the first bytes of uuid and the rest are zero, other uuids can't be ids here.
+func objectId(id uuid.UUID) (bson.ObjectID, bool) {
+	var objectId bson.ObjectID
+	if [4]byte(id[len(objectId):]) != [4]byte{} {
+		return bson.NilObjectID, false
+	}
+
+	copy(objectId[:], id[:len(objectId)])
+
+	return objectId, true
+}
 
-	if len(cards) == 0 {
-		return nil, fmt.Errorf("%s: %w", op, errs.ErrProductNotFound)
+// sortKey returns expression products are sorted by, it must give the same value
+// as models.NewProductCursor
+func sortKey(sort models.ProductSort, now time.Time) any {
+	switch sort {
+	case models.SortPopular:
+		return bson.M{"$ifNull": bson.A{"$pieces_sold", 0}}
+	case models.SortPriceAsc, models.SortPriceDesc:
+		return effectivePrice(now)
+	case models.SortDiscount:
+		return effectiveDiscount(now)
+	default:
+		return "$created_at"
 	}
+}
+
+// effectivePrice is price with discount active at now, rounded half up like in money.ApplyDiscount
+func effectivePrice(now time.Time) bson.M {
+	return bson.M{"$floor": bson.M{"$divide": bson.A{
+		bson.M{"$add": bson.A{
+			bson.M{"$multiply": bson.A{"$price.amount", bson.M{"$subtract": bson.A{100, effectiveDiscount(now)}}}},
+			50,
+		}},
+		100,
+	}}}
+}
 
-	return cards, nil
+func effectiveDiscount(now time.Time) bson.M {
+	return bson.M{"$cond": bson.A{
+		bson.M{"$and": bson.A{
+			bson.M{"$or": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$discount_starts_at", nil}}, nil}},
+				bson.M{"$lte": bson.A{"$discount_starts_at", now}},
+			}},
+			bson.M{"$or": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$discount_expires_at", nil}}, nil}},
+				bson.M{"$gt": bson.A{"$discount_expires_at", now}},
+			}},
+		}},
+		bson.M{"$ifNull": bson.A{"$discount", 0}},
+		0,
+	}}
 }
 
 func (p *ProductRepository) UpdateProduct(ctx context.Context, productToUpdate *models.Product) error {
@@ -181,7 +274,7 @@ func (p *ProductRepository) UpdateProduct(ctx context.Context, productToUpdate *
 		update["description"] = productToUpdate.Description
 	}
 
-	if productToUpdate.Price != -1 {
+	if productToUpdate.Price.Amount != -1 {
 		update["price"] = productToUpdate.Price
 	}
 
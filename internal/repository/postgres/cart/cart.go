@@ -2,7 +2,10 @@ package cart_repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/AlexMickh/shop-backend/internal/errs"
 	"github.com/AlexMickh/shop-backend/internal/models"
@@ -17,6 +20,7 @@ type DB interface {
 	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
 type CartRepository struct {
@@ -31,38 +35,89 @@ func New(db DB) *CartRepository {
 	}
 }
 
-func (c *CartRepository) AddProduct(ctx context.Context, userId, productId uuid.UUID) (uuid.UUID, error) {
+// AddProduct adds pieces of product in chosen size, if the size is already in the cart
+// its quantity is increased. Cart can't hold more pieces than there is in stock.
+func (c *CartRepository) AddProduct(
+	ctx context.Context,
+	owner models.CartOwner,
+	productId uuid.UUID,
+	size models.ProductSize,
+	quantity int,
+) (uuid.UUID, error) {
 	const op = "repository.postgres.cart.AddProduct"
 
-	query, args, err := c.queryBuilder.Insert("carts").
-		Rows(goqu.Record{"user_id": userId, "product_id": productId}).
-		Returning("id").
-		ToSQL()
-	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
-	}
+	column, ownerId := ownerColumn(owner)
+
+	// user sees the current price when adds product, so snapshot is updated for existing line too
+	query := fmt.Sprintf(`INSERT INTO carts (%[1]s, product_id, variant_id, quantity, price_snapshot, discount_snapshot)
+			  SELECT $1, products.id, product_variants.id, $4, products.price,
+			  	  CASE WHEN (products.discount_starts_at IS NULL OR products.discount_starts_at <= $5)
+			  	  AND (products.discount_expires_at IS NULL OR products.discount_expires_at > $5)
+			  	  THEN COALESCE(products.discount, 0) ELSE 0 END
+			  FROM product_variants
+			  JOIN products ON products.id = product_variants.product_id
+			  WHERE product_variants.product_id = $2 AND product_variants.size = $3 AND product_variants.stock >= $4
+			  ON CONFLICT (%[1]s, variant_id) DO UPDATE
+			  SET quantity = carts.quantity + EXCLUDED.quantity,
+			  	  price_snapshot = EXCLUDED.price_snapshot,
+			  	  discount_snapshot = EXCLUDED.discount_snapshot,
+			  	  updated_at = $5
+			  WHERE carts.quantity + EXCLUDED.quantity <= (
+			  	  SELECT stock FROM product_variants WHERE id = EXCLUDED.variant_id
+			  )
+			  RETURNING id`, column)
 
 	var id uuid.UUID
-	err = c.db.QueryRow(ctx, query, args...).Scan(&id)
+	err := c.db.QueryRow(ctx, query, ownerId, productId, size, quantity, time.Now()).Scan(&id)
 	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		var productExists, variantExists bool
+		query = `SELECT EXISTS(SELECT 1 FROM products WHERE id = $1),
+				 		EXISTS(SELECT 1 FROM product_variants WHERE product_id = $1 AND size = $2)`
+		err = c.db.QueryRow(ctx, query, productId, size).Scan(&productExists, &variantExists)
+		if err != nil {
+			return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if !productExists {
+			return uuid.UUID{}, fmt.Errorf("%s: %w", op, errs.ErrProductNotFound)
+		}
+
+		if !variantExists {
+			return uuid.UUID{}, fmt.Errorf("%s: %w", op, errs.ErrVariantNotFound)
+		}
+
+		return uuid.UUID{}, fmt.Errorf("%s: %w", op, errs.ErrNotEnoughStock)
 	}
 
 	return id, nil
 }
 
-func (c *CartRepository) Cart(ctx context.Context, userId uuid.UUID) ([]*models.CartItem, error) {
+func (c *CartRepository) Cart(ctx context.Context, owner models.CartOwner) ([]*models.CartItem, error) {
 	const op = "repository.postgres.cart.Cart"
 
-	// TODO: add product_id
+	column, ownerId := ownerColumn(owner)
+
 	query, args, err := c.queryBuilder.From("carts").
-		Select("id", "name", "price", "image_url", "discount", "discount_expires_at").
+		Select(
+			"carts.id", "products.id", "product_variants.id", "products.category_id", "products.name", "products.price",
+			"products.thumb_url", "products.discount", "products.discount_starts_at", "products.discount_expires_at",
+			"product_variants.size", "carts.quantity", "carts.price_snapshot", "carts.discount_snapshot",
+			"product_variants.stock",
+		).
 		Join(
 			goqu.T("products"),
 			goqu.On(goqu.Ex{"carts.product_id": goqu.I("products.id")}),
 		).
-		Where(goqu.Ex{"user_id": userId}).
-		GroupBy(goqu.C("products.id")).
+		Join(
+			goqu.T("product_variants"),
+			goqu.On(goqu.Ex{"carts.variant_id": goqu.I("product_variants.id")}),
+		).
+		Where(goqu.Ex{"carts." + column: ownerId}).
+		Order(goqu.I("carts.id").Asc()).
 		ToSQL()
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -80,11 +135,20 @@ func (c *CartRepository) Cart(ctx context.Context, userId uuid.UUID) ([]*models.
 
 		err = rows.Scan(
 			&cartItem.ID,
+			&cartItem.ProductID,
+			&cartItem.VariantID,
+			&cartItem.CategoryID,
 			&cartItem.Name,
 			&cartItem.Price,
 			&cartItem.ImageUrl,
 			&cartItem.Discount,
+			&cartItem.DiscountStartsAt,
 			&cartItem.DiscountExpiresAt,
+			&cartItem.Size,
+			&cartItem.Quantity,
+			&cartItem.PriceSnapshot,
+			&cartItem.DiscountSnapshot,
+			&cartItem.Stock,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
@@ -103,11 +167,48 @@ func (c *CartRepository) Cart(ctx context.Context, userId uuid.UUID) ([]*models.
 	return cartItems, nil
 }
 
-func (c *CartRepository) DeleteItem(ctx context.Context, userId, productId uuid.UUID) error {
+// SetQuantity sets quantity of cart item, but not more than there is in stock
+func (c *CartRepository) SetQuantity(ctx context.Context, owner models.CartOwner, id uuid.UUID, quantity int) error {
+	const op = "repository.postgres.cart.SetQuantity"
+
+	column, ownerId := ownerColumn(owner)
+
+	query := fmt.Sprintf(`UPDATE carts
+			  SET quantity = $3, updated_at = $4
+			  WHERE id = $1 AND %s = $2 AND $3 <= (
+			  	  SELECT stock FROM product_variants WHERE id = carts.variant_id
+			  )`, column)
+
+	result, err := c.db.Exec(ctx, query, id, ownerId, quantity, time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		var exists bool
+		query = fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM carts WHERE id = $1 AND %s = $2)", column)
+		err = c.db.QueryRow(ctx, query, id, ownerId).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if !exists {
+			return fmt.Errorf("%s: %w", op, errs.ErrCartItemNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, errs.ErrNotEnoughStock)
+	}
+
+	return nil
+}
+
+func (c *CartRepository) DeleteItem(ctx context.Context, owner models.CartOwner, id uuid.UUID) error {
 	const op = "repository.postgres.cart.DeleteItem"
 
+	column, ownerId := ownerColumn(owner)
+
 	query, args, err := c.queryBuilder.Delete("carts").
-		Where(goqu.Ex{"user_id": userId, "product_id": productId}).
+		Where(goqu.Ex{"id": id, column: ownerId}).
 		ToSQL()
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
@@ -121,20 +222,281 @@ func (c *CartRepository) DeleteItem(ctx context.Context, userId, productId uuid.
 	return nil
 }
 
-func (c *CartRepository) Clear(ctx context.Context, userId uuid.UUID) error {
+// Clear deletes all lines and applied coupon of the cart
+func (c *CartRepository) Clear(ctx context.Context, owner models.CartOwner) error {
 	const op = "repository.postgres.cart.Clear"
 
-	query, args, err := c.queryBuilder.Delete("carts").
-		Where(goqu.Ex{"user_id": userId}).
+	column, ownerId := ownerColumn(owner)
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range []string{"carts", "cart_coupons"} {
+		query, args, err := c.queryBuilder.Delete(table).
+			Where(goqu.Ex{column: ownerId}).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		_, err = tx.Exec(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetCoupon applies coupon to the cart, it replaces previously applied one
+func (c *CartRepository) SetCoupon(ctx context.Context, owner models.CartOwner, couponId uuid.UUID) error {
+	const op = "repository.postgres.cart.SetCoupon"
+
+	column, ownerId := ownerColumn(owner)
+
+	query := fmt.Sprintf(`INSERT INTO cart_coupons (%[1]s, coupon_id)
+			  VALUES ($1, $2)
+			  ON CONFLICT (%[1]s) DO UPDATE
+			  SET coupon_id = EXCLUDED.coupon_id, created_at = $3`, column)
+
+	_, err := c.db.Exec(ctx, query, ownerId, couponId, time.Now())
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == "23503" {
+				return fmt.Errorf("%s: %w", op, errs.ErrCouponNotFound)
+			}
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CouponId returns id of coupon applied to the cart, nil if there is no coupon
+func (c *CartRepository) CouponId(ctx context.Context, owner models.CartOwner) (*uuid.UUID, error) {
+	const op = "repository.postgres.cart.CouponId"
+
+	column, ownerId := ownerColumn(owner)
+
+	query, args, err := c.queryBuilder.From("cart_coupons").
+		Select("coupon_id").
+		Where(goqu.Ex{column: ownerId}).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id uuid.UUID
+	err = c.db.QueryRow(ctx, query, args...).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &id, nil
+}
+
+func (c *CartRepository) DeleteCoupon(ctx context.Context, owner models.CartOwner) error {
+	const op = "repository.postgres.cart.DeleteCoupon"
+
+	column, ownerId := ownerColumn(owner)
+
+	query, args, err := c.queryBuilder.Delete("cart_coupons").
+		Where(goqu.Ex{column: ownerId}).
 		ToSQL()
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = c.db.Exec(ctx, query, args...)
+	result, err := c.db.Exec(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, errs.ErrCouponNotFound)
+	}
+
 	return nil
 }
+
+// UpdateLines saves quantity and price snapshot of cart lines, lines with zero quantity are deleted
+func (c *CartRepository) UpdateLines(ctx context.Context, owner models.CartOwner, items []*models.CartItem) error {
+	const op = "repository.postgres.cart.UpdateLines"
+
+	column, ownerId := ownerColumn(owner)
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, v := range items {
+		var query string
+		var args []any
+
+		if v.Quantity == 0 {
+			query, args, err = c.queryBuilder.Delete("carts").
+				Where(goqu.Ex{"id": v.ID, column: ownerId}).
+				ToSQL()
+		} else {
+			query, args, err = c.queryBuilder.Update("carts").
+				Set(goqu.Record{
+					"quantity":          v.Quantity,
+					"price_snapshot":    v.PriceSnapshot,
+					"discount_snapshot": v.DiscountSnapshot,
+					"updated_at":        time.Now(),
+				}).
+				Where(goqu.Ex{"id": v.ID, column: ownerId}).
+				ToSQL()
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		_, err = tx.Exec(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Merge moves guest cart lines to the user cart. If the user already has the same size,
+// quantities are summed. Quantity is cut to stock, but the user's own quantity is never decreased.
+// Sold out sizes are dropped.
+func (c *CartRepository) Merge(ctx context.Context, guestId, userId uuid.UUID) error {
+	const op = "repository.postgres.cart.Merge"
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO carts (user_id, product_id, variant_id, quantity, price_snapshot, discount_snapshot)
+			  SELECT $2, carts.product_id, carts.variant_id, LEAST(carts.quantity, product_variants.stock),
+			  	  carts.price_snapshot, carts.discount_snapshot
+			  FROM carts
+			  JOIN product_variants ON product_variants.id = carts.variant_id
+			  WHERE carts.guest_id = $1 AND product_variants.stock > 0
+			  ON CONFLICT (user_id, variant_id) DO UPDATE
+			  SET quantity = GREATEST(carts.quantity, LEAST(
+			  	  carts.quantity + EXCLUDED.quantity,
+			  	  (SELECT stock FROM product_variants WHERE id = EXCLUDED.variant_id)
+			  )),
+			  	  updated_at = $3`
+
+	_, err = tx.Exec(ctx, query, guestId, userId, time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM carts WHERE guest_id = $1", guestId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// coupon of the user cart is kept if both have one
+	query = `INSERT INTO cart_coupons (user_id, coupon_id)
+			 SELECT $2, coupon_id FROM cart_coupons WHERE guest_id = $1
+			 ON CONFLICT (user_id) DO NOTHING`
+
+	_, err = tx.Exec(ctx, query, guestId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM cart_coupons WHERE guest_id = $1", guestId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TouchGuest saves time of guest visit. It's updated not more often than once an hour,
+// so every request of the guest doesn't write to the table.
+func (c *CartRepository) TouchGuest(ctx context.Context, guestId uuid.UUID, now time.Time) error {
+	const op = "repository.postgres.cart.TouchGuest"
+
+	query := `INSERT INTO guests (id, seen_at) VALUES ($1, $2)
+			  ON CONFLICT (id) DO UPDATE SET seen_at = EXCLUDED.seen_at
+			  WHERE guests.seen_at < EXCLUDED.seen_at - INTERVAL '1 hour'`
+
+	_, err := c.db.Exec(ctx, query, guestId, now)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteOldGuestCarts deletes carts of guests not seen since before, returns number of deleted lines
+func (c *CartRepository) DeleteOldGuestCarts(ctx context.Context, before time.Time) (int64, error) {
+	const op = "repository.postgres.cart.DeleteOldGuestCarts"
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `DELETE FROM carts
+			  WHERE guest_id IN (SELECT id FROM guests WHERE seen_at < $1)`
+
+	result, err := tx.Exec(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `DELETE FROM cart_coupons
+			 WHERE guest_id IN (SELECT id FROM guests WHERE seen_at < $1)`
+
+	_, err = tx.Exec(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM guests WHERE seen_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// ownerColumn returns column and id by which cart lines of owner are found
+func ownerColumn(owner models.CartOwner) (string, uuid.UUID) {
+	if owner.IsGuest() {
+		return "guest_id", owner.GuestID
+	}
+
+	return "user_id", owner.UserID
+}
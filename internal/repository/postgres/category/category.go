@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/AlexMickh/shop-backend/internal/errs"
 	"github.com/AlexMickh/shop-backend/internal/models"
@@ -18,6 +19,7 @@ type DB interface {
 	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
 type CategoryRepository struct {
@@ -36,7 +38,12 @@ func (c *CategoryRepository) SaveCategory(ctx context.Context, category models.C
 	const op = "repository.postgres.category.SaveCategory"
 
 	query, args, err := c.queryBuilder.Insert("categories").
-		Rows(goqu.Record{"name": category.Name}).
+		Rows(goqu.Record{
+			"parent_id": category.ParentID,
+			"name":      category.Name,
+			"slug":      category.Slug,
+			"position":  category.Position,
+		}).
 		Returning("id").
 		ToSQL()
 	if err != nil {
@@ -51,6 +58,10 @@ func (c *CategoryRepository) SaveCategory(ctx context.Context, category models.C
 			if pgErr.Code == "23505" {
 				return uuid.UUID{}, fmt.Errorf("%s: %w", op, errs.ErrCategoryAlreadyExists)
 			}
+			// parent doesn't exist
+			if pgErr.Code == "23503" {
+				return uuid.UUID{}, fmt.Errorf("%s: %w", op, errs.ErrCategoryNotFound)
+			}
 		}
 
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
@@ -59,9 +70,139 @@ func (c *CategoryRepository) SaveCategory(ctx context.Context, category models.C
 	return id, nil
 }
 
-func (c *CategoryRepository) DeleteCategory(ctx context.Context, id uuid.UUID) error {
+// CategoryById returns category with its direct children, every one of them has count
+// of products including products of subcategories
+func (c *CategoryRepository) CategoryById(ctx context.Context, id uuid.UUID) (*models.Category, error) {
+	const op = "repository.postgres.category.CategoryById"
+
+	// tree has every descendant of category and of its children, root is the one they're counted for
+	query := `WITH RECURSIVE tree AS (
+			  	  SELECT id, id AS root FROM categories WHERE id = $1 OR parent_id = $1
+			  	  UNION ALL
+			  	  SELECT categories.id, tree.root FROM categories JOIN tree ON categories.parent_id = tree.id
+			  )
+			  SELECT categories.id, categories.parent_id, categories.name, categories.slug, categories.description,
+			  	  COALESCE(categories.image_url, ''), categories.position, COUNT(products.id)
+			  FROM categories
+			  LEFT JOIN tree ON tree.root = categories.id
+			  LEFT JOIN products ON products.category_id = tree.id
+			  WHERE categories.id = $1 OR categories.parent_id = $1
+			  GROUP BY categories.id
+			  ORDER BY categories.position, categories.name`
+
+	rows, err := c.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var category *models.Category
+	children := make([]models.Category, 0)
+	for rows.Next() {
+		var v models.Category
+
+		err = rows.Scan(&v.ID, &v.ParentID, &v.Name, &v.Slug, &v.Description, &v.ImageUrl, &v.Position, &v.ProductCount)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if v.ID == id {
+			category = &v
+			continue
+		}
+
+		children = append(children, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if category == nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrCategoryNotFound)
+	}
+	category.Children = children
+
+	return category, nil
+}
+
+// UpdateCategory saves not empty fields of category, position -1 means not changed
+func (c *CategoryRepository) UpdateCategory(ctx context.Context, category *models.Category) error {
+	const op = "repository.postgres.category.UpdateCategory"
+
+	record := goqu.Record{"updated_at": time.Now()}
+
+	if category.Name != "" {
+		record["name"] = category.Name
+	}
+	if category.Slug != "" {
+		record["slug"] = category.Slug
+	}
+	if category.Description != "" {
+		record["description"] = category.Description
+	}
+	if category.ImageUrl != "" {
+		record["image_url"] = category.ImageUrl
+	}
+	if category.Position != -1 {
+		record["position"] = category.Position
+	}
+
+	query, args, err := c.queryBuilder.Update("categories").
+		Set(record).
+		Where(goqu.Ex{"id": category.ID}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := c.db.Exec(ctx, query, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, errs.ErrCategoryAlreadyExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, errs.ErrCategoryNotFound)
+	}
+
+	return nil
+}
+
+// DeleteCategory deletes category without subcategories. Its products are moved to moveTo category,
+// if moveTo is nil and category has products, it isn't deleted.
+func (c *CategoryRepository) DeleteCategory(ctx context.Context, id, moveTo uuid.UUID) error {
 	const op = "repository.postgres.category.DeleteCategory"
 
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if moveTo != uuid.Nil {
+		query, args, err := c.queryBuilder.Update("products").
+			Set(goqu.Record{"category_id": moveTo, "updated_at": time.Now()}).
+			Where(goqu.Ex{"category_id": id}).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		_, err = tx.Exec(ctx, query, args...)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				return fmt.Errorf("%s: %w", op, errs.ErrCategoryNotFound)
+			}
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
 	query, args, err := c.queryBuilder.Delete("categories").
 		Where(goqu.Ex{"id": id}).
 		ToSQL()
@@ -69,8 +210,14 @@ func (c *CategoryRepository) DeleteCategory(ctx context.Context, id uuid.UUID) e
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	result, err := c.db.Exec(ctx, query, args...)
+	result, err := tx.Exec(ctx, query, args...)
 	if err != nil {
+		// products or subcategories reference it
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, errs.ErrCategoryNotEmpty)
+		}
+
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -78,14 +225,20 @@ func (c *CategoryRepository) DeleteCategory(ctx context.Context, id uuid.UUID) e
 		return fmt.Errorf("%s: %w", op, errs.ErrCategoryNotFound)
 	}
 
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
+// AllCategories returns flat list of categories ordered by position
 func (c *CategoryRepository) AllCategories(ctx context.Context) ([]models.Category, error) {
 	const op = "repository.postgres.category.AllCategories"
 
 	query, _, err := c.queryBuilder.From("categories").
-		Select("id", "name").
+		Select("id", "parent_id", "name", "slug", "position").
+		Order(goqu.I("position").Asc(), goqu.I("name").Asc()).
 		ToSQL()
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -101,7 +254,7 @@ func (c *CategoryRepository) AllCategories(ctx context.Context) ([]models.Catego
 	for rows.Next() {
 		var category models.Category
 
-		err = rows.Scan(&category.ID, &category.Name)
+		err = rows.Scan(&category.ID, &category.ParentID, &category.Name, &category.Slug, &category.Position)
 		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
@@ -0,0 +1,416 @@
+package coupon_repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/doug-martin/goqu/v9"
+	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+type CouponRepository struct {
+	db           DB
+	queryBuilder goqu.DialectWrapper
+}
+
+func New(db DB) *CouponRepository {
+	return &CouponRepository{
+		db:           db,
+		queryBuilder: goqu.Dialect("postgres"),
+	}
+}
+
+func (c *CouponRepository) SaveCoupon(ctx context.Context, coupon *models.Coupon) error {
+	const op = "repository.postgres.coupon.SaveCoupon"
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query, args, err := c.queryBuilder.Insert("coupons").
+		Rows(goqu.Record{
+			"code":                 coupon.Code,
+			"type":                 coupon.Type,
+			"value":                coupon.Value,
+			"min_order_price":      coupon.MinOrderPrice,
+			"usage_limit":          coupon.UsageLimit,
+			"usage_limit_per_user": coupon.UsageLimitPerUser,
+			"starts_at":            coupon.StartsAt,
+			"expires_at":           coupon.ExpiresAt,
+		}).
+		Returning("id", "created_at").
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = tx.QueryRow(ctx, query, args...).Scan(&coupon.ID, &coupon.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == "23505" {
+				return fmt.Errorf("%s: %w", op, errs.ErrCouponAlreadyExists)
+			}
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = c.saveScope(ctx, tx, coupon)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *CouponRepository) CouponById(ctx context.Context, id uuid.UUID) (*models.Coupon, error) {
+	const op = "repository.postgres.coupon.CouponById"
+
+	coupon, err := c.coupon(ctx, goqu.Ex{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return coupon, nil
+}
+
+func (c *CouponRepository) CouponByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	const op = "repository.postgres.coupon.CouponByCode"
+
+	coupon, err := c.coupon(ctx, goqu.Ex{"code": code})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return coupon, nil
+}
+
+func (c *CouponRepository) Coupons(ctx context.Context) ([]models.Coupon, error) {
+	const op = "repository.postgres.coupon.Coupons"
+
+	query, args, err := c.queryBuilder.From("coupons").
+		Select(couponColumns...).
+		Order(goqu.C("created_at").Desc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := c.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	coupons := make([]models.Coupon, 0)
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var coupon models.Coupon
+
+		err = rows.Scan(couponFields(&coupon)...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		coupons = append(coupons, coupon)
+		ids = append(ids, coupon.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(coupons) == 0 {
+		return coupons, nil
+	}
+
+	products, categories, err := c.scopes(ctx, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range coupons {
+		coupons[i].ProductIDs = products[coupons[i].ID]
+		coupons[i].CategoryIDs = categories[coupons[i].ID]
+	}
+
+	return coupons, nil
+}
+
+// UpdateCoupon saves all fields of coupon, its products and categories are replaced
+func (c *CouponRepository) UpdateCoupon(ctx context.Context, coupon *models.Coupon) error {
+	const op = "repository.postgres.coupon.UpdateCoupon"
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query, args, err := c.queryBuilder.Update("coupons").
+		Set(goqu.Record{
+			"code":                 coupon.Code,
+			"type":                 coupon.Type,
+			"value":                coupon.Value,
+			"min_order_price":      coupon.MinOrderPrice,
+			"usage_limit":          coupon.UsageLimit,
+			"usage_limit_per_user": coupon.UsageLimitPerUser,
+			"starts_at":            coupon.StartsAt,
+			"expires_at":           coupon.ExpiresAt,
+			"updated_at":           time.Now(),
+		}).
+		Where(goqu.Ex{"id": coupon.ID}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == "23505" {
+				return fmt.Errorf("%s: %w", op, errs.ErrCouponAlreadyExists)
+			}
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, errs.ErrCouponNotFound)
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM coupon_products WHERE coupon_id = $1", coupon.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM coupon_categories WHERE coupon_id = $1", coupon.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = c.saveScope(ctx, tx, coupon)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *CouponRepository) DeleteCoupon(ctx context.Context, id uuid.UUID) error {
+	const op = "repository.postgres.coupon.DeleteCoupon"
+
+	query, args, err := c.queryBuilder.Delete("coupons").
+		Where(goqu.Ex{"id": id}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := c.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, errs.ErrCouponNotFound)
+	}
+
+	return nil
+}
+
+// CouponUsage returns how many not cancelled orders used coupon, in total and by user
+func (c *CouponRepository) CouponUsage(ctx context.Context, id, userId uuid.UUID) (int, int, error) {
+	const op = "repository.postgres.coupon.CouponUsage"
+
+	query := `SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2)
+			  FROM orders
+			  WHERE coupon_id = $1 AND status <> 'cancelled'`
+
+	var total, byUser int
+	err := c.db.QueryRow(ctx, query, id, userId).Scan(&total, &byUser)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return total, byUser, nil
+}
+
+var couponColumns = []any{
+	"id", "code", "type", "value", "min_order_price", "usage_limit",
+	"usage_limit_per_user", "starts_at", "expires_at", "created_at", "updated_at",
+}
+
+func couponFields(coupon *models.Coupon) []any {
+	return []any{
+		&coupon.ID,
+		&coupon.Code,
+		&coupon.Type,
+		&coupon.Value,
+		&coupon.MinOrderPrice,
+		&coupon.UsageLimit,
+		&coupon.UsageLimitPerUser,
+		&coupon.StartsAt,
+		&coupon.ExpiresAt,
+		&coupon.CreatedAt,
+		&coupon.UpdatedAt,
+	}
+}
+
+func (c *CouponRepository) coupon(ctx context.Context, where goqu.Ex) (*models.Coupon, error) {
+	const op = "repository.postgres.coupon.coupon"
+
+	query, args, err := c.queryBuilder.From("coupons").
+		Select(couponColumns...).
+		Where(where).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	coupon := new(models.Coupon)
+	err = c.db.QueryRow(ctx, query, args...).Scan(couponFields(coupon)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, errs.ErrCouponNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	products, categories, err := c.scopes(ctx, coupon.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	coupon.ProductIDs = products[coupon.ID]
+	coupon.CategoryIDs = categories[coupon.ID]
+
+	return coupon, nil
+}
+
+func (c *CouponRepository) saveScope(ctx context.Context, tx pgx.Tx, coupon *models.Coupon) error {
+	const op = "repository.postgres.coupon.saveScope"
+
+	if len(coupon.ProductIDs) != 0 {
+		rows := make([]any, 0, len(coupon.ProductIDs))
+		for _, v := range coupon.ProductIDs {
+			rows = append(rows, goqu.Record{"coupon_id": coupon.ID, "product_id": v})
+		}
+
+		query, args, err := c.queryBuilder.Insert("coupon_products").
+			Rows(rows...).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		_, err = tx.Exec(ctx, query, args...)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				if pgErr.Code == "23503" {
+					return fmt.Errorf("%s: %w", op, errs.ErrProductNotFound)
+				}
+			}
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if len(coupon.CategoryIDs) != 0 {
+		rows := make([]any, 0, len(coupon.CategoryIDs))
+		for _, v := range coupon.CategoryIDs {
+			rows = append(rows, goqu.Record{"coupon_id": coupon.ID, "category_id": v})
+		}
+
+		query, args, err := c.queryBuilder.Insert("coupon_categories").
+			Rows(rows...).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		_, err = tx.Exec(ctx, query, args...)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				if pgErr.Code == "23503" {
+					return fmt.Errorf("%s: %w", op, errs.ErrCategoryNotFound)
+				}
+			}
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// scopes returns products and categories of coupons by coupon id
+func (c *CouponRepository) scopes(
+	ctx context.Context,
+	couponIds ...uuid.UUID,
+) (map[uuid.UUID][]uuid.UUID, map[uuid.UUID][]uuid.UUID, error) {
+	const op = "repository.postgres.coupon.scopes"
+
+	query := `SELECT coupon_id, product_id, NULL::uuid FROM coupon_products WHERE coupon_id = ANY($1)
+			  UNION ALL
+			  SELECT coupon_id, NULL::uuid, category_id FROM coupon_categories WHERE coupon_id = ANY($1)`
+
+	rows, err := c.db.Query(ctx, query, couponIds)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	products := make(map[uuid.UUID][]uuid.UUID, len(couponIds))
+	categories := make(map[uuid.UUID][]uuid.UUID, len(couponIds))
+	for rows.Next() {
+		var couponId uuid.UUID
+		var productId, categoryId *uuid.UUID
+
+		err = rows.Scan(&couponId, &productId, &categoryId)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if productId != nil {
+			products[couponId] = append(products[couponId], *productId)
+		}
+		if categoryId != nil {
+			categories[couponId] = append(categories[couponId], *categoryId)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return products, categories, nil
+}
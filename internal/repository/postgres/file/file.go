@@ -0,0 +1,82 @@
+package file_repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// FileRepository finds which image urls are referenced by products and categories
+type FileRepository struct {
+	db DB
+}
+
+func New(db DB) *FileRepository {
+	return &FileRepository{
+		db: db,
+	}
+}
+
+// ImageUrls returns every image url saved in database
+func (f *FileRepository) ImageUrls(ctx context.Context) ([]string, error) {
+	const op = "repository.postgres.file.ImageUrls"
+
+	query := `SELECT url FROM product_images
+			  UNION SELECT medium_url FROM product_images
+			  UNION SELECT thumb_url FROM product_images
+			  UNION SELECT image_url FROM products WHERE image_url IS NOT NULL
+			  UNION SELECT thumb_url FROM products WHERE thumb_url IS NOT NULL
+			  UNION SELECT image_url FROM categories WHERE image_url IS NOT NULL`
+
+	return f.urls(ctx, op, query)
+}
+
+// ImagesInUse returns urls which files are still referenced by some product image or category.
+// Urls are compared by file name like in garbage collection, it's hash of content, so saved url
+// with other server address references the same file.
+// Names are taken by the same expression as in indexes of migration 000027, so lookups use them.
+// Urls of products are copies of their main image, so they aren't checked.
+func (f *FileRepository) ImagesInUse(ctx context.Context, urls []string) ([]string, error) {
+	const op = "repository.postgres.file.ImagesInUse"
+
+	query := `SELECT DISTINCT u FROM unnest($1::text[]) AS u,
+			  LATERAL (SELECT regexp_replace(u, '^.*/', '') AS name) AS file
+			  WHERE EXISTS (
+			  	  SELECT 1 FROM product_images
+			  	  WHERE regexp_replace(url, '^.*/', '') = file.name
+			  	     OR regexp_replace(medium_url, '^.*/', '') = file.name
+			  	     OR regexp_replace(thumb_url, '^.*/', '') = file.name
+			  ) OR EXISTS (
+			  	  SELECT 1 FROM categories WHERE regexp_replace(image_url, '^.*/', '') = file.name
+			  )`
+
+	return f.urls(ctx, op, query, urls)
+}
+
+func (f *FileRepository) urls(ctx context.Context, op string, query string, args ...any) ([]string, error) {
+	rows, err := f.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	urls := make([]string, 0)
+	for rows.Next() {
+		var url string
+		if err = rows.Scan(&url); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		urls = append(urls, url)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return urls, nil
+}
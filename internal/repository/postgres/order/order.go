@@ -0,0 +1,731 @@
+package order_repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/doug-martin/goqu/v9"
+	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+type OrderRepository struct {
+	db           DB
+	queryBuilder goqu.DialectWrapper
+}
+
+func New(db DB) *OrderRepository {
+	return &OrderRepository{
+		db:           db,
+		queryBuilder: goqu.Dialect("postgres"),
+	}
+}
+
+// SaveOrder saves order with its items. If order has coupon, its usage limits are checked
+// under the coupon row lock, so concurrent orders can't exceed them.
+func (o *OrderRepository) SaveOrder(ctx context.Context, order *models.Order) error {
+	const op = "repository.postgres.order.SaveOrder"
+
+	tx, err := o.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if order.CouponID != nil {
+		err = checkCouponUsage(ctx, tx, *order.CouponID, order.UserID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	query, args, err := o.queryBuilder.Insert("orders").
+		Rows(goqu.Record{
+			"user_id":         order.UserID,
+			"status":          order.Status,
+			"price":           order.Price,
+			"coupon_id":       order.CouponID,
+			"coupon_discount": order.CouponDiscount,
+		}).
+		Returning("id", "created_at").
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = tx.QueryRow(ctx, query, args...).Scan(&order.ID, &order.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	items := make([]any, 0, len(order.Items))
+	for _, v := range order.Items {
+		items = append(items, goqu.Record{
+			"order_id":        order.ID,
+			"product_id":      v.ProductID,
+			"variant_id":      v.VariantID,
+			"name":            v.Name,
+			"price":           v.Price,
+			"discount":        v.Discount,
+			"size":            v.Size,
+			"quantity":        v.Quantity,
+			"coupon_discount": v.CouponDiscount,
+		})
+	}
+
+	query, args, err = o.queryBuilder.Insert("order_items").
+		Rows(items...).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (o *OrderRepository) OrderById(ctx context.Context, id uuid.UUID) (*models.Order, error) {
+	const op = "repository.postgres.order.OrderById"
+
+	query, args, err := o.queryBuilder.From("orders").
+		Select("user_id", "status", "price", "payment_id", "coupon_id", "coupon_discount", "created_at", "updated_at").
+		Where(goqu.Ex{"id": id}).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	order := new(models.Order)
+	err = o.db.QueryRow(ctx, query, args...).Scan(
+		&order.UserID,
+		&order.Status,
+		&order.Price,
+		&order.PaymentID,
+		&order.CouponID,
+		&order.CouponDiscount,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, errs.ErrOrderNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	order.ID = id
+
+	items, err := o.orderItems(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	order.Items = items[id]
+
+	return order, nil
+}
+
+func (o *OrderRepository) Orders(ctx context.Context, userId uuid.UUID) ([]models.Order, error) {
+	const op = "repository.postgres.order.Orders"
+
+	query, args, err := o.queryBuilder.From("orders").
+		Select("id", "status", "price", "payment_id", "coupon_id", "coupon_discount", "created_at", "updated_at").
+		Where(goqu.Ex{"user_id": userId}).
+		Order(goqu.C("created_at").Desc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := o.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	orders := make([]models.Order, 0)
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		order := models.Order{UserID: userId}
+
+		err = rows.Scan(
+			&order.ID,
+			&order.Status,
+			&order.Price,
+			&order.PaymentID,
+			&order.CouponID,
+			&order.CouponDiscount,
+			&order.CreatedAt,
+			&order.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		orders = append(orders, order)
+		ids = append(ids, order.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(orders) == 0 {
+		return orders, nil
+	}
+
+	items, err := o.orderItems(ctx, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range orders {
+		orders[i].Items = items[orders[i].ID]
+	}
+
+	return orders, nil
+}
+
+func (o *OrderRepository) UpdateStatus(ctx context.Context, id uuid.UUID, from, to models.OrderStatus) error {
+	const op = "repository.postgres.order.UpdateStatus"
+
+	// checking the previous status here guards against two concurrent transitions
+	query, args, err := o.queryBuilder.Update("orders").
+		Set(goqu.Record{"status": to, "updated_at": time.Now()}).
+		Where(goqu.Ex{"id": id, "status": from}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := o.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, errs.ErrOrderNotFound)
+	}
+
+	return nil
+}
+
+func (o *OrderRepository) SetPaymentId(ctx context.Context, id uuid.UUID, paymentId string) error {
+	const op = "repository.postgres.order.SetPaymentId"
+
+	query, args, err := o.queryBuilder.Update("orders").
+		Set(goqu.Record{"payment_id": paymentId, "updated_at": time.Now()}).
+		Where(goqu.Ex{"id": id}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := o.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, errs.ErrOrderNotFound)
+	}
+
+	return nil
+}
+
+// CancelExpired cancels orders waiting for payment which stock reservations are expired and returns
+// their stock. It's done by one statement, so stock is never on sale while its order can be paid.
+// Returns number of cancelled orders.
+func (o *OrderRepository) CancelExpired(ctx context.Context, now time.Time) (int64, error) {
+	const op = "repository.postgres.order.CancelExpired"
+
+	// reservations are updated first, reservation committed by concurrent payment isn't released
+	// and its order isn't cancelled
+	query := `WITH released AS (
+			  	  UPDATE stock_reservations
+			  	  SET status = 'released', updated_at = $1
+			  	  WHERE status = 'active' AND expires_at < $1
+			  	  RETURNING order_id, variant_id, quantity
+			  ), restocked AS (
+			  	  UPDATE product_variants
+			  	  SET stock = product_variants.stock + r.quantity
+			  	  FROM (SELECT variant_id, SUM(quantity) AS quantity FROM released GROUP BY variant_id) AS r
+			  	  WHERE product_variants.id = r.variant_id
+			  ), cancelled AS (
+			  	  UPDATE orders
+			  	  SET status = 'cancelled', updated_at = $1
+			  	  WHERE status = 'pending_payment' AND id IN (SELECT order_id FROM released)
+			  	  RETURNING id
+			  )
+			  SELECT COUNT(*) FROM cancelled`
+
+	var count int64
+	err := o.db.QueryRow(ctx, query, now).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// SaveRefund records refund in pending status and reserves refunded quantity of order items,
+// so two concurrent refunds can't return the same piece twice
+func (o *OrderRepository) SaveRefund(ctx context.Context, refund *models.OrderRefund) error {
+	const op = "repository.postgres.order.SaveRefund"
+
+	tx, err := o.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query, args, err := o.queryBuilder.Insert("refunds").
+		Rows(goqu.Record{
+			"order_id": refund.OrderID,
+			"status":   refund.Status,
+			"price":    refund.Price,
+			"reason":   refund.Reason,
+		}).
+		Returning("id", "created_at").
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = tx.QueryRow(ctx, query, args...).Scan(&refund.ID, &refund.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	items := make([]any, 0, len(refund.Items))
+	for _, v := range refund.Items {
+		items = append(items, goqu.Record{
+			"refund_id":     refund.ID,
+			"order_item_id": v.OrderItemID,
+			"quantity":      v.Quantity,
+			"price":         v.Price,
+		})
+	}
+
+	query, args, err = o.queryBuilder.Insert("refund_items").
+		Rows(items...).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `UPDATE order_items
+			 SET refunded_quantity = refunded_quantity + $1
+			 WHERE id = $2 AND order_id = $3 AND refunded_quantity + $1 <= quantity`
+
+	for _, v := range refund.Items {
+		result, err := tx.Exec(ctx, query, v.Quantity, v.OrderItemID, refund.OrderID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return fmt.Errorf("%s: %w", op, errs.ErrRefundQuantity)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CompleteRefund saves refund succeeded in provider, returns refunded pieces to stock
+// and moves order to refunded or partially_refunded status
+func (o *OrderRepository) CompleteRefund(ctx context.Context, refund *models.OrderRefund) error {
+	const op = "repository.postgres.order.CompleteRefund"
+
+	// money isn't returned yet, so stock and order can't be changed
+	if refund.Status != models.RefundStatusSucceeded {
+		return fmt.Errorf("%s: refund %s is %s, not succeeded", op, refund.ID, refund.Status)
+	}
+
+	tx, err := o.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query, args, err := o.queryBuilder.Update("refunds").
+		Set(goqu.Record{
+			"status":            refund.Status,
+			"payment_refund_id": refund.PaymentRefundID,
+			"updated_at":        time.Now(),
+		}).
+		Where(goqu.Ex{"id": refund.ID, "status": models.RefundStatusPending}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: refund %s is already completed", op, refund.ID)
+	}
+
+	query = `UPDATE product_variants
+			 SET stock = product_variants.stock + refund_items.quantity
+			 FROM refund_items
+			 JOIN order_items ON order_items.id = refund_items.order_item_id
+			 WHERE refund_items.refund_id = $1 AND product_variants.id = order_items.variant_id`
+
+	_, err = tx.Exec(ctx, query, refund.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `UPDATE orders
+			 SET status = CASE WHEN EXISTS (
+			 		 SELECT 1 FROM order_items WHERE order_id = $1 AND refunded_quantity < quantity
+			 	 ) THEN 'partially_refunded'::order_status ELSE 'refunded'::order_status END,
+			 	 updated_at = $2
+			 WHERE id = $1`
+
+	_, err = tx.Exec(ctx, query, refund.OrderID, time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SavePaymentRefundId saves id of refund which provider has accepted but hasn't finished,
+// refund stays pending and is checked again by it
+func (o *OrderRepository) SavePaymentRefundId(ctx context.Context, id uuid.UUID, paymentRefundId string) error {
+	const op = "repository.postgres.order.SavePaymentRefundId"
+
+	query, args, err := o.queryBuilder.Update("refunds").
+		Set(goqu.Record{"payment_refund_id": paymentRefundId, "updated_at": time.Now()}).
+		Where(goqu.Ex{"id": id, "status": models.RefundStatusPending}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := o.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: refund %s is already completed", op, id)
+	}
+
+	return nil
+}
+
+// CancelRefund marks refund as canceled and releases quantity reserved by SaveRefund
+func (o *OrderRepository) CancelRefund(ctx context.Context, id uuid.UUID) error {
+	const op = "repository.postgres.order.CancelRefund"
+
+	tx, err := o.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query, args, err := o.queryBuilder.Update("refunds").
+		Set(goqu.Record{"status": models.RefundStatusCanceled, "updated_at": time.Now()}).
+		Where(goqu.Ex{"id": id, "status": models.RefundStatusPending}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: refund %s is already completed", op, id)
+	}
+
+	query = `UPDATE order_items
+			 SET refunded_quantity = order_items.refunded_quantity - refund_items.quantity
+			 FROM refund_items
+			 WHERE refund_items.refund_id = $1 AND order_items.id = refund_items.order_item_id`
+
+	_, err = tx.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PendingRefunds returns refunds which were created before given time and aren't finished:
+// not answered by provider or accepted by it and still pending there
+func (o *OrderRepository) PendingRefunds(ctx context.Context, before time.Time) ([]models.OrderRefund, error) {
+	const op = "repository.postgres.order.PendingRefunds"
+
+	query, args, err := o.queryBuilder.From("refunds").
+		Select("id", "order_id", "payment_refund_id", "price", "created_at").
+		Where(
+			goqu.Ex{"status": models.RefundStatusPending},
+			goqu.C("created_at").Lt(before),
+		).
+		Order(goqu.C("created_at").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := o.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	refunds := make([]models.OrderRefund, 0)
+	for rows.Next() {
+		refund := models.OrderRefund{Status: models.RefundStatusPending}
+
+		err = rows.Scan(&refund.ID, &refund.OrderID, &refund.PaymentRefundID, &refund.Price, &refund.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		refunds = append(refunds, refund)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return refunds, nil
+}
+
+func (o *OrderRepository) Refunds(ctx context.Context, orderId uuid.UUID) ([]models.OrderRefund, error) {
+	const op = "repository.postgres.order.Refunds"
+
+	query, args, err := o.queryBuilder.From("refunds").
+		Select("id", "payment_refund_id", "status", "price", "reason", "created_at", "updated_at").
+		Where(goqu.Ex{"order_id": orderId}).
+		Order(goqu.C("created_at").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := o.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	refunds := make([]models.OrderRefund, 0)
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		refund := models.OrderRefund{OrderID: orderId}
+		var reason *string
+
+		err = rows.Scan(
+			&refund.ID,
+			&refund.PaymentRefundID,
+			&refund.Status,
+			&refund.Price,
+			&reason,
+			&refund.CreatedAt,
+			&refund.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if reason != nil {
+			refund.Reason = *reason
+		}
+
+		refunds = append(refunds, refund)
+		ids = append(ids, refund.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(refunds) == 0 {
+		return refunds, nil
+	}
+
+	items, err := o.refundItems(ctx, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range refunds {
+		refunds[i].Items = items[refunds[i].ID]
+	}
+
+	return refunds, nil
+}
+
+func (o *OrderRepository) refundItems(ctx context.Context, refundIds ...uuid.UUID) (map[uuid.UUID][]models.OrderRefundItem, error) {
+	const op = "repository.postgres.order.refundItems"
+
+	query, args, err := o.queryBuilder.From("refund_items").
+		Select("id", "refund_id", "order_item_id", "quantity", "price").
+		Where(goqu.Ex{"refund_id": refundIds}).
+		Order(goqu.C("id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := o.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	items := make(map[uuid.UUID][]models.OrderRefundItem, len(refundIds))
+	for rows.Next() {
+		var item models.OrderRefundItem
+		var refundId uuid.UUID
+
+		err = rows.Scan(&item.ID, &refundId, &item.OrderItemID, &item.Quantity, &item.Price)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		items[refundId] = append(items[refundId], item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return items, nil
+}
+
+func (o *OrderRepository) orderItems(ctx context.Context, orderIds ...uuid.UUID) (map[uuid.UUID][]models.OrderItem, error) {
+	const op = "repository.postgres.order.orderItems"
+
+	query, args, err := o.queryBuilder.From("order_items").
+		Select(
+			"id", "order_id", "product_id", "variant_id", "name", "price",
+			"discount", "size", "quantity", "refunded_quantity", "coupon_discount",
+		).
+		Where(goqu.Ex{"order_id": orderIds}).
+		Order(goqu.C("id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := o.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	items := make(map[uuid.UUID][]models.OrderItem, len(orderIds))
+	for rows.Next() {
+		var item models.OrderItem
+		var orderId uuid.UUID
+
+		err = rows.Scan(
+			&item.ID,
+			&orderId,
+			&item.ProductID,
+			&item.VariantID,
+			&item.Name,
+			&item.Price,
+			&item.Discount,
+			&item.Size,
+			&item.Quantity,
+			&item.RefundedQuantity,
+			&item.CouponDiscount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		items[orderId] = append(items[orderId], item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return items, nil
+}
+
+// checkCouponUsage locks coupon till the end of tx and checks that user can make one more order with it
+func checkCouponUsage(ctx context.Context, tx pgx.Tx, couponId, userId uuid.UUID) error {
+	const op = "repository.postgres.order.checkCouponUsage"
+
+	var usageLimit, usageLimitPerUser *int
+	err := tx.QueryRow(
+		ctx,
+		"SELECT usage_limit, usage_limit_per_user FROM coupons WHERE id = $1 FOR UPDATE",
+		couponId,
+	).Scan(&usageLimit, &usageLimitPerUser)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, errs.ErrCouponNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if usageLimit == nil && usageLimitPerUser == nil {
+		return nil
+	}
+
+	query := `SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2)
+			  FROM orders
+			  WHERE coupon_id = $1 AND status <> 'cancelled'`
+
+	var total, byUser int
+	err = tx.QueryRow(ctx, query, couponId, userId).Scan(&total, &byUser)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if (usageLimit != nil && total >= *usageLimit) || (usageLimitPerUser != nil && byUser >= *usageLimitPerUser) {
+		return fmt.Errorf("%s: %w", op, errs.ErrCouponUsageLimit)
+	}
+
+	return nil
+}
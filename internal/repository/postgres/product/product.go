@@ -5,9 +5,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/AlexMickh/shop-backend/internal/errs"
 	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
 	"github.com/doug-martin/goqu/v9"
 	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
 	"github.com/doug-martin/goqu/v9/exp"
@@ -20,6 +23,7 @@ type DB interface {
 	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
 type ProductRepository struct {
@@ -37,11 +41,17 @@ func New(db DB) *ProductRepository {
 func (p *ProductRepository) SaveProduct(ctx context.Context, product *models.Product) error {
 	const op = "repository.postgres.product.SaveProduct"
 
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
 	query := `INSERT INTO products 
-			  (id, category_id, name, description, price, quantity, existing_sizes, image_url)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+			  (id, category_id, name, description, price, image_url, thumb_url)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
-	_, err := p.db.Exec(
+	_, err = tx.Exec(
 		ctx,
 		query,
 		product.ID,
@@ -49,14 +59,79 @@ func (p *ProductRepository) SaveProduct(ctx context.Context, product *models.Pro
 		product.Name,
 		product.Description,
 		product.Price,
-		product.Quantity,
-		product.ExistingSizes,
 		product.ImageUrl,
+		product.ThumbUrl,
 	)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	err = savePrices(ctx, tx, time.Now(), product.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(product.Images) != 0 {
+		rows := make([]any, 0, len(product.Images))
+		for i, v := range product.Images {
+			rows = append(rows, goqu.Record{
+				"id":         v.ID,
+				"product_id": product.ID,
+				"url":        v.Url,
+				"medium_url": v.MediumUrl,
+				"thumb_url":  v.ThumbUrl,
+				"position":   i,
+			})
+		}
+
+		insert, args, err := p.queryBuilder.Insert("product_images").
+			Rows(rows...).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if _, err = tx.Exec(ctx, insert, args...); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if len(product.Variants) != 0 {
+		rows := make([]any, 0, len(product.Variants))
+		for _, v := range product.Variants {
+			rows = append(rows, goqu.Record{
+				"product_id": product.ID,
+				"size":       v.Size,
+				"stock":      v.Stock,
+				"sku":        v.Sku,
+				"barcode":    v.Barcode,
+			})
+		}
+
+		insert, args, err := p.queryBuilder.Insert("product_variants").
+			Rows(rows...).
+			ToSQL()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		_, err = tx.Exec(ctx, insert, args...)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				if pgErr.Code == "23505" {
+					return fmt.Errorf("%s: %w", op, errs.ErrVariantAlreadyExists)
+				}
+			}
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
@@ -65,9 +140,9 @@ func (p *ProductRepository) ProductById(ctx context.Context, id uuid.UUID) (*mod
 
 	query, args, err := p.queryBuilder.From("products").
 		Select(
-			"products.name", "products.description", "products.price", "products.quantity",
-			"products.existing_sizes", "products.image_url", "products.discount",
-			"products.discount_expires_at", "categories.id", "categories.name",
+			"products.name", "products.description", "products.price", "products.image_url",
+			"products.discount", "products.discount_starts_at", "products.discount_expires_at",
+			"categories.id", "categories.name",
 		).
 		Join(
 			goqu.T("categories"),
@@ -79,17 +154,14 @@ func (p *ProductRepository) ProductById(ctx context.Context, id uuid.UUID) (*mod
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	fmt.Println(query)
-
 	product := new(models.Product)
 	err = p.db.QueryRow(ctx, query, args...).Scan(
 		&product.Name,
 		&product.Description,
 		&product.Price,
-		&product.Quantity,
-		&product.ExistingSizes,
 		&product.ImageUrl,
 		&product.Discount,
+		&product.DiscountStartsAt,
 		&product.DiscountExpiresAt,
 		&product.Category.ID,
 		&product.Category.Name,
@@ -104,58 +176,83 @@ func (p *ProductRepository) ProductById(ctx context.Context, id uuid.UUID) (*mod
 
 	product.ID = id
 
+	product.Variants, err = p.Variants(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	product.Images, err = p.ProductImages(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, v := range product.Variants {
+		product.Quantity += v.Stock
+		if v.Stock > 0 {
+			product.ExistingSizes = append(product.ExistingSizes, v.Size)
+		}
+	}
+
 	return product, nil
 }
 
-func (p *ProductRepository) ProductCards(
-	ctx context.Context,
-	page int,
-	popularity bool,
-	price int,
-	categoryId uuid.UUID,
-	search string,
-) ([]models.ProductCard, error) {
+// ProductCards returns page of products in q.Sort order, page is empty if nothing is found.
+// One more product than limit is selected to know if there is next page.
+func (p *ProductRepository) ProductCards(ctx context.Context, q models.ProductCardsQuery) (models.ProductCardsPage, error) {
 	const op = "repository.postgres.product.ProductCards"
 
-	filter := goqu.Ex{}
-	groupBy := make([]exp.OrderedExpression, 0)
-
-	if categoryId != uuid.Max {
-		filter["category_id"] = categoryId
+	key := sortKey(q.Sort, q.ProductFilter)
+	order := []exp.OrderedExpression{key.Asc(), goqu.I("products.id").Asc()}
+	if q.Sort.Desc() {
+		order = []exp.OrderedExpression{key.Desc(), goqu.I("products.id").Desc()}
 	}
 
-	if search != "" {
-		filter["name"] = goqu.Op{"like": search}
+	rank, snippet := goqu.L("0"), goqu.L("''")
+	if q.Search != "" {
+		rank = searchRank(q.Search)
+		snippet = goqu.L(
+			"ts_headline('russian', COALESCE(products.description, ''), ?, ?)",
+			searchQuery(q.Search),
+			"StartSel=<b>, StopSel=</b>, MaxWords=20, MinWords=5, MaxFragments=2",
+		)
 	}
 
-	if popularity {
-		groupBy = append(groupBy, goqu.C("pieces_sold").Desc())
-	}
+	ds := p.queryBuilder.From("products").
+		Select(
+			"products.id", "products.name", "products.price", "products.thumb_url",
+			goqu.L("COALESCE(products.pieces_sold, 0)"), "products.discount",
+			"products.discount_starts_at", "products.discount_expires_at", "products.created_at",
+			rank, snippet,
+		).
+		Where(p.filter(q.ProductFilter, true, true)...).
+		Order(order...).
+		Limit(uint(q.Limit + 1))
+
+	if q.After != nil {
+		var after any = q.After.Key
+		if q.Sort == models.SortNewest {
+			after = q.After.CreatedAt
+		}
 
-	switch price {
-	case 1:
-		groupBy = append(groupBy, goqu.C("price - price / 100 * discount").Desc())
-	case 0:
-		groupBy = append(groupBy, goqu.C("price - price / 100 * discount").Asc())
+		if q.Sort.Desc() {
+			ds = ds.Where(goqu.L("(?, products.id) < (?, ?)", key, after, q.After.ID))
+		} else {
+			ds = ds.Where(goqu.L("(?, products.id) > (?, ?)", key, after, q.After.ID))
+		}
 	}
 
-	query, args, err := p.queryBuilder.From("products").
-		Select("id", "name", "price", "image_url", "discount", "discount_expires_at").
-		Where(filter).
-		Limit(10).
-		Offset(uint(page * 10)).
-		ToSQL()
+	query, args, err := ds.ToSQL()
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	rows, err := p.db.Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	products := make([]models.ProductCard, 0)
+	products := make([]models.ProductCard, 0, q.Limit+1)
 	for rows.Next() {
 		var product models.ProductCard
 
@@ -164,71 +261,298 @@ func (p *ProductRepository) ProductCards(
 			&product.Name,
 			&product.Price,
 			&product.ImageUrl,
+			&product.PiecesSold,
 			&product.Discount,
+			&product.DiscountStartsAt,
 			&product.DiscountExpiresAt,
+			&product.CreatedAt,
+			&product.SearchRank,
+			&product.Snippet,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
 		}
 
 		products = append(products, product)
 	}
-	if rows.Err() != nil {
+	if err = rows.Err(); err != nil {
+		return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := models.ProductCardsPage{Cards: products}
+	if len(products) > q.Limit {
+		page.Cards = products[:q.Limit]
+		page.HasMore = true
+	}
+
+	return page, nil
+}
+
+// ProductNames returns id, name and sold pieces of every product, it's used to build suggestions index
+func (p *ProductRepository) ProductNames(ctx context.Context) ([]models.ProductCard, error) {
+	const op = "repository.postgres.product.ProductNames"
+
+	query, _, err := p.queryBuilder.From("products").
+		Select("id", "name", goqu.L("COALESCE(pieces_sold, 0)")).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	defer rows.Close()
+
+	products := make([]models.ProductCard, 0)
+	for rows.Next() {
+		var product models.ProductCard
+
+		err = rows.Scan(&product.ID, &product.Name, &product.PiecesSold)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
 
-	if len(products) == 0 {
-		return nil, fmt.Errorf("%s: %w", op, errs.ErrProductNotFound)
+		products = append(products, product)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return products, nil
 }
 
+// ProductFacets counts products matching filter for every size and category
+func (p *ProductRepository) ProductFacets(ctx context.Context, f models.ProductFilter) (*models.ProductFacets, error) {
+	const op = "repository.postgres.product.ProductFacets"
+
+	sizeFilter := p.filter(f, false, true)
+	if f.InStock {
+		sizeFilter = append(sizeFilter, goqu.C("stock").Table("product_variants").Gt(0))
+	}
+
+	query, args, err := p.queryBuilder.From("products").
+		Select("product_variants.size", goqu.COUNT(goqu.DISTINCT("products.id"))).
+		Join(
+			goqu.T("product_variants"),
+			goqu.On(goqu.Ex{"product_variants.product_id": goqu.I("products.id")}),
+		).
+		Where(sizeFilter...).
+		GroupBy("product_variants.size").
+		Order(goqu.I("product_variants.size").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := p.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	facets := &models.ProductFacets{
+		Sizes:      make([]models.SizeFacet, 0),
+		Categories: make([]models.CategoryFacet, 0),
+	}
+	for rows.Next() {
+		var facet models.SizeFacet
+
+		if err = rows.Scan(&facet.Size, &facet.Count); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		facets.Sizes = append(facets.Sizes, facet)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query, args, err = p.queryBuilder.From("products").
+		Select("categories.id", "categories.name", goqu.COUNT("products.id")).
+		Join(
+			goqu.T("categories"),
+			goqu.On(goqu.Ex{"products.category_id": goqu.I("categories.id")}),
+		).
+		Where(p.filter(f, true, false)...).
+		GroupBy("categories.id", "categories.name").
+		Order(goqu.I("categories.name").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err = p.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var facet models.CategoryFacet
+
+		if err = rows.Scan(&facet.Category.ID, &facet.Category.Name, &facet.Count); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		facets.Categories = append(facets.Categories, facet)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return facets, nil
+}
+
+// filter returns conditions of f, sizes and category can be left out for counting their facets
+func (p *ProductRepository) filter(f models.ProductFilter, withSizes, withCategory bool) []exp.Expression {
+	conditions := make([]exp.Expression, 0)
+
+	// products of subcategories are shown in category too
+	if withCategory && f.CategoryID != uuid.Nil {
+		conditions = append(conditions, goqu.L(
+			`products.category_id IN (
+			  	  WITH RECURSIVE tree AS (
+			  	  	  SELECT id FROM categories WHERE id = ?
+			  	  	  UNION ALL
+			  	  	  SELECT categories.id FROM categories JOIN tree ON categories.parent_id = tree.id
+			  	  )
+			  	  SELECT id FROM tree
+			  )`,
+			f.CategoryID,
+		))
+	}
+
+	// full text search, names with typos are found by trigrams
+	if f.Search != "" {
+		conditions = append(conditions, goqu.L(
+			"(products.search_vector @@ ? OR ? <% products.name)",
+			searchQuery(f.Search), f.Search,
+		))
+	}
+
+	if !f.MinPrice.IsZero() {
+		conditions = append(conditions, goqu.L("? >= ?", effectivePrice(f.Now), f.MinPrice.Amount))
+	}
+	if !f.MaxPrice.IsZero() {
+		conditions = append(conditions, goqu.L("? <= ?", effectivePrice(f.Now), f.MaxPrice.Amount))
+	}
+
+	if f.OnSale {
+		conditions = append(conditions, goqu.L("? > 0", effectiveDiscount(f.Now)))
+	}
+
+	variants := goqu.Ex{}
+	if withSizes && len(f.Sizes) != 0 {
+		sizes := make([]string, 0, len(f.Sizes))
+		for _, v := range f.Sizes {
+			sizes = append(sizes, string(v))
+		}
+		variants["size"] = sizes
+	}
+	if f.InStock {
+		variants["stock"] = goqu.Op{"gt": 0}
+	}
+	if withSizes && len(variants) != 0 {
+		conditions = append(conditions, goqu.I("products.id").In(
+			p.queryBuilder.From("product_variants").Select("product_id").Where(variants),
+		))
+	}
+
+	return conditions
+}
+
+// sortKey returns expression products are sorted by, it must give the same value
+// as models.NewProductCursor
+func sortKey(sort models.ProductSort, f models.ProductFilter) exp.LiteralExpression {
+	switch sort {
+	case models.SortPopular:
+		return goqu.L("COALESCE(products.pieces_sold, 0)")
+	case models.SortPriceAsc, models.SortPriceDesc:
+		return effectivePrice(f.Now)
+	case models.SortDiscount:
+		return effectiveDiscount(f.Now)
+	case models.SortRelevance:
+		return searchRank(f.Search)
+	default:
+		return goqu.L("products.created_at")
+	}
+}
+
+// searchQuery matches words of search in russian and english forms,
+// search can have quotes, or and minus like in web search engines
+func searchQuery(search string) exp.LiteralExpression {
+	return goqu.L("(websearch_to_tsquery('russian', ?) || websearch_to_tsquery('english', ?))", search, search)
+}
+
+// searchRank is integer, so it can be compared in cursor without rounding problems
+func searchRank(search string) exp.LiteralExpression {
+	return goqu.L(
+		"((ts_rank_cd(products.search_vector, ?) + word_similarity(?, products.name)) * 1000000)::bigint",
+		searchQuery(search), search,
+	)
+}
+
+// effectivePrice is price with discount active at now, rounded half up like in money.ApplyDiscount
+func effectivePrice(now time.Time) exp.LiteralExpression {
+	return goqu.L("(products.price::bigint * (100 - ?) + 50) / 100", effectiveDiscount(now))
+}
+
+func effectiveDiscount(now time.Time) exp.LiteralExpression {
+	return goqu.L(
+		`CASE WHEN (products.discount_starts_at IS NULL OR products.discount_starts_at <= ?)
+			  AND (products.discount_expires_at IS NULL OR products.discount_expires_at > ?)
+		 THEN COALESCE(products.discount, 0) ELSE 0 END`,
+		now, now,
+	)
+}
+
+// UpdateProduct changes only set fields: not empty strings, price and discount other than -1
+// and not nil dates
 func (p *ProductRepository) UpdateProduct(ctx context.Context, productToUpdate *models.Product) error {
 	const op = "repository.postgres.product.UpdateProduct"
 
-	// query, args, err := p.queryBuilder.Update("products").
-	// 	Set(goqu.Record{
-	// 		"name":                productToUpdate.Name,
-	// 		"description":         productToUpdate.Description,
-	// 		"price":               productToUpdate.Price,
-	// 		"quantity":            productToUpdate.Quantity,
-	// 		"existing_sizes":      productToUpdate.ExistingSizes,
-	// 		"image_url":           productToUpdate.ImageUrl,
-	// 		"discount":            productToUpdate.Discount,
-	// 		"discount_expires_at": productToUpdate.DiscountExpiresAt,
-	// 		"updated_at":          time.Now(),
-	// 	}).
-	// 	Where(goqu.Ex{"id": productToUpdate.ID}).
-	// 	ToSQL()
-	// if err != nil {
-	// 	return fmt.Errorf("%s: %w", op, err)
-	// }
+	now := time.Now()
+	record := goqu.Record{"updated_at": now}
 
-	query := `UPDATE products
-			  SET name = $1, 
-			  	  description = $2, 
-				  price = $3, 
-				  quantity = $4, 
-				  existing_sizes = $5, 
-				  image_url = $6, 
-				  discount = $7, 
-				  discount_expires_at = $8
-			  WHERE id = $9`
-
-	result, err := p.db.Exec(
-		ctx,
-		query,
-		productToUpdate.Name,
-		productToUpdate.Description,
-		productToUpdate.Price,
-		productToUpdate.Quantity,
-		productToUpdate.ExistingSizes,
-		productToUpdate.ImageUrl,
-		productToUpdate.Discount,
-		productToUpdate.DiscountExpiresAt,
-		productToUpdate.ID,
-	)
+	if productToUpdate.Name != "" {
+		record["name"] = productToUpdate.Name
+	}
+	if productToUpdate.Description != "" {
+		record["description"] = productToUpdate.Description
+	}
+	if productToUpdate.Price.Amount != -1 {
+		record["price"] = productToUpdate.Price
+	}
+	if productToUpdate.ImageUrl != "" {
+		record["image_url"] = productToUpdate.ImageUrl
+	}
+	if productToUpdate.Discount != -1 {
+		record["discount"] = productToUpdate.Discount
+	}
+	if productToUpdate.DiscountStartsAt != nil {
+		record["discount_starts_at"] = productToUpdate.DiscountStartsAt
+	}
+	if productToUpdate.DiscountExpiresAt != nil {
+		record["discount_expires_at"] = productToUpdate.DiscountExpiresAt
+	}
+
+	query, args, err := p.queryBuilder.Update("products").
+		Set(record).
+		Where(goqu.Ex{"id": productToUpdate.ID}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -237,21 +561,768 @@ func (p *ProductRepository) UpdateProduct(ctx context.Context, productToUpdate *
 		return fmt.Errorf("%s: %w", op, errs.ErrProductNotFound)
 	}
 
+	// discount changes the price too, so it's saved after any update
+	err = savePrices(ctx, tx, now, productToUpdate.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
-func (p *ProductRepository) DeleteProduct(ctx context.Context, id uuid.UUID) error {
-	const op = "repository.postgres.product.DeleteProduct"
+// savePrices writes prices of products with discount active at now to history
+// if they differ from the last written ones, so discounts get into history too
+func savePrices(ctx context.Context, tx pgx.Tx, now time.Time, productIds ...uuid.UUID) error {
+	const op = "repository.postgres.product.savePrices"
 
-	query, args, err := p.queryBuilder.Delete("products").
-		Where(goqu.Ex{"id": id}).
+	if len(productIds) == 0 {
+		return nil
+	}
+
+	// price can't be rounded down to zero, history doesn't allow it
+	query := `INSERT INTO price_history (product_id, price, changed_at)
+			  SELECT id, price, $2 FROM (
+			  	  SELECT id, GREATEST((price::bigint * (100 - CASE
+			  	  	  WHEN (discount_starts_at IS NULL OR discount_starts_at <= $2)
+			  	  	  AND (discount_expires_at IS NULL OR discount_expires_at > $2)
+			  	  	  THEN COALESCE(discount, 0) ELSE 0 END) + 50) / 100, 1) AS price
+			  	  FROM products
+			  	  WHERE id = ANY($1)
+			  ) AS effective
+			  WHERE price IS DISTINCT FROM (
+			  	  SELECT price_history.price FROM price_history
+			  	  WHERE price_history.product_id = effective.id
+			  	  ORDER BY price_history.changed_at DESC LIMIT 1
+			  )`
+
+	_, err := tx.Exec(ctx, query, productIds, now)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// LowestPrice returns the lowest price with discounts product had during period before its
+// current price was set, including the price which was already set when period started.
+// If product had no other price, current one is returned.
+func (p *ProductRepository) LowestPrice(ctx context.Context, productId uuid.UUID, period time.Duration) (money.Money, error) {
+	const op = "repository.postgres.product.LowestPrice"
+
+	query := `WITH current AS (
+			  	  SELECT price, changed_at, changed_at - make_interval(secs => $2) AS since
+			  	  FROM price_history WHERE product_id = $1
+			  	  ORDER BY changed_at DESC LIMIT 1
+			  )
+			  SELECT COALESCE((
+			  	  SELECT MIN(price_history.price) FROM price_history
+			  	  WHERE price_history.product_id = $1
+			  	    AND price_history.changed_at < current.changed_at
+			  	    AND price_history.changed_at >= COALESCE((
+			  	  	  SELECT MAX(changed_at) FROM price_history
+			  	  	  WHERE product_id = $1 AND changed_at <= current.since
+			  	    ), current.since)
+			  ), current.price)
+			  FROM current`
+
+	var price money.Money
+	err := p.db.QueryRow(ctx, query, productId, period.Seconds()).Scan(&price)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return money.Money{}, fmt.Errorf("%s: %w", op, errs.ErrProductNotFound)
+		}
+
+		return money.Money{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return price, nil
+}
+
+func (p *ProductRepository) SaveScheduledPrice(ctx context.Context, price *models.ScheduledPrice) error {
+	const op = "repository.postgres.product.SaveScheduledPrice"
+
+	query, args, err := p.queryBuilder.Insert("scheduled_prices").
+		Rows(goqu.Record{
+			"product_id": price.ProductID,
+			"price":      price.Price,
+			"starts_at":  price.StartsAt,
+		}).
+		Returning("id", "created_at").
 		ToSQL()
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = p.db.Exec(ctx, query, args...)
+	err = p.db.QueryRow(ctx, query, args...).Scan(&price.ID, &price.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == "23503" {
+				return fmt.Errorf("%s: %w", op, errs.ErrProductNotFound)
+			}
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ApplyScheduledPrices sets prices which start time has come and writes them to history.
+// If several changes of one product are due, only the latest one is applied.
+// Returns number of changed products.
+func (p *ProductRepository) ApplyScheduledPrices(ctx context.Context, now time.Time) (int64, error) {
+	const op = "repository.postgres.product.ApplyScheduledPrices"
+
+	query := `WITH due AS (
+			  	  UPDATE scheduled_prices
+			  	  SET applied_at = $1
+			  	  WHERE applied_at IS NULL AND starts_at <= $1
+			  	  RETURNING product_id, price, starts_at
+			  ), latest AS (
+			  	  SELECT DISTINCT ON (product_id) product_id, price
+			  	  FROM due
+			  	  ORDER BY product_id, starts_at DESC
+			  )
+			  UPDATE products
+			  SET price = latest.price, updated_at = $1
+			  FROM latest
+			  WHERE products.id = latest.product_id
+			  RETURNING products.id`
+
+	count, err := p.updateWithHistory(ctx, now, query, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// ExpireDiscounts removes discounts expired before now
+func (p *ProductRepository) ExpireDiscounts(ctx context.Context, now time.Time) (int64, error) {
+	const op = "repository.postgres.product.ExpireDiscounts"
+
+	query, args, err := p.queryBuilder.Update("products").
+		Set(goqu.Record{
+			"discount":            0,
+			"discount_starts_at":  nil,
+			"discount_expires_at": nil,
+			"updated_at":          now,
+		}).
+		Where(goqu.C("discount_expires_at").Lte(now)).
+		Returning("id").
+		ToSQL()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	count, err := p.updateWithHistory(ctx, now, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// StartDiscounts clears start date of discounts started before now,
+// after that they're applied without checking it
+func (p *ProductRepository) StartDiscounts(ctx context.Context, now time.Time) (int64, error) {
+	const op = "repository.postgres.product.StartDiscounts"
+
+	query, args, err := p.queryBuilder.Update("products").
+		Set(goqu.Record{"discount_starts_at": nil, "updated_at": now}).
+		Where(goqu.C("discount_starts_at").Lte(now)).
+		Returning("id").
+		ToSQL()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	count, err := p.updateWithHistory(ctx, now, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// updateWithHistory runs update returning ids of changed products and writes their new prices
+// to history in the same transaction. Returns number of changed products.
+func (p *ProductRepository) updateWithHistory(ctx context.Context, now time.Time, query string, args ...any) (int64, error) {
+	const op = "repository.postgres.product.updateWithHistory"
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, query, args...)
 	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = savePrices(ctx, tx, now, ids...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int64(len(ids)), nil
+}
+
+func (p *ProductRepository) DeleteProduct(ctx context.Context, id uuid.UUID) error {
+	const op = "repository.postgres.product.DeleteProduct"
+
+	query, args, err := p.queryBuilder.Delete("products").
+		Where(goqu.Ex{"id": id}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = p.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReserveStock subtracts reserved pieces from variants stock. Update is conditional,
+// so when two buyers want the last piece only one of them gets it.
+func (p *ProductRepository) ReserveStock(
+	ctx context.Context,
+	orderId uuid.UUID,
+	items []models.StockReservation,
+	expiresAt time.Time,
+) error {
+	const op = "repository.postgres.product.ReserveStock"
+
+	// the same locking order for all transactions, otherwise two orders can deadlock
+	items = slices.Clone(items)
+	slices.SortFunc(items, func(a, b models.StockReservation) int {
+		return slices.Compare(a.VariantID[:], b.VariantID[:])
+	})
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE product_variants
+			  SET stock = stock - $1
+			  WHERE id = $2 AND product_id = $3 AND stock >= $1`
+
+	for _, v := range items {
+		result, err := tx.Exec(ctx, query, v.Quantity, v.VariantID, v.ProductID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if result.RowsAffected() == 0 {
+			return fmt.Errorf("%s: variant %s: %w", op, v.VariantID, errs.ErrNotEnoughStock)
+		}
+	}
+
+	rows := make([]any, 0, len(items))
+	for _, v := range items {
+		rows = append(rows, goqu.Record{
+			"order_id":   orderId,
+			"product_id": v.ProductID,
+			"variant_id": v.VariantID,
+			"quantity":   v.Quantity,
+			"expires_at": expiresAt,
+		})
+	}
+
+	insert, args, err := p.queryBuilder.Insert("stock_reservations").
+		Rows(rows...).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.Exec(ctx, insert, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReleaseStock returns pieces of active reservations of the order back to stock
+func (p *ProductRepository) ReleaseStock(ctx context.Context, orderId uuid.UUID) error {
+	const op = "repository.postgres.product.ReleaseStock"
+
+	query := `WITH released AS (
+			  	  UPDATE stock_reservations
+			  	  SET status = 'released', updated_at = $2
+			  	  WHERE order_id = $1 AND status = 'active'
+			  	  RETURNING variant_id, quantity
+			  )
+			  UPDATE product_variants
+			  SET stock = product_variants.stock + r.quantity
+			  FROM (SELECT variant_id, SUM(quantity) AS quantity FROM released GROUP BY variant_id) AS r
+			  WHERE product_variants.id = r.variant_id`
+
+	_, err := p.db.Exec(ctx, query, orderId, time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CommitStock turns reservations of the order into sold pieces. Released reservation
+// isn't committed, because its pieces could be sold to someone else, ErrReservationExpired is returned.
+// It's safe to call it several times.
+func (p *ProductRepository) CommitStock(ctx context.Context, orderId uuid.UUID) error {
+	const op = "repository.postgres.product.CommitStock"
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `WITH committed AS (
+			  	  UPDATE stock_reservations
+			  	  SET status = 'committed', updated_at = $2
+			  	  WHERE order_id = $1 AND status = 'active'
+			  	  RETURNING product_id, quantity
+			  )
+			  UPDATE products
+			  SET pieces_sold = COALESCE(products.pieces_sold, 0) + r.quantity
+			  FROM (SELECT product_id, SUM(quantity) AS quantity FROM committed GROUP BY product_id) AS r
+			  WHERE products.id = r.product_id`
+
+	_, err = tx.Exec(ctx, query, orderId, time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var released bool
+	err = tx.QueryRow(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM stock_reservations WHERE order_id = $1 AND status = 'released')",
+		orderId,
+	).Scan(&released)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if released {
+		return fmt.Errorf("%s: %w", op, errs.ErrReservationExpired)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *ProductRepository) SaveVariant(ctx context.Context, variant *models.ProductVariant) error {
+	const op = "repository.postgres.product.SaveVariant"
+
+	query, args, err := p.queryBuilder.Insert("product_variants").
+		Rows(goqu.Record{
+			"product_id": variant.ProductID,
+			"size":       variant.Size,
+			"stock":      variant.Stock,
+			"sku":        variant.Sku,
+			"barcode":    variant.Barcode,
+		}).
+		Returning("id", "created_at").
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = p.db.QueryRow(ctx, query, args...).Scan(&variant.ID, &variant.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				return fmt.Errorf("%s: %w", op, errs.ErrVariantAlreadyExists)
+			case "23503":
+				return fmt.Errorf("%s: %w", op, errs.ErrProductNotFound)
+			}
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *ProductRepository) VariantById(ctx context.Context, productId, id uuid.UUID) (*models.ProductVariant, error) {
+	const op = "repository.postgres.product.VariantById"
+
+	query, args, err := p.queryBuilder.From("product_variants").
+		Select("id", "product_id", "size", "stock", "sku", "barcode", "created_at", "updated_at").
+		Where(goqu.Ex{"id": id, "product_id": productId}).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	variant := new(models.ProductVariant)
+	err = p.db.QueryRow(ctx, query, args...).Scan(
+		&variant.ID,
+		&variant.ProductID,
+		&variant.Size,
+		&variant.Stock,
+		&variant.Sku,
+		&variant.Barcode,
+		&variant.CreatedAt,
+		&variant.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, errs.ErrVariantNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return variant, nil
+}
+
+// Variants returns all sizes of product, including sold out ones
+func (p *ProductRepository) Variants(ctx context.Context, productId uuid.UUID) ([]models.ProductVariant, error) {
+	const op = "repository.postgres.product.Variants"
+
+	query, args, err := p.queryBuilder.From("product_variants").
+		Select("id", "product_id", "size", "stock", "sku", "barcode", "created_at", "updated_at").
+		Where(goqu.Ex{"product_id": productId}).
+		Order(goqu.C("id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := p.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	variants := make([]models.ProductVariant, 0)
+	for rows.Next() {
+		var variant models.ProductVariant
+
+		err = rows.Scan(
+			&variant.ID,
+			&variant.ProductID,
+			&variant.Size,
+			&variant.Stock,
+			&variant.Sku,
+			&variant.Barcode,
+			&variant.CreatedAt,
+			&variant.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		variants = append(variants, variant)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return variants, nil
+}
+
+func (p *ProductRepository) UpdateVariant(ctx context.Context, variant *models.ProductVariant) error {
+	const op = "repository.postgres.product.UpdateVariant"
+
+	query, args, err := p.queryBuilder.Update("product_variants").
+		Set(goqu.Record{
+			"size":       variant.Size,
+			"stock":      variant.Stock,
+			"sku":        variant.Sku,
+			"barcode":    variant.Barcode,
+			"updated_at": time.Now(),
+		}).
+		Where(goqu.Ex{"id": variant.ID, "product_id": variant.ProductID}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := p.db.Exec(ctx, query, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == "23505" {
+				return fmt.Errorf("%s: %w", op, errs.ErrVariantAlreadyExists)
+			}
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, errs.ErrVariantNotFound)
+	}
+
+	return nil
+}
+
+func (p *ProductRepository) DeleteVariant(ctx context.Context, productId, id uuid.UUID) error {
+	const op = "repository.postgres.product.DeleteVariant"
+
+	query, args, err := p.queryBuilder.Delete("product_variants").
+		Where(goqu.Ex{"id": id, "product_id": productId}).
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := p.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, errs.ErrVariantNotFound)
+	}
+
+	return nil
+}
+
+// ProductImages returns gallery of product, the first image is the main one
+func (p *ProductRepository) ProductImages(ctx context.Context, productId uuid.UUID) ([]models.ProductImage, error) {
+	const op = "repository.postgres.product.ProductImages"
+
+	query, args, err := p.queryBuilder.From("product_images").
+		Select("id", "product_id", "url", "medium_url", "thumb_url", "position", "created_at").
+		Where(goqu.Ex{"product_id": productId}).
+		Order(goqu.C("position").Asc(), goqu.C("id").Asc()).
+		ToSQL()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := p.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	images := make([]models.ProductImage, 0)
+	for rows.Next() {
+		var image models.ProductImage
+
+		err = rows.Scan(
+			&image.ID,
+			&image.ProductID,
+			&image.Url,
+			&image.MediumUrl,
+			&image.ThumbUrl,
+			&image.Position,
+			&image.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		images = append(images, image)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return images, nil
+}
+
+// SaveImage adds image to the end of gallery, main image is put first
+func (p *ProductRepository) SaveImage(ctx context.Context, image *models.ProductImage, main bool) error {
+	const op = "repository.postgres.product.SaveImage"
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// product is locked, so positions of images added at the same time don't collide
+	var id uuid.UUID
+	err = tx.QueryRow(ctx, "SELECT id FROM products WHERE id = $1 FOR UPDATE", image.ProductID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, errs.ErrProductNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if main {
+		_, err = tx.Exec(ctx, "UPDATE product_images SET position = position + 1 WHERE product_id = $1", image.ProductID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	} else {
+		query := `SELECT COALESCE(MAX(position) + 1, 0) FROM product_images WHERE product_id = $1`
+
+		err = tx.QueryRow(ctx, query, image.ProductID).Scan(&image.Position)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	query, args, err := p.queryBuilder.Insert("product_images").
+		Rows(goqu.Record{
+			"id":         image.ID,
+			"product_id": image.ProductID,
+			"url":        image.Url,
+			"medium_url": image.MediumUrl,
+			"thumb_url":  image.ThumbUrl,
+			"position":   image.Position,
+		}).
+		Returning("created_at").
+		ToSQL()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.QueryRow(ctx, query, args...).Scan(&image.CreatedAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = setMainImage(ctx, tx, image.ProductID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReorderImages sets positions of images in order of ids, ids must be all images of product
+func (p *ProductRepository) ReorderImages(ctx context.Context, productId uuid.UUID, ids []uuid.UUID) error {
+	const op = "repository.postgres.product.ReorderImages"
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE product_images
+			  SET position = o.position - 1
+			  FROM unnest($2::uuid[]) WITH ORDINALITY AS o(id, position)
+			  WHERE product_images.id = o.id AND product_images.product_id = $1`
+
+	result, err := tx.Exec(ctx, query, productId, ids)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.RowsAffected() != int64(len(ids)) {
+		return fmt.Errorf("%s: %w", op, errs.ErrImageNotFound)
+	}
+
+	if err = setMainImage(ctx, tx, productId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *ProductRepository) DeleteImage(ctx context.Context, productId, id uuid.UUID) (*models.ProductImage, error) {
+	const op = "repository.postgres.product.DeleteImage"
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `DELETE FROM product_images WHERE id = $1 AND product_id = $2
+			  RETURNING id, product_id, url, medium_url, thumb_url, position, created_at`
+
+	var image models.ProductImage
+	err = tx.QueryRow(ctx, query, id, productId).Scan(
+		&image.ID,
+		&image.ProductID,
+		&image.Url,
+		&image.MediumUrl,
+		&image.ThumbUrl,
+		&image.Position,
+		&image.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, errs.ErrImageNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = setMainImage(ctx, tx, productId); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &image, nil
+}
+
+// setMainImage copies urls of the first image to product, so cards are selected without joining images
+func setMainImage(ctx context.Context, tx pgx.Tx, productId uuid.UUID) error {
+	const op = "repository.postgres.product.setMainImage"
+
+	query := `UPDATE products
+			  SET (image_url, thumb_url) = (
+			  	  SELECT COALESCE(MAX(url), ''), COALESCE(MAX(thumb_url), '')
+			  	  FROM (
+			  	  	  SELECT url, thumb_url FROM product_images
+			  	  	  WHERE product_id = $1
+			  	  	  ORDER BY position, id
+			  	  	  LIMIT 1
+			  	  ) AS main
+			  )
+			  WHERE id = $1`
+
+	if _, err := tx.Exec(ctx, query, productId); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -3,7 +3,9 @@ package category_repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/AlexMickh/shop-backend/internal/errs"
 	"github.com/AlexMickh/shop-backend/internal/models"
@@ -86,3 +88,86 @@ func (c *CategoryRepository) AllCategories(ctx context.Context) ([]models.Catego
 
 	return categories, nil
 }
+
+// CategoryById returns category with count of its products, sqlite categories don't have subcategories
+func (c *CategoryRepository) CategoryById(ctx context.Context, id int64) (*models.Category, error) {
+	const op = "repository.sqlite.category.CategoryById"
+
+	query := `SELECT c.name, c.slug, c.description, COALESCE(c.image_url, ''), c.position, COUNT(p.id)
+			  FROM categories AS c
+			  LEFT JOIN products AS p ON p.category_id = c.id
+			  WHERE c.id = ?
+			  GROUP BY c.id`
+
+	var category models.Category
+	err := c.db.QueryRowContext(ctx, query, id).Scan(
+		&category.Name,
+		&category.Slug,
+		&category.Description,
+		&category.ImageUrl,
+		&category.Position,
+		&category.ProductCount,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, errs.ErrCategoryNotFound)
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &category, nil
+}
+
+// UpdateCategory saves not empty fields of category, position -1 means not changed
+func (c *CategoryRepository) UpdateCategory(ctx context.Context, id int64, category *models.Category) error {
+	const op = "repository.sqlite.category.UpdateCategory"
+
+	query := new(strings.Builder)
+	args := make([]any, 0)
+
+	query.WriteString("UPDATE categories SET")
+
+	if category.Name != "" {
+		query.WriteString(" name = ?,")
+		args = append(args, category.Name)
+	}
+
+	if category.Slug != "" {
+		query.WriteString(" slug = ?,")
+		args = append(args, category.Slug)
+	}
+
+	if category.Description != "" {
+		query.WriteString(" description = ?,")
+		args = append(args, category.Description)
+	}
+
+	if category.ImageUrl != "" {
+		query.WriteString(" image_url = ?,")
+		args = append(args, category.ImageUrl)
+	}
+
+	if category.Position != -1 {
+		query.WriteString(" position = ?,")
+		args = append(args, category.Position)
+	}
+
+	query.WriteString(" updated_at = datetime('now') WHERE id = ?")
+	args = append(args, id)
+
+	result, err := c.db.ExecContext(ctx, query.String(), args...)
+	if err != nil {
+		if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, errs.ErrCategoryAlreadyExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("%s: %w", op, errs.ErrCategoryNotFound)
+	}
+
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/AlexMickh/shop-backend/internal/errs"
 	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/google/uuid"
 	"github.com/mattn/go-sqlite3"
 )
 
@@ -91,7 +92,7 @@ func (p *ProductRepository) ProductById(ctx context.Context, id int64) (*models.
 	const op = "repository.sqlite.product.ProductById"
 
 	query := `SELECT p.name, p.description, p.price, p.quantity, p.existing_sizes, 
-			  		 p.image_url, p.discount, p.discount_expires_at, c.id, c.name
+			  		 p.image_url, p.discount, datetime(p.discount_expires_at), c.id, c.name
 			  FROM products AS p
 			  JOIN categories AS c ON c.id = p.category_id
 			  WHERE p.id = ?`
@@ -135,115 +136,171 @@ func (p *ProductRepository) ProductById(ctx context.Context, id int64) (*models.
 	return product, nil
 }
 
-func (p *ProductRepository) ProductCards(
-	ctx context.Context,
-	page int,
-	popularity bool,
-	price int,
-	categoryId int64,
-	search string,
-) ([]models.ProductCard, error) {
+// ProductCards returns page of products in q.Sort order, page is empty if nothing is found
+func (p *ProductRepository) ProductCards(ctx context.Context, q models.ProductCardsQuery) (models.ProductCardsPage, error) {
 	const op = "repository.sqlite.product.ProductCards"
 
+	// there is no ranking here, found products are shown from newest
+	if q.Sort == models.SortRelevance {
+		q.Sort = models.SortNewest
+	}
+
 	query := p.builderPool.Get().(*strings.Builder)
-	query.WriteString("SELECT p.id, p.name, p.price, p.image_url, p.discount, p.discount_expires_at FROM products AS p")
-	isFirst := true
-	isFirstWhere := true
+	defer func() {
+		query.Reset()
+		p.builderPool.Put(query)
+	}()
+
+	key, keyArgs := sortKey(q.Sort, q.Now)
 
-	args := make([]any, 0, 2)
+	query.WriteString("SELECT p.id, p.name, p.price, p.image_url, COALESCE(p.pieces_sold, 0), p.discount, datetime(p.discount_expires_at), p.created_at FROM products AS p WHERE 1 = 1")
 
-	if categoryId != -1 {
-		query.WriteString(" WHERE category_id = ?")
-		args = append(args, categoryId)
-		isFirstWhere = false
+	args := make([]any, 0, 8)
+
+	// products of subcategories aren't selected, categories here don't know their parents
+	if q.CategoryID != uuid.Nil {
+		query.WriteString(" AND p.category_id = ?")
+		args = append(args, q.CategoryID)
 	}
 
-	if search != "" {
-		if isFirstWhere {
-			query.WriteString(" WHERE name LIKE ? OR description LIKE ?")
-			args = append(args, search)
-			args = append(args, search)
-		} else {
-			query.WriteString(" AND name LIKE ? OR description LIKE ?")
-			args = append(args, search)
-			args = append(args, search)
-		}
+	if q.Search != "" {
+		query.WriteString(" AND (p.name LIKE ? OR p.description LIKE ?)")
+		args = append(args, q.Search, q.Search)
 	}
 
-	if popularity {
-		query.WriteString(" ORDER BY p.pieces_sold DESC")
-		isFirst = false
+	now := q.Now.UTC().Format("2006-01-02 15:04:05")
+
+	if !q.MinPrice.IsZero() {
+		query.WriteString(" AND " + effectivePrice + " >= ?")
+		args = append(args, now, q.MinPrice.Amount)
+	}
+	if !q.MaxPrice.IsZero() {
+		query.WriteString(" AND " + effectivePrice + " <= ?")
+		args = append(args, now, q.MaxPrice.Amount)
 	}
 
-	switch price {
-	case 1:
-		if isFirst {
-			query.WriteString(" ORDER BY (p.price - p.price / 100 * p.discount) DESC")
-			isFirst = false
-		} else {
-			query.WriteString(", (p.price - p.price / 100 * p.discount) DESC")
+	if q.OnSale {
+		query.WriteString(" AND " + effectiveDiscount + " > 0")
+		args = append(args, now)
+	}
+
+	// stock isn't tracked per size here, so in stock means any size is in stock
+	if q.InStock {
+		query.WriteString(" AND p.quantity > 0")
+	}
+
+	if len(q.Sizes) != 0 {
+		query.WriteString(" AND (")
+		for i, v := range q.Sizes {
+			if i != 0 {
+				query.WriteString(" OR ")
+			}
+			query.WriteString("(' ' || p.existing_sizes || ' ') LIKE ?")
+			args = append(args, "% "+string(v)+" %")
+		}
+		query.WriteString(")")
+	}
+
+	if q.After != nil {
+		var after any = q.After.Key
+		if q.Sort == models.SortNewest {
+			after = q.After.CreatedAt.UTC().Format("2006-01-02 15:04:05")
 		}
-	case 0:
-		if isFirst {
-			query.WriteString(" ORDER BY (p.price - p.price / 100 * p.discount)")
-			isFirst = false
+
+		if q.Sort.Desc() {
+			query.WriteString(" AND (" + key + ", p.id) < (?, ?)")
 		} else {
-			query.WriteString(", (p.price - p.price / 100 * p.discount)")
+			query.WriteString(" AND (" + key + ", p.id) > (?, ?)")
 		}
+		args = append(args, keyArgs...)
+		args = append(args, after, q.After.ID)
+	}
+
+	if q.Sort.Desc() {
+		query.WriteString(" ORDER BY " + key + " DESC, p.id DESC LIMIT ?")
+	} else {
+		query.WriteString(" ORDER BY " + key + ", p.id LIMIT ?")
 	}
+	args = append(args, keyArgs...)
+	args = append(args, q.Limit+1)
 
-	query.WriteString(" LIMIT 10 OFFSET ?")
-	args = append(args, page*10)
-	s := query.String()
-	fmt.Println(s)
-	cards := make([]models.ProductCard, 0)
-	rows, err := p.db.QueryContext(ctx, s, args...)
+	rows, err := p.db.QueryContext(ctx, query.String(), args...)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
-	query.Reset()
-	p.builderPool.Put(query)
-
+	cards := make([]models.ProductCard, 0, q.Limit+1)
 	for rows.Next() {
 		var card models.ProductCard
 		var discountExpiresAt *string
+		var createdAt string
 
 		err = rows.Scan(
 			&card.ID,
 			&card.Name,
 			&card.Price,
 			&card.ImageUrl,
+			&card.PiecesSold,
 			&card.Discount,
 			&discountExpiresAt,
+			&createdAt,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+			return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
 		}
 
 		if discountExpiresAt != nil && *discountExpiresAt != "" {
 			t, err := time.Parse("2006-01-02 15:04:05", *discountExpiresAt)
 			if err != nil {
-				return nil, fmt.Errorf("%s: %w", op, err)
+				return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
 			}
 			card.DiscountExpiresAt = &t
 		} else {
 			card.DiscountExpiresAt = nil
 		}
 
+		card.CreatedAt, err = time.Parse("2006-01-02 15:04:05", createdAt)
+		if err != nil {
+			return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
+		}
+
 		cards = append(cards, card)
 	}
 
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	if err = rows.Err(); err != nil {
+		return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if len(cards) == 0 {
-		return nil, fmt.Errorf("%s: %w", op, errs.ErrProductNotFound)
+	page := models.ProductCardsPage{Cards: cards}
+	if len(cards) > q.Limit {
+		page.Cards = cards[:q.Limit]
+		page.HasMore = true
 	}
 
-	return cards, nil
+	return page, nil
+}
+
+const (
+	// dates are compared by datetime, it brings stored text with any zone to UTC like now argument
+	effectiveDiscount = "(CASE WHEN p.discount_expires_at IS NULL OR p.discount_expires_at = '' OR datetime(p.discount_expires_at) > ? THEN COALESCE(p.discount, 0) ELSE 0 END)"
+	// discount is rounded half up like in money.ApplyDiscount
+	effectivePrice = "((p.price * (100 - " + effectiveDiscount + ") + 50) / 100)"
+)
+
+// sortKey returns expression products are sorted by and its arguments, it must give
+// the same value as models.NewProductCursor
+func sortKey(sort models.ProductSort, now time.Time) (string, []any) {
+	switch sort {
+	case models.SortPopular:
+		return "COALESCE(p.pieces_sold, 0)", nil
+	case models.SortPriceAsc, models.SortPriceDesc:
+		return effectivePrice, []any{now.UTC().Format("2006-01-02 15:04:05")}
+	case models.SortDiscount:
+		return effectiveDiscount, []any{now.UTC().Format("2006-01-02 15:04:05")}
+	default:
+		return "p.created_at", nil
+	}
 }
 
 func (p *ProductRepository) UpdateProduct(ctx context.Context, productToUpdate *models.Product) error {
@@ -264,7 +321,7 @@ func (p *ProductRepository) UpdateProduct(ctx context.Context, productToUpdate *
 		args = append(args, productToUpdate.Description)
 	}
 
-	if productToUpdate.Price != -1 {
+	if productToUpdate.Price.Amount != -1 {
 		query.WriteString(" price = ?,")
 		args = append(args, productToUpdate.Price)
 	}
@@ -299,10 +356,10 @@ func (p *ProductRepository) UpdateProduct(ctx context.Context, productToUpdate *
 
 	if productToUpdate.DiscountExpiresAt != nil {
 		query.WriteString(" discount_expires_at = ?,")
-		args = append(args, *productToUpdate.DiscountExpiresAt)
+		args = append(args, productToUpdate.DiscountExpiresAt.UTC().Format("2006-01-02 15:04:05"))
 	}
 
-	query.WriteString(" updated_at = datetime('now) WHERE id = ?")
+	query.WriteString(" updated_at = datetime('now') WHERE id = ?")
 	args = append(args, productToUpdate.ID)
 
 	result, err := p.db.ExecContext(ctx, query.String(), args...)
@@ -0,0 +1,369 @@
+package product_repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductRepository_ProductCards(t *testing.T) {
+	columns := []string{
+		"id", "name", "price", "image_url", "pieces_sold", "discount", "discount_expires_at", "created_at",
+	}
+
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	firstId := uuid.New()
+	secondId := uuid.New()
+	afterId := uuid.New()
+	filter := models.ProductFilter{Now: now}
+
+	const discount = "(CASE WHEN p.discount_expires_at IS NULL OR p.discount_expires_at = '' OR datetime(p.discount_expires_at) > ? THEN COALESCE(p.discount, 0) ELSE 0 END)"
+	const price = "((p.price * (100 - " + discount + ") + 50) / 100)"
+
+	tests := []struct {
+		name        string
+		query       models.ProductCardsQuery
+		rows        [][]driver.Value
+		wantQuery   string
+		wantArgs    []driver.Value
+		wantIds     []uuid.UUID
+		wantHasMore bool
+	}{
+		{
+			name:        "empty page case",
+			query:       models.ProductCardsQuery{Sort: models.SortNewest, Limit: 2, ProductFilter: filter},
+			wantQuery:   " ORDER BY p.created_at DESC, p.id DESC LIMIT ?",
+			wantArgs:    []driver.Value{3},
+			wantIds:     []uuid.UUID{},
+			wantHasMore: false,
+		},
+		{
+			name:  "last page case",
+			query: models.ProductCardsQuery{Sort: models.SortNewest, Limit: 2, ProductFilter: filter},
+			rows: [][]driver.Value{
+				{firstId, "coat", 10000, "url", 3, 0, nil, "2025-01-02 03:04:05"},
+			},
+			wantQuery:   " ORDER BY p.created_at DESC, p.id DESC LIMIT ?",
+			wantArgs:    []driver.Value{3},
+			wantIds:     []uuid.UUID{firstId},
+			wantHasMore: false,
+		},
+		{
+			name:  "has more case",
+			query: models.ProductCardsQuery{Sort: models.SortNewest, Limit: 1, ProductFilter: filter},
+			rows: [][]driver.Value{
+				{firstId, "coat", 10000, "url", 3, 0, nil, "2025-01-02 03:04:05"},
+				{secondId, "suit", 20000, "url", 1, 10, "2025-02-01 00:00:00", "2025-01-01 03:04:05"},
+			},
+			wantQuery:   " ORDER BY p.created_at DESC, p.id DESC LIMIT ?",
+			wantArgs:    []driver.Value{2},
+			wantIds:     []uuid.UUID{firstId},
+			wantHasMore: true,
+		},
+		{
+			name: "newest cursor case",
+			query: models.ProductCardsQuery{
+				Sort:          models.SortNewest,
+				Limit:         2,
+				ProductFilter: filter,
+				After: &models.ProductCursor{
+					Sort:      models.SortNewest,
+					CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+					ID:        afterId,
+				},
+			},
+			rows: [][]driver.Value{
+				{secondId, "suit", 20000, "url", 1, 10, "2025-02-01 00:00:00", "2025-01-01 03:04:05"},
+			},
+			wantQuery:   " AND (p.created_at, p.id) < (?, ?) ORDER BY p.created_at DESC, p.id DESC LIMIT ?",
+			wantArgs:    []driver.Value{"2025-01-02 03:04:05", afterId, 3},
+			wantIds:     []uuid.UUID{secondId},
+			wantHasMore: false,
+		},
+		{
+			name: "filters case",
+			query: models.ProductCardsQuery{
+				ProductFilter: models.ProductFilter{
+					MinPrice: money.Rub(10000),
+					MaxPrice: money.Rub(20000),
+					Sizes:    []models.ProductSize{models.SizeS, models.Size52},
+					InStock:  true,
+					OnSale:   true,
+					Now:      now,
+				},
+				Sort:  models.SortNewest,
+				Limit: 2,
+			},
+			rows: [][]driver.Value{
+				{secondId, "suit", 20000, "url", 1, 10, "2025-02-01 00:00:00", "2025-01-01 03:04:05"},
+			},
+			wantQuery: " WHERE 1 = 1 AND " + price + " >= ? AND " + price + " <= ? AND " + discount + " > 0" +
+				" AND p.quantity > 0 AND ((' ' || p.existing_sizes || ' ') LIKE ? OR (' ' || p.existing_sizes || ' ') LIKE ?)" +
+				" ORDER BY p.created_at DESC, p.id DESC LIMIT ?",
+			wantArgs: []driver.Value{
+				"2025-01-15 12:00:00", 10000, "2025-01-15 12:00:00", 20000, "2025-01-15 12:00:00", "% s %", "% 52 %", 3,
+			},
+			wantIds:     []uuid.UUID{secondId},
+			wantHasMore: false,
+		},
+		{
+			name:  "popular case",
+			query: models.ProductCardsQuery{Sort: models.SortPopular, Limit: 2, ProductFilter: filter},
+			rows: [][]driver.Value{
+				{firstId, "coat", 10000, "url", 3, 0, nil, "2025-01-02 03:04:05"},
+				{secondId, "suit", 20000, "url", 1, 10, "2025-02-01 00:00:00", "2025-01-01 03:04:05"},
+			},
+			wantQuery:   " ORDER BY COALESCE(p.pieces_sold, 0) DESC, p.id DESC LIMIT ?",
+			wantArgs:    []driver.Value{3},
+			wantIds:     []uuid.UUID{firstId, secondId},
+			wantHasMore: false,
+		},
+		{
+			name:  "price asc case",
+			query: models.ProductCardsQuery{Sort: models.SortPriceAsc, Limit: 2, ProductFilter: filter},
+			rows: [][]driver.Value{
+				{firstId, "coat", 10000, "url", 3, 0, nil, "2025-01-02 03:04:05"},
+				{secondId, "suit", 20000, "url", 1, 10, "2025-02-01 00:00:00", "2025-01-01 03:04:05"},
+			},
+			wantQuery:   " ORDER BY " + price + ", p.id LIMIT ?",
+			wantArgs:    []driver.Value{"2025-01-15 12:00:00", 3},
+			wantIds:     []uuid.UUID{firstId, secondId},
+			wantHasMore: false,
+		},
+		{
+			name: "price desc cursor case",
+			query: models.ProductCardsQuery{
+				Sort:          models.SortPriceDesc,
+				Limit:         2,
+				ProductFilter: filter,
+				After:         &models.ProductCursor{Sort: models.SortPriceDesc, Key: 18000, ID: afterId},
+			},
+			rows: [][]driver.Value{
+				{firstId, "coat", 10000, "url", 3, 0, nil, "2025-01-02 03:04:05"},
+			},
+			wantQuery: " AND (" + price + ", p.id) < (?, ?) ORDER BY " + price + " DESC, p.id DESC LIMIT ?",
+			wantArgs: []driver.Value{
+				"2025-01-15 12:00:00", 18000, afterId, "2025-01-15 12:00:00", 3,
+			},
+			wantIds:     []uuid.UUID{firstId},
+			wantHasMore: false,
+		},
+		{
+			name:  "discount case",
+			query: models.ProductCardsQuery{Sort: models.SortDiscount, Limit: 1, ProductFilter: filter},
+			rows: [][]driver.Value{
+				{secondId, "suit", 20000, "url", 1, 10, "2025-02-01 00:00:00", "2025-01-01 03:04:05"},
+				{firstId, "coat", 10000, "url", 3, 0, nil, "2025-01-02 03:04:05"},
+			},
+			wantQuery:   " ORDER BY " + discount + " DESC, p.id DESC LIMIT ?",
+			wantArgs:    []driver.Value{"2025-01-15 12:00:00", 2},
+			wantIds:     []uuid.UUID{secondId},
+			wantHasMore: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			rows := sqlmock.NewRows(columns)
+			for _, v := range tt.rows {
+				rows.AddRow(v...)
+			}
+
+			mock.ExpectQuery(regexp.QuoteMeta(tt.wantQuery) + "$").
+				WithArgs(tt.wantArgs...).
+				WillReturnRows(rows)
+
+			repo := New(db)
+
+			page, err := repo.ProductCards(context.Background(), tt.query)
+			require.NoError(t, err)
+			require.NotNil(t, page.Cards)
+			require.Equal(t, tt.wantHasMore, page.HasMore)
+
+			ids := make([]uuid.UUID, 0, len(page.Cards))
+			for _, v := range page.Cards {
+				ids = append(ids, v.ID)
+			}
+			require.Equal(t, tt.wantIds, ids)
+
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// newTestDB returns in-memory database with products table like in the sqlite storage
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// every connection gets its own in-memory database
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE products(
+		id TEXT PRIMARY KEY,
+		category_id TEXT,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		price INTEGER NOT NULL,
+		quantity INTEGER NOT NULL DEFAULT 0,
+		existing_sizes TEXT NOT NULL DEFAULT '',
+		image_url TEXT NOT NULL DEFAULT '',
+		pieces_sold INTEGER,
+		discount INTEGER DEFAULT 0,
+		discount_expires_at TEXT,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		updated_at TEXT
+	)`)
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestProductRepository_ProductCardsDB(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	type product struct {
+		name              string
+		price             int64
+		quantity          int
+		sizes             string
+		piecesSold        any
+		discount          int
+		discountExpiresAt any
+		createdAt         string
+	}
+
+	// ids are given in name order, so ties are broken predictably
+	products := []product{
+		{name: "a coat", price: 10000, quantity: 1, sizes: "s m", piecesSold: 5, createdAt: "2025-01-01 00:00:00"},
+		// expired an hour ago, time is written by driver in local zone of the writer
+		{name: "b suit", price: 20000, quantity: 0, sizes: "52", piecesSold: nil, discount: 50,
+			discountExpiresAt: time.Date(2025, 1, 15, 14, 0, 0, 0, moscow), createdAt: "2025-01-02 00:00:00"},
+		// expires in an hour, written in local zone too
+		{name: "c shirt", price: 30000, quantity: 2, sizes: "m", piecesSold: 5, discount: 50,
+			discountExpiresAt: time.Date(2025, 1, 15, 16, 0, 0, 0, moscow), createdAt: "2025-01-02 00:00:00"},
+		{name: "d hat", price: 15000, quantity: 3, sizes: "s", piecesSold: 1, discount: 20,
+			discountExpiresAt: "", createdAt: "2025-01-03 00:00:00"},
+		{name: "e scarf", price: 9000, quantity: 1, sizes: "m", piecesSold: 0, discount: 10,
+			discountExpiresAt: "2025-01-20 00:00:00", createdAt: "2025-01-04 00:00:00"},
+	}
+
+	db := newTestDB(t)
+	ids := make(map[string]uuid.UUID, len(products))
+	for i, v := range products {
+		id := uuid.UUID{byte(i + 1)}
+		ids[v.name] = id
+
+		_, err := db.Exec(
+			`INSERT INTO products
+			 (id, name, price, quantity, existing_sizes, pieces_sold, discount, discount_expires_at, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, v.name, v.price, v.quantity, v.sizes, v.piecesSold, v.discount, v.discountExpiresAt, v.createdAt,
+		)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name   string
+		filter models.ProductFilter
+		sort   models.ProductSort
+		want   []string
+	}{
+		{
+			name: "newest case",
+			sort: models.SortNewest,
+			want: []string{"e scarf", "d hat", "c shirt", "b suit", "a coat"},
+		},
+		{
+			name: "popular case",
+			sort: models.SortPopular,
+			want: []string{"c shirt", "a coat", "d hat", "e scarf", "b suit"},
+		},
+		{
+			name: "price asc case",
+			sort: models.SortPriceAsc,
+			want: []string{"e scarf", "a coat", "d hat", "c shirt", "b suit"},
+		},
+		{
+			name: "price desc case",
+			sort: models.SortPriceDesc,
+			want: []string{"b suit", "c shirt", "d hat", "a coat", "e scarf"},
+		},
+		{
+			name: "discount case",
+			sort: models.SortDiscount,
+			want: []string{"c shirt", "d hat", "e scarf", "b suit", "a coat"},
+		},
+		{
+			name:   "on sale case",
+			filter: models.ProductFilter{OnSale: true},
+			sort:   models.SortNewest,
+			want:   []string{"e scarf", "d hat", "c shirt"},
+		},
+		{
+			name:   "price range case",
+			filter: models.ProductFilter{MinPrice: money.Rub(10000), MaxPrice: money.Rub(15000)},
+			sort:   models.SortPriceAsc,
+			want:   []string{"a coat", "d hat", "c shirt"},
+		},
+		{
+			name:   "sizes in stock case",
+			filter: models.ProductFilter{Sizes: []models.ProductSize{models.SizeM, models.Size52}, InStock: true},
+			sort:   models.SortNewest,
+			want:   []string{"e scarf", "c shirt", "a coat"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := New(db)
+
+			filter := tt.filter
+			filter.Now = now
+
+			// pages of two products must give the same order as one page with all of them
+			got := make([]string, 0, len(tt.want))
+			var after *models.ProductCursor
+			for range len(products) {
+				page, err := repo.ProductCards(context.Background(), models.ProductCardsQuery{
+					ProductFilter: filter,
+					Sort:          tt.sort,
+					Limit:         2,
+					After:         after,
+				})
+				require.NoError(t, err)
+
+				for _, v := range page.Cards {
+					require.Equal(t, ids[v.Name], v.ID)
+					got = append(got, v.Name)
+				}
+
+				if !page.HasMore {
+					break
+				}
+
+				cursor := models.NewProductCursor(page.Cards[len(page.Cards)-1], tt.sort, now)
+				after = &cursor
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
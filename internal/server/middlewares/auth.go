@@ -11,7 +11,7 @@ import (
 )
 
 type TokenValidator interface {
-	ValidateJwt(token string) (int64, error)
+	ValidateJwt(token string) (string, error)
 }
 
 const UserIdKey = "user_id"
@@ -30,7 +30,7 @@ func Login(tokenValidator TokenValidator) func(next http.Handler) http.Handler {
 			}
 
 			content := strings.Split(header, " ")
-			if len(content) != 2 && content[0] != "Bearer" {
+			if len(content) != 2 || content[0] != "Bearer" {
 				log.Error("bad format")
 				return response.Error("bad format", http.StatusUnauthorized)
 			}
@@ -49,3 +49,41 @@ func Login(tokenValidator TokenValidator) func(next http.Handler) http.Handler {
 		}))
 	}
 }
+
+// OptionalLogin puts user id to context if request has valid token,
+// requests without token are passed as anonymous
+func OptionalLogin(tokenValidator TokenValidator) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(response.ErrorWrapper(func(w http.ResponseWriter, r *http.Request) error {
+			const op = "middlewares.auth.OptionalLogin"
+			ctx := r.Context()
+			log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return nil
+			}
+
+			content := strings.Split(header, " ")
+			if len(content) != 2 || content[0] != "Bearer" {
+				log.Error("bad format")
+				return response.Error("bad format", http.StatusUnauthorized)
+			}
+
+			// expired token is an error, otherwise user would silently get an empty guest cart
+			userID, err := tokenValidator.ValidateJwt(content[1])
+			if err != nil {
+				log.Error("failed to validate token")
+				return response.Error("failed to validate token", http.StatusUnauthorized)
+			}
+
+			ctx = context.WithValue(ctx, UserIdKey, userID)
+			r = r.WithContext(ctx)
+
+			next.ServeHTTP(w, r)
+
+			return nil
+		}))
+	}
+}
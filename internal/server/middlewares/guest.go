@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/pkg/logger"
+	"github.com/AlexMickh/shop-backend/pkg/response"
+	"github.com/AlexMickh/shop-backend/pkg/utils/cookies"
+	"github.com/google/uuid"
+)
+
+const (
+	GuestIdKey      = "guest_id"
+	GuestCartCookie = "guest_cart"
+)
+
+type GuestTracker interface {
+	TouchGuest(ctx context.Context, guestId string) error
+}
+
+// GuestCart gives anonymous visitor an id kept in signed cookie, so a cart can be
+// filled before login. Requests with user id in context are passed as is,
+// so it must be used after OptionalLogin. Visits are saved by guests, guest cart is deleted
+// only if visitor doesn't come back while the cookie is valid.
+func GuestCart(secret string, ttl time.Duration, guests GuestTracker) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(response.ErrorWrapper(func(w http.ResponseWriter, r *http.Request) error {
+			const op = "middlewares.guest.GuestCart"
+			ctx := r.Context()
+			log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+			if _, ok := ctx.Value(UserIdKey).(string); ok {
+				next.ServeHTTP(w, r)
+				return nil
+			}
+
+			guestId, err := cookies.GetSigned(r, GuestCartCookie, secret)
+			if err == nil {
+				_, err = uuid.Parse(guestId)
+			}
+			if err != nil {
+				if !errors.Is(err, http.ErrNoCookie) {
+					log.Warn("invalid guest cookie, new one is issued", logger.Err(err))
+				}
+
+				id, err := uuid.NewV7()
+				if err != nil {
+					log.Error("failed to generate guest id", logger.Err(err))
+					return response.Error("failed to generate guest id", http.StatusInternalServerError)
+				}
+				guestId = id.String()
+			}
+
+			// every visit prolongs the cookie, cart is kept while visitor comes back
+			cookies.SetSigned(w, GuestCartCookie, guestId, secret, ttl)
+			if err := guests.TouchGuest(ctx, guestId); err != nil {
+				log.Warn("failed to save guest visit", logger.Err(err))
+			}
+
+			ctx = context.WithValue(ctx, GuestIdKey, guestId)
+			r = r.WithContext(ctx)
+
+			next.ServeHTTP(w, r)
+
+			return nil
+		}))
+	}
+}
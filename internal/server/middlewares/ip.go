@@ -0,0 +1,80 @@
+package middlewares
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/AlexMickh/shop-backend/pkg/logger"
+	"github.com/AlexMickh/shop-backend/pkg/response"
+)
+
+// ParseNetworks parses CIDRs and single ips
+func ParseNetworks(networks []string) ([]netip.Prefix, error) {
+	const op = "middlewares.ip.ParseNetworks"
+
+	prefixes := make([]netip.Prefix, 0, len(networks))
+	for _, v := range networks {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// AllowIPs rejects requests from addresses outside of networks.
+// If trustProxy is set, address is taken from X-Real-IP header set by nginx.
+func AllowIPs(networks []netip.Prefix, trustProxy bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(response.ErrorWrapper(func(w http.ResponseWriter, r *http.Request) error {
+			const op = "middlewares.ip.AllowIPs"
+			ctx := r.Context()
+			log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+			remoteAddr := r.RemoteAddr
+			if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+				remoteAddr = host
+			}
+			if realIp := r.Header.Get("X-Real-IP"); trustProxy && realIp != "" {
+				remoteAddr = realIp
+			}
+
+			addr, err := netip.ParseAddr(remoteAddr)
+			if err != nil {
+				log.Error("failed to parse remote addr", logger.Err(err))
+				return response.Error("forbidden", http.StatusForbidden)
+			}
+			addr = addr.Unmap()
+
+			for _, v := range networks {
+				if v.Contains(addr) {
+					next.ServeHTTP(w, r)
+					return nil
+				}
+			}
+
+			log.Error("address is not allowed", slog.String("addr", addr.String()))
+			return response.Error("forbidden", http.StatusForbidden)
+		}))
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,6 +12,7 @@ import (
 
 	"github.com/AlexMickh/shop-backend/internal/dtos"
 	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
 	"github.com/AlexMickh/shop-backend/pkg/logger"
 	"github.com/AlexMickh/shop-backend/pkg/response"
 	"github.com/go-chi/chi/v5"
@@ -22,14 +22,38 @@ import (
 )
 
 type CategoryService interface {
-	CreateCategory(ctx context.Context, req dtos.CreateCategoryRequest) (uuid.UUID, error)
-	DeleteCategory(ctx context.Context, id string) error
+	CreateCategory(ctx context.Context, req dtos.CreateCategoryRequest) (*models.Category, error)
+	UpdateCategory(ctx context.Context, req *dtos.UpdateCategoryRequest) error
+	DeleteCategory(ctx context.Context, id, moveTo string) error
 }
 
 type ProductService interface {
 	CreateProduct(ctx context.Context, req dtos.CreateProductRequest) (uuid.UUID, error)
 	UpdateProduct(ctx context.Context, req *dtos.UpdateProductRequest) error
 	DeleteProduct(ctx context.Context, id string) error
+	Variants(ctx context.Context, productId string) ([]models.ProductVariant, error)
+	CreateVariant(ctx context.Context, req dtos.CreateVariantRequest) (*models.ProductVariant, error)
+	UpdateVariant(ctx context.Context, req dtos.UpdateVariantRequest) (*models.ProductVariant, error)
+	DeleteVariant(ctx context.Context, productId, id string) error
+	SchedulePrice(ctx context.Context, req dtos.SchedulePriceRequest) (*models.ScheduledPrice, error)
+	AddImage(ctx context.Context, req dtos.AddProductImageRequest) (*models.ProductImage, error)
+	ReorderImages(ctx context.Context, req dtos.ReorderImagesRequest) error
+	DeleteImage(ctx context.Context, productId, id string) error
+}
+
+type OrderService interface {
+	Orders(ctx context.Context, userId string) ([]models.Order, error)
+	OrderById(ctx context.Context, id string) (*models.Order, error)
+	UpdateStatus(ctx context.Context, req dtos.UpdateOrderStatusRequest) error
+	Refund(ctx context.Context, req dtos.RefundOrderRequest) (*models.OrderRefund, error)
+	Refunds(ctx context.Context, orderId string) ([]models.OrderRefund, error)
+}
+
+type CouponService interface {
+	CreateCoupon(ctx context.Context, req dtos.CreateCouponRequest) (*models.Coupon, error)
+	Coupons(ctx context.Context) ([]models.Coupon, error)
+	UpdateCoupon(ctx context.Context, req dtos.UpdateCouponRequest) (*models.Coupon, error)
+	DeleteCoupon(ctx context.Context, id string) error
 }
 
 type AdminRouter struct {
@@ -37,16 +61,26 @@ type AdminRouter struct {
 	password        string
 	categoryService CategoryService
 	productService  ProductService
+	orderService    OrderService
+	couponService   CouponService
 }
 
 var ErrNothingToUpdate = errors.New("nothing to update")
 
-func New(login, password string, categoryService CategoryService, productService ProductService) *AdminRouter {
+func New(
+	login, password string,
+	categoryService CategoryService,
+	productService ProductService,
+	orderService OrderService,
+	couponService CouponService,
+) *AdminRouter {
 	return &AdminRouter{
 		login:           login,
 		password:        password,
 		categoryService: categoryService,
 		productService:  productService,
+		orderService:    orderService,
+		couponService:   couponService,
 	}
 }
 
@@ -58,6 +92,7 @@ func (a *AdminRouter) RegisterRoute(r *chi.Mux) {
 
 		r.Route("/categories", func(r chi.Router) {
 			r.Post("/", response.ErrorWrapper(a.CreateCategory))
+			r.Patch("/{id}", response.ErrorWrapper(a.UpdateCategory))
 			r.Delete("/{id}", response.ErrorWrapper(a.DeleteCategory))
 		})
 
@@ -65,6 +100,32 @@ func (a *AdminRouter) RegisterRoute(r *chi.Mux) {
 			r.Post("/", response.ErrorWrapper(a.CreateProduct))
 			r.Patch("/{id}", response.ErrorWrapper(a.UpdateProduct))
 			r.Delete("/{id}", response.ErrorWrapper(a.DeleteProduct))
+
+			r.Get("/{id}/variants", response.ErrorWrapper(a.Variants))
+			r.Post("/{id}/variants", response.ErrorWrapper(a.CreateVariant))
+			r.Patch("/{id}/variants/{variant_id}", response.ErrorWrapper(a.UpdateVariant))
+			r.Delete("/{id}/variants/{variant_id}", response.ErrorWrapper(a.DeleteVariant))
+
+			r.Post("/{id}/images", response.ErrorWrapper(a.AddImage))
+			r.Put("/{id}/images", response.ErrorWrapper(a.ReorderImages))
+			r.Delete("/{id}/images/{image_id}", response.ErrorWrapper(a.DeleteImage))
+
+			r.Post("/{id}/prices", response.ErrorWrapper(a.SchedulePrice))
+		})
+
+		r.Route("/orders", func(r chi.Router) {
+			r.Get("/", response.ErrorWrapper(a.Orders))
+			r.Get("/{id}", response.ErrorWrapper(a.OrderById))
+			r.Patch("/{id}/status", response.ErrorWrapper(a.UpdateOrderStatus))
+			r.Post("/{id}/refund", response.ErrorWrapper(a.RefundOrder))
+			r.Get("/{id}/refunds", response.ErrorWrapper(a.OrderRefunds))
+		})
+
+		r.Route("/coupons", func(r chi.Router) {
+			r.Get("/", response.ErrorWrapper(a.Coupons))
+			r.Post("/", response.ErrorWrapper(a.CreateCoupon))
+			r.Patch("/{id}", response.ErrorWrapper(a.UpdateCoupon))
+			r.Delete("/{id}", response.ErrorWrapper(a.DeleteCoupon))
 		})
 	})
 }
@@ -72,14 +133,15 @@ func (a *AdminRouter) RegisterRoute(r *chi.Mux) {
 // Create godoc
 //
 //	@Summary		create new category
-//	@Description	create new category
+//	@Description	create new category, slug is made from name if it isn't set
 //	@Tags			admin
 //	@Accept			json
 //	@Produce		json
-//	@Param			name	body		string	true	"category name"
+//	@Param			request	body		dtos.CreateCategoryRequest	true	"category with optional parent"
 //	@Success		201		{object}	dtos.CreateCategoryResponse
 //	@Failure		400		{object}	response.ErrorResponse
 //	@Failure		401		{object}	response.ErrorResponse
+//	@Failure		404		{object}	response.ErrorResponse
 //	@Failure		409		{object}	response.ErrorResponse
 //	@Failure		500		{object}	response.ErrorResponse
 //	@Security		AdminAuth
@@ -102,8 +164,16 @@ func (a *AdminRouter) CreateCategory(w http.ResponseWriter, r *http.Request) err
 	// 	return response.Error("failed to validate request", http.StatusBadRequest)
 	// }
 
-	id, err := a.categoryService.CreateCategory(ctx, req)
+	category, err := a.categoryService.CreateCategory(ctx, req)
 	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrCategoryNotFound) {
+			log.Error(errs.ErrCategoryNotFound.Error())
+			return response.Error("parent category not found", http.StatusNotFound)
+		}
 		if errors.Is(err, errs.ErrCategoryAlreadyExists) {
 			log.Error(errs.ErrCategoryAlreadyExists.Error())
 			return response.Error(errs.ErrCategoryAlreadyExists.Error(), http.StatusConflict)
@@ -115,24 +185,100 @@ func (a *AdminRouter) CreateCategory(w http.ResponseWriter, r *http.Request) err
 
 	render.Status(r, http.StatusCreated)
 	render.JSON(w, r, dtos.CreateCategoryResponse{
-		ID: id.String(),
+		ID:   category.ID.String(),
+		Slug: category.Slug,
 	})
 
 	return nil
 }
 
+// UpdateCategory godoc
+//
+//	@Summary		update category
+//	@Description	update category, products stay in it
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		string	true	"category id"
+//	@Param			name		formData	string	false	"new category name"
+//	@Param			slug		formData	string	false	"new category slug, only latin letters, numbers and hyphens"
+//	@Param			description	formData	string	false	"new category description"
+//	@Param			position	formData	int		false	"new position among categories with the same parent"
+//	@Param			image		formData	file	false	"new category image"
+//	@Success		204
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		409	{object}	response.ErrorResponse
+//	@Failure		413	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/categories/{id} [patch]
+func (a *AdminRouter) UpdateCategory(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.UpdateCategory"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	req := new(dtos.UpdateCategoryRequest)
+	req.ID = r.PathValue("id")
+
+	err := parseUpdateCategoryForm(r, req)
+	if err != nil {
+		if errors.Is(err, ErrNothingToUpdate) {
+			log.Error("nothing to update")
+			return response.Error(ErrNothingToUpdate.Error(), http.StatusBadRequest)
+		}
+
+		log.Error("failed to parse form", logger.Err(err))
+		return response.Error("failed to perse request", http.StatusBadRequest)
+	}
+
+	if err = a.categoryService.UpdateCategory(ctx, req); err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrCategoryNotFound) {
+			log.Error(errs.ErrCategoryNotFound.Error())
+			return response.Error(errs.ErrCategoryNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrCategoryAlreadyExists) {
+			log.Error(errs.ErrCategoryAlreadyExists.Error())
+			return response.Error(errs.ErrCategoryAlreadyExists.Error(), http.StatusConflict)
+		}
+
+		if errors.Is(err, errs.ErrUnsupportedImageType) {
+			log.Error(errs.ErrUnsupportedImageType.Error())
+			return response.Error(errs.ErrUnsupportedImageType.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrImageTooLarge) {
+			log.Error(errs.ErrImageTooLarge.Error())
+			return response.Error(errs.ErrImageTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		}
+
+		log.Error("failed to update category", logger.Err(err))
+		return response.Error("failed to update category", http.StatusInternalServerError)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
 // DeleteCategory godoc
 //
 //	@Summary		delete category
-//	@Description	delete category
+//	@Description	delete category without subcategories, category with products is deleted only if they are moved to other category
 //	@Tags			admin
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path	int	true	"category id"
+//	@Param			id		path	string	true	"category id"
+//	@Param			move_to	query	string	false	"category id to move products to"
 //	@Success		204
 //	@Failure		400	{object}	response.ErrorResponse
 //	@Failure		401	{object}	response.ErrorResponse
 //	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		409	{object}	response.ErrorResponse
 //	@Failure		500	{object}	response.ErrorResponse
 //	@Security		AdminAuth
 //	@Router			/admin/categories/{id} [delete]
@@ -143,12 +289,20 @@ func (a *AdminRouter) DeleteCategory(w http.ResponseWriter, r *http.Request) err
 
 	id := r.PathValue("id")
 
-	err := a.categoryService.DeleteCategory(ctx, id)
+	err := a.categoryService.DeleteCategory(ctx, id, r.URL.Query().Get("move_to"))
 	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
 		if errors.Is(err, errs.ErrCategoryNotFound) {
 			log.Error(errs.ErrCategoryNotFound.Error())
 			return response.Error(errs.ErrCategoryNotFound.Error(), http.StatusNotFound)
 		}
+		if errors.Is(err, errs.ErrCategoryNotEmpty) {
+			log.Error(errs.ErrCategoryNotEmpty.Error())
+			return response.Error(errs.ErrCategoryNotEmpty.Error(), http.StatusConflict)
+		}
 
 		log.Error("failed to delete category", logger.Err(err))
 		return response.Error("failed to delete category", http.StatusInternalServerError)
@@ -170,13 +324,14 @@ func (a *AdminRouter) DeleteCategory(w http.ResponseWriter, r *http.Request) err
 //	@Param			description		formData	string	true	"product description"
 //	@Param			price			formData	integer	true	"product proce"
 //	@Param			category_id		formData	string	true	"product category id"
-//	@Param			quantity		formData	integer	true	"product quantity"
-//	@Param			existing_sizes	formData	string	true	"product sizes"
+//	@Param			quantity		formData	integer	true	"initial stock of the product, it is split between sizes evenly"
+//	@Param			existing_sizes	formData	string	true	"product sizes separated by space"
 //	@Param			image			formData	file	true	"product image"
 //	@Success		201				{object}	dtos.CreateCategoryResponse
 //	@Failure		400				{object}	response.ErrorResponse
 //	@Failure		401				{object}	response.ErrorResponse
 //	@Failure		409				{object}	response.ErrorResponse
+//	@Failure		413				{object}	response.ErrorResponse
 //	@Failure		500				{object}	response.ErrorResponse
 //	@Security		AdminAuth
 //	@Router			/admin/products [post]
@@ -188,11 +343,6 @@ func (a *AdminRouter) CreateProduct(w http.ResponseWriter, r *http.Request) erro
 	var req dtos.CreateProductRequest
 	err := parseCreateProductForm(r, &req)
 	if err != nil {
-		if errors.Is(err, errs.ErrUnsupportedImageType) {
-			log.Error(errs.ErrUnsupportedImageType.Error())
-			return response.Error(errs.ErrUnsupportedImageType.Error(), http.StatusBadRequest)
-		}
-
 		log.Error("failed to parse form", logger.Err(err))
 		return response.Error("failed to parse form", http.StatusBadRequest)
 	}
@@ -209,6 +359,15 @@ func (a *AdminRouter) CreateProduct(w http.ResponseWriter, r *http.Request) erro
 			return response.Error(errs.ErrProductAlreadyExists.Error(), http.StatusConflict)
 		}
 
+		if errors.Is(err, errs.ErrUnsupportedImageType) {
+			log.Error(errs.ErrUnsupportedImageType.Error())
+			return response.Error(errs.ErrUnsupportedImageType.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrImageTooLarge) {
+			log.Error(errs.ErrImageTooLarge.Error())
+			return response.Error(errs.ErrImageTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		}
+
 		log.Error("failed to create product", logger.Err(err))
 		return response.Error("failed to create product", http.StatusInternalServerError)
 	}
@@ -232,15 +391,15 @@ func (a *AdminRouter) CreateProduct(w http.ResponseWriter, r *http.Request) erro
 //	@Param			name				formData	string	false	"new product name"
 //	@Param			description			formData	string	false	"new product description"
 //	@Param			price				formData	integer	false	"new product proce"
-//	@Param			quantity			formData	integer	false	"new product quantity"
-//	@Param			existing_sizes		formData	string	false	"new product sizes"
-//	@Param			image				formData	file	false	"new product image"
-//	@Param			discount			formData	int		false	"new product discount"
+//	@Param			image				formData	file	false	"new main product image, old images stay in gallery"
+//	@Param			discount			formData	int		false	"new product discount, 0 removes it"
+//	@Param			discount_starts_at	formData	string	false	"discount isn't applied before this time, format 2006-01-02 15:04:05"
 //	@Param			discount_expires_at	formData	string	false	"new product discount expires at (only if discount exists)"
 //	@Success		204
 //	@Failure		400	{object}	response.ErrorResponse
 //	@Failure		401	{object}	response.ErrorResponse
 //	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		413	{object}	response.ErrorResponse
 //	@Failure		500	{object}	response.ErrorResponse
 //	@Security		AdminAuth
 //	@Router			/admin/products/{id} [patch]
@@ -274,11 +433,24 @@ func (a *AdminRouter) UpdateProduct(w http.ResponseWriter, r *http.Request) erro
 	// }
 
 	if err = a.productService.UpdateProduct(ctx, req); err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
 		if errors.Is(err, errs.ErrProductNotFound) {
 			log.Error("product not found")
 			return response.Error(errs.ErrProductNotFound.Error(), http.StatusNotFound)
 		}
 
+		if errors.Is(err, errs.ErrUnsupportedImageType) {
+			log.Error(errs.ErrUnsupportedImageType.Error())
+			return response.Error(errs.ErrUnsupportedImageType.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrImageTooLarge) {
+			log.Error(errs.ErrImageTooLarge.Error())
+			return response.Error(errs.ErrImageTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		}
+
 		log.Error("failed to update product", logger.Err(err))
 		return response.Error("failed to update product", http.StatusInternalServerError)
 	}
@@ -325,90 +497,980 @@ func (a *AdminRouter) DeleteProduct(w http.ResponseWriter, r *http.Request) erro
 	return nil
 }
 
-func parseCreateProductForm(r *http.Request, req *dtos.CreateProductRequest) error {
-	const op = "routers.admin.parseCreateProductForm"
+// Variants godoc
+//
+//	@Summary		get product variants
+//	@Description	get all sizes of product with their stock, including sold out ones
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"product id"
+//	@Success		200	{object}	dtos.GetVariantsResponse
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/products/{id}/variants [get]
+func (a *AdminRouter) Variants(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.Variants"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
 
-	err := r.ParseMultipartForm(10 << 20)
+	id := r.PathValue("id")
+
+	variants, err := a.productService.Variants(ctx, id)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error("invalid product id", http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrProductNotFound) {
+			log.Error(errs.ErrProductNotFound.Error())
+			return response.Error(errs.ErrProductNotFound.Error(), http.StatusNotFound)
+		}
+
+		log.Error("failed to get variants", logger.Err(err))
+		return response.Error("failed to get variants", http.StatusInternalServerError)
 	}
 
-	req.Name = r.FormValue("name")
-	req.Description = r.FormValue("description")
+	render.JSON(w, r, dtos.ToGetVariantsResponse(variants))
 
-	price, err := strconv.Atoi(r.FormValue("price"))
+	return nil
+}
+
+// CreateVariant godoc
+//
+//	@Summary		create product variant
+//	@Description	add new size of product with its own stock
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string						true	"product id"
+//	@Param			req	body		dtos.CreateVariantRequest	true	"size, stock and optional sku and barcode"
+//	@Success		201	{object}	dtos.Variant
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		409	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/products/{id}/variants [post]
+func (a *AdminRouter) CreateVariant(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.CreateVariant"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	var req dtos.CreateVariantRequest
+	err := render.DecodeJSON(r.Body, &req)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		log.Error("failed to decode request", logger.Err(err))
+		return response.Error("failed to decode request", http.StatusBadRequest)
 	}
-	req.Price = price
+	defer r.Body.Close()
 
-	req.CategoryID = r.FormValue("category_id")
+	req.ProductID = r.PathValue("id")
 
-	req.Quantity, err = strconv.Atoi(r.FormValue("quantity"))
+	variant, err := a.productService.CreateVariant(ctx, req)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrProductNotFound) {
+			log.Error(errs.ErrProductNotFound.Error())
+			return response.Error(errs.ErrProductNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrVariantAlreadyExists) {
+			log.Error(errs.ErrVariantAlreadyExists.Error())
+			return response.Error(errs.ErrVariantAlreadyExists.Error(), http.StatusConflict)
+		}
+
+		log.Error("failed to create variant", logger.Err(err))
+		return response.Error("failed to create variant", http.StatusInternalServerError)
 	}
 
-	req.ExistingSizes = strings.Split(r.FormValue("existing_sizes"), " ")
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, dtos.ToVariant(*variant))
+
+	return nil
+}
+
+// UpdateVariant godoc
+//
+//	@Summary		update product variant
+//	@Description	change size, stock, sku or barcode of product variant, only passed fields are changed
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		string						true	"product id"
+//	@Param			variant_id	path		string						true	"variant id"
+//	@Param			req			body		dtos.UpdateVariantRequest	true	"fields to change"
+//	@Success		200			{object}	dtos.Variant
+//	@Failure		400			{object}	response.ErrorResponse
+//	@Failure		401			{object}	response.ErrorResponse
+//	@Failure		404			{object}	response.ErrorResponse
+//	@Failure		409			{object}	response.ErrorResponse
+//	@Failure		500			{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/products/{id}/variants/{variant_id} [patch]
+func (a *AdminRouter) UpdateVariant(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.UpdateVariant"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
 
-	var header *multipart.FileHeader
-	req.Image, header, err = r.FormFile("image")
+	var req dtos.UpdateVariantRequest
+	err := render.DecodeJSON(r.Body, &req)
 	if err != nil {
-		return fmt.Errorf("1111%s: %w", op, err)
+		log.Error("failed to decode request", logger.Err(err))
+		return response.Error("failed to decode request", http.StatusBadRequest)
+	}
+	defer r.Body.Close()
+
+	req.ProductID = r.PathValue("id")
+	req.ID = r.PathValue("variant_id")
+
+	if req.Size == nil && req.Stock == nil && req.Sku == nil && req.Barcode == nil {
+		log.Error("nothing to update")
+		return response.Error(ErrNothingToUpdate.Error(), http.StatusBadRequest)
 	}
 
-	arr := strings.Split(header.Filename, ".")
-	if arr[len(arr)-1] != "png" {
-		return fmt.Errorf("%s: %w", op, errs.ErrUnsupportedImageType)
+	variant, err := a.productService.UpdateVariant(ctx, req)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrVariantNotFound) {
+			log.Error(errs.ErrVariantNotFound.Error())
+			return response.Error(errs.ErrVariantNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrVariantAlreadyExists) {
+			log.Error(errs.ErrVariantAlreadyExists.Error())
+			return response.Error(errs.ErrVariantAlreadyExists.Error(), http.StatusConflict)
+		}
+
+		log.Error("failed to update variant", logger.Err(err))
+		return response.Error("failed to update variant", http.StatusInternalServerError)
 	}
 
+	render.JSON(w, r, dtos.ToVariant(*variant))
+
 	return nil
 }
 
-func parseUpdateProductForm(r *http.Request, req *dtos.UpdateProductRequest) error {
-	const op = "routers.admin.parseUpdateProductForm"
+// DeleteVariant godoc
+//
+//	@Summary		delete product variant
+//	@Description	delete size of product, it's removed from carts too
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path	string	true	"product id"
+//	@Param			variant_id	path	string	true	"variant id"
+//	@Success		204
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/products/{id}/variants/{variant_id} [delete]
+func (a *AdminRouter) DeleteVariant(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.DeleteVariant"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
 
-	hasSomething := false
+	err := a.productService.DeleteVariant(ctx, r.PathValue("id"), r.PathValue("variant_id"))
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error("invalid product or variant id", http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrVariantNotFound) {
+			log.Error(errs.ErrVariantNotFound.Error())
+			return response.Error(errs.ErrVariantNotFound.Error(), http.StatusNotFound)
+		}
 
-	req.Name = r.FormValue("name")
-	if req.Name != "" {
-		hasSomething = true
+		log.Error("failed to delete variant", logger.Err(err))
+		return response.Error("failed to delete variant", http.StatusInternalServerError)
 	}
 
-	req.Description = r.FormValue("description")
-	if req.Description != "" && !hasSomething {
-		hasSomething = true
-	}
+	w.WriteHeader(http.StatusNoContent)
 
-	var err error
-	req.Price, err = strconv.Atoi(r.FormValue("price"))
-	if err == nil && !hasSomething {
-		hasSomething = true
+	return nil
+}
+
+// AddImage godoc
+//
+//	@Summary		add product image
+//	@Description	add image to the end of product gallery, main image is put first and shown in catalogue
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string	true	"product id"
+//	@Param			image	formData	file	true	"product image"
+//	@Param			main	formData	bool	false	"make image main"
+//	@Success		201		{object}	dtos.ProductImage
+//	@Failure		400		{object}	response.ErrorResponse
+//	@Failure		401		{object}	response.ErrorResponse
+//	@Failure		404		{object}	response.ErrorResponse
+//	@Failure		413		{object}	response.ErrorResponse
+//	@Failure		500		{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/products/{id}/images [post]
+func (a *AdminRouter) AddImage(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.AddImage"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	req := dtos.AddProductImageRequest{
+		ProductID: r.PathValue("id"),
 	}
 
-	req.Quantity, err = strconv.Atoi(r.FormValue("quantity"))
-	if err == nil && !hasSomething {
-		hasSomething = true
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		log.Error("failed to get image", logger.Err(err))
+		return response.Error("image is required", http.StatusBadRequest)
 	}
+	defer file.Close()
+	req.Image = file
 
-	req.ExistingSizes = strings.Split(r.FormValue("existing_sizes"), " ")
-	if req.ExistingSizes != nil && !hasSomething {
-		hasSomething = true
+	if main := r.FormValue("main"); main != "" {
+		req.Main, err = strconv.ParseBool(main)
+		if err != nil {
+			log.Error("failed to parse main", logger.Err(err))
+			return response.Error("failed to parse main", http.StatusBadRequest)
+		}
 	}
 
-	var header *multipart.FileHeader
-	req.Image, header, err = r.FormFile("image")
-	if err == nil {
-		arr := strings.Split(header.Filename, ".")
-		if arr[len(arr)-1] != "png" {
-			return fmt.Errorf("%s: %w", op, errs.ErrUnsupportedImageType)
+	image, err := a.productService.AddImage(ctx, req)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrProductNotFound) {
+			log.Error(errs.ErrProductNotFound.Error())
+			return response.Error(errs.ErrProductNotFound.Error(), http.StatusNotFound)
 		}
 
-		hasSomething = true
+		if errors.Is(err, errs.ErrUnsupportedImageType) {
+			log.Error(errs.ErrUnsupportedImageType.Error())
+			return response.Error(errs.ErrUnsupportedImageType.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrImageTooLarge) {
+			log.Error(errs.ErrImageTooLarge.Error())
+			return response.Error(errs.ErrImageTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		}
+
+		log.Error("failed to add image", logger.Err(err))
+		return response.Error("failed to add image", http.StatusInternalServerError)
 	}
 
-	req.Price, err = strconv.Atoi(r.FormValue("discount"))
-	if err == nil && !hasSomething {
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, dtos.ToProductImage(*image))
+
+	return nil
+}
+
+// ReorderImages godoc
+//
+//	@Summary		reorder product images
+//	@Description	set order of product gallery, request has ids of all images, the first becomes main
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	string						true	"product id"
+//	@Param			req	body	dtos.ReorderImagesRequest	true	"image ids in new order"
+//	@Success		204
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/products/{id}/images [put]
+func (a *AdminRouter) ReorderImages(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.ReorderImages"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	var req dtos.ReorderImagesRequest
+	err := render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		log.Error("failed to decode request", logger.Err(err))
+		return response.Error("failed to decode request", http.StatusBadRequest)
+	}
+	defer r.Body.Close()
+
+	req.ProductID = r.PathValue("id")
+
+	err = a.productService.ReorderImages(ctx, req)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrImageNotFound) {
+			log.Error(errs.ErrImageNotFound.Error())
+			return response.Error(errs.ErrImageNotFound.Error(), http.StatusNotFound)
+		}
+
+		log.Error("failed to reorder images", logger.Err(err))
+		return response.Error("failed to reorder images", http.StatusInternalServerError)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// DeleteImage godoc
+//
+//	@Summary		delete product image
+//	@Description	delete image from product gallery, the next one becomes main if main is deleted
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path	string	true	"product id"
+//	@Param			image_id	path	string	true	"image id"
+//	@Success		204
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/products/{id}/images/{image_id} [delete]
+func (a *AdminRouter) DeleteImage(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.DeleteImage"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	err := a.productService.DeleteImage(ctx, r.PathValue("id"), r.PathValue("image_id"))
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrImageNotFound) {
+			log.Error(errs.ErrImageNotFound.Error())
+			return response.Error(errs.ErrImageNotFound.Error(), http.StatusNotFound)
+		}
+
+		log.Error("failed to delete image", logger.Err(err))
+		return response.Error("failed to delete image", http.StatusInternalServerError)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// SchedulePrice godoc
+//
+//	@Summary		schedule price change
+//	@Description	plan new product price, it's applied when starts_at comes and written to price history
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string						true	"product id"
+//	@Param			req	body		dtos.SchedulePriceRequest	true	"new price in kopecks and time it starts at"
+//	@Success		201	{object}	dtos.ScheduledPrice
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/products/{id}/prices [post]
+func (a *AdminRouter) SchedulePrice(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.SchedulePrice"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	var req dtos.SchedulePriceRequest
+	err := render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		log.Error("failed to decode request", logger.Err(err))
+		return response.Error("failed to decode request", http.StatusBadRequest)
+	}
+	defer r.Body.Close()
+
+	req.ProductID = r.PathValue("id")
+
+	price, err := a.productService.SchedulePrice(ctx, req)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrProductNotFound) {
+			log.Error(errs.ErrProductNotFound.Error())
+			return response.Error(errs.ErrProductNotFound.Error(), http.StatusNotFound)
+		}
+
+		log.Error("failed to schedule price", logger.Err(err))
+		return response.Error("failed to schedule price", http.StatusInternalServerError)
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, dtos.ToScheduledPrice(*price))
+
+	return nil
+}
+
+// Orders godoc
+//
+//	@Summary		get users orders
+//	@Description	get all orders of user, newest first
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			user_id	query		string	true	"user id"
+//	@Success		200		{object}	dtos.GetOrdersResponse
+//	@Failure		400		{object}	response.ErrorResponse
+//	@Failure		401		{object}	response.ErrorResponse
+//	@Failure		500		{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/orders [get]
+func (a *AdminRouter) Orders(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.Orders"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	userId := r.URL.Query().Get("user_id")
+	if userId == "" {
+		log.Error("user id is empty")
+		return response.Error("user id is required", http.StatusBadRequest)
+	}
+
+	orders, err := a.orderService.Orders(ctx, userId)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error("invalid user id", http.StatusBadRequest)
+		}
+
+		log.Error("failed to get orders", logger.Err(err))
+		return response.Error("failed to get orders", http.StatusInternalServerError)
+	}
+
+	render.JSON(w, r, dtos.ToGetOrdersResponse(orders))
+
+	return nil
+}
+
+// OrderById godoc
+//
+//	@Summary		get order by id
+//	@Description	get order by id
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"order id"
+//	@Success		200	{object}	dtos.Order
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/orders/{id} [get]
+func (a *AdminRouter) OrderById(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.OrderById"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	id := r.PathValue("id")
+
+	order, err := a.orderService.OrderById(ctx, id)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error("invalid order id", http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrOrderNotFound) {
+			log.Error(errs.ErrOrderNotFound.Error())
+			return response.Error(errs.ErrOrderNotFound.Error(), http.StatusNotFound)
+		}
+
+		log.Error("failed to get order", logger.Err(err))
+		return response.Error("failed to get order", http.StatusInternalServerError)
+	}
+
+	render.JSON(w, r, dtos.ToOrder(*order))
+
+	return nil
+}
+
+// UpdateOrderStatus godoc
+//
+//	@Summary		update order status
+//	@Description	move order to the next status (pending_payment -> paid -> shipped -> delivered, or cancelled before payment, paid orders are refunded instead)
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	string	true	"order id"
+//	@Param			status	body	string	true	"new order status"
+//	@Success		204
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		409	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/orders/{id}/status [patch]
+func (a *AdminRouter) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.UpdateOrderStatus"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	var req dtos.UpdateOrderStatusRequest
+	err := render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		log.Error("failed to decode request", logger.Err(err))
+		return response.Error("failed to decode request", http.StatusBadRequest)
+	}
+	defer r.Body.Close()
+
+	req.ID = r.PathValue("id")
+
+	err = a.orderService.UpdateStatus(ctx, req)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrOrderNotFound) {
+			log.Error(errs.ErrOrderNotFound.Error())
+			return response.Error(errs.ErrOrderNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrOrderStatusTransition) {
+			log.Error(errs.ErrOrderStatusTransition.Error())
+			return response.Error(errs.ErrOrderStatusTransition.Error(), http.StatusConflict)
+		}
+
+		log.Error("failed to update order status", logger.Err(err))
+		return response.Error("failed to update order status", http.StatusInternalServerError)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// RefundOrder godoc
+//
+//	@Summary		refund order
+//	@Description	give money back for the whole order or for some of its items, refunded items are returned to stock.
+//	@Description	If provider doesn't answer or hasn't finished refund, it stays pending and is checked again automatically
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string					true	"order id"
+//	@Param			req	body		dtos.RefundOrderRequest	true	"items to refund, all not refunded items if empty"
+//	@Success		201	{object}	dtos.Refund
+//	@Success		202	{object}	dtos.Refund
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		409	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Failure		502	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/orders/{id}/refund [post]
+func (a *AdminRouter) RefundOrder(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.RefundOrder"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	var req dtos.RefundOrderRequest
+	if r.ContentLength != 0 {
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("failed to decode request", logger.Err(err))
+			return response.Error("failed to decode request", http.StatusBadRequest)
+		}
+		defer r.Body.Close()
+	}
+
+	req.ID = r.PathValue("id")
+
+	refund, err := a.orderService.Refund(ctx, req)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrOrderNotFound) {
+			log.Error(errs.ErrOrderNotFound.Error())
+			return response.Error(errs.ErrOrderNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrOrderStatusTransition) {
+			log.Error(errs.ErrOrderStatusTransition.Error())
+			return response.Error("order can't be refunded", http.StatusConflict)
+		}
+		if errors.Is(err, errs.ErrRefundQuantity) {
+			log.Error(errs.ErrRefundQuantity.Error())
+			return response.Error(errs.ErrRefundQuantity.Error(), http.StatusConflict)
+		}
+		if errors.Is(err, errs.ErrRefundPayment) {
+			log.Error(errs.ErrRefundPayment.Error(), logger.Err(err))
+			return response.Error(errs.ErrRefundPayment.Error(), http.StatusBadGateway)
+		}
+
+		log.Error("failed to refund order", logger.Err(err))
+		return response.Error("failed to refund order", http.StatusInternalServerError)
+	}
+
+	if refund.Status == models.RefundStatusPending {
+		log.Warn("refund is pending", slog.String("refund_id", refund.ID.String()))
+		render.Status(r, http.StatusAccepted)
+	} else {
+		render.Status(r, http.StatusCreated)
+	}
+	render.JSON(w, r, dtos.ToRefund(*refund))
+
+	return nil
+}
+
+// OrderRefunds godoc
+//
+//	@Summary		get order refunds
+//	@Description	get refund history of the order, oldest first
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"order id"
+//	@Success		200	{object}	dtos.GetRefundsResponse
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/orders/{id}/refunds [get]
+func (a *AdminRouter) OrderRefunds(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.OrderRefunds"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	id := r.PathValue("id")
+
+	refunds, err := a.orderService.Refunds(ctx, id)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error("invalid order id", http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrOrderNotFound) {
+			log.Error(errs.ErrOrderNotFound.Error())
+			return response.Error(errs.ErrOrderNotFound.Error(), http.StatusNotFound)
+		}
+
+		log.Error("failed to get refunds", logger.Err(err))
+		return response.Error("failed to get refunds", http.StatusInternalServerError)
+	}
+
+	render.JSON(w, r, dtos.ToGetRefundsResponse(refunds))
+
+	return nil
+}
+
+// Coupons godoc
+//
+//	@Summary		get coupons
+//	@Description	get all coupons, newest first
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	dtos.GetCouponsResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/coupons [get]
+func (a *AdminRouter) Coupons(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.Coupons"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	coupons, err := a.couponService.Coupons(ctx)
+	if err != nil {
+		log.Error("failed to get coupons", logger.Err(err))
+		return response.Error("failed to get coupons", http.StatusInternalServerError)
+	}
+
+	render.JSON(w, r, dtos.ToGetCouponsResponse(coupons))
+
+	return nil
+}
+
+// CreateCoupon godoc
+//
+//	@Summary		create coupon
+//	@Description	create promo code with percent or fixed discount. Value is percent for percent coupons and kopecks for fixed ones.
+//	@Description	Coupon without product_ids and category_ids covers the whole cart.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			req	body		dtos.CreateCouponRequest	true	"coupon"
+//	@Success		201	{object}	dtos.Coupon
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		409	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/coupons [post]
+func (a *AdminRouter) CreateCoupon(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.CreateCoupon"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	var req dtos.CreateCouponRequest
+	err := render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		log.Error("failed to decode request", logger.Err(err))
+		return response.Error("failed to decode request", http.StatusBadRequest)
+	}
+	defer r.Body.Close()
+
+	coupon, err := a.couponService.CreateCoupon(ctx, req)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrProductNotFound) {
+			log.Error(errs.ErrProductNotFound.Error())
+			return response.Error(errs.ErrProductNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrCategoryNotFound) {
+			log.Error(errs.ErrCategoryNotFound.Error())
+			return response.Error(errs.ErrCategoryNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrCouponAlreadyExists) {
+			log.Error(errs.ErrCouponAlreadyExists.Error())
+			return response.Error(errs.ErrCouponAlreadyExists.Error(), http.StatusConflict)
+		}
+
+		log.Error("failed to create coupon", logger.Err(err))
+		return response.Error("failed to create coupon", http.StatusInternalServerError)
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, dtos.ToCoupon(*coupon))
+
+	return nil
+}
+
+// UpdateCoupon godoc
+//
+//	@Summary		update coupon
+//	@Description	change coupon, only passed fields are changed. Passed product_ids and category_ids replace the old ones.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string						true	"coupon id"
+//	@Param			req	body		dtos.UpdateCouponRequest	true	"fields to change"
+//	@Success		200	{object}	dtos.Coupon
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		409	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/coupons/{id} [patch]
+func (a *AdminRouter) UpdateCoupon(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.UpdateCoupon"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	var req dtos.UpdateCouponRequest
+	err := render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		log.Error("failed to decode request", logger.Err(err))
+		return response.Error("failed to decode request", http.StatusBadRequest)
+	}
+	defer r.Body.Close()
+
+	req.ID = r.PathValue("id")
+
+	coupon, err := a.couponService.UpdateCoupon(ctx, req)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrCouponNotFound) {
+			log.Error(errs.ErrCouponNotFound.Error())
+			return response.Error(errs.ErrCouponNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrProductNotFound) {
+			log.Error(errs.ErrProductNotFound.Error())
+			return response.Error(errs.ErrProductNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrCategoryNotFound) {
+			log.Error(errs.ErrCategoryNotFound.Error())
+			return response.Error(errs.ErrCategoryNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrCouponAlreadyExists) {
+			log.Error(errs.ErrCouponAlreadyExists.Error())
+			return response.Error(errs.ErrCouponAlreadyExists.Error(), http.StatusConflict)
+		}
+
+		log.Error("failed to update coupon", logger.Err(err))
+		return response.Error("failed to update coupon", http.StatusInternalServerError)
+	}
+
+	render.JSON(w, r, dtos.ToCoupon(*coupon))
+
+	return nil
+}
+
+// DeleteCoupon godoc
+//
+//	@Summary		delete coupon
+//	@Description	delete coupon, it's removed from carts too
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	string	true	"coupon id"
+//	@Success		204
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		AdminAuth
+//	@Router			/admin/coupons/{id} [delete]
+func (a *AdminRouter) DeleteCoupon(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.admin.DeleteCoupon"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	err := a.couponService.DeleteCoupon(ctx, r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error("invalid coupon id", http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrCouponNotFound) {
+			log.Error(errs.ErrCouponNotFound.Error())
+			return response.Error(errs.ErrCouponNotFound.Error(), http.StatusNotFound)
+		}
+
+		log.Error("failed to delete coupon", logger.Err(err))
+		return response.Error("failed to delete coupon", http.StatusInternalServerError)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+func parseCreateProductForm(r *http.Request, req *dtos.CreateProductRequest) error {
+	const op = "routers.admin.parseCreateProductForm"
+
+	err := r.ParseMultipartForm(10 << 20)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	req.Name = r.FormValue("name")
+	req.Description = r.FormValue("description")
+
+	price, err := strconv.Atoi(r.FormValue("price"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req.Price = price
+
+	req.CategoryID = r.FormValue("category_id")
+
+	req.Quantity, err = strconv.Atoi(r.FormValue("quantity"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	req.ExistingSizes = strings.Split(r.FormValue("existing_sizes"), " ")
+
+	// type of image is checked by its content in service
+	req.Image, _, err = r.FormFile("image")
+	if err != nil {
+		return fmt.Errorf("1111%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func parseUpdateCategoryForm(r *http.Request, req *dtos.UpdateCategoryRequest) error {
+	const op = "routers.admin.parseUpdateCategoryForm"
+
+	req.Name = r.FormValue("name")
+	req.Slug = r.FormValue("slug")
+	req.Description = r.FormValue("description")
+	hasSomething := req.Name != "" || req.Slug != "" || req.Description != ""
+
+	if position := r.FormValue("position"); position != "" {
+		p, err := strconv.Atoi(position)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		req.Position = &p
+		hasSomething = true
+	}
+
+	var err error
+	req.Image, _, err = r.FormFile("image")
+	if err == nil {
+		hasSomething = true
+	}
+
+	if !hasSomething {
+		return fmt.Errorf("%s: %w", op, ErrNothingToUpdate)
+	}
+
+	return nil
+}
+
+func parseUpdateProductForm(r *http.Request, req *dtos.UpdateProductRequest) error {
+	const op = "routers.admin.parseUpdateProductForm"
+
+	hasSomething := false
+
+	req.Name = r.FormValue("name")
+	if req.Name != "" {
+		hasSomething = true
+	}
+
+	req.Description = r.FormValue("description")
+	if req.Description != "" && !hasSomething {
+		hasSomething = true
+	}
+
+	var err error
+	req.Price, err = strconv.Atoi(r.FormValue("price"))
+	if err == nil && !hasSomething {
+		hasSomething = true
+	}
+
+	req.Image, _, err = r.FormFile("image")
+	if err == nil {
+		hasSomething = true
+	}
+
+	if discount := r.FormValue("discount"); discount != "" {
+		d, err := strconv.Atoi(discount)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		req.Discount = &d
+		hasSomething = true
+	}
+
+	discountStartsAt := r.FormValue("discount_starts_at")
+	if discountStartsAt != "" {
+		t, err := time.Parse("2006-01-02 15:04:05", discountStartsAt)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		req.DiscountStartsAt = &t
 		hasSomething = true
 	}
 
@@ -9,6 +9,7 @@ import (
 
 	"github.com/AlexMickh/shop-backend/internal/dtos"
 	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/server/middlewares"
 	"github.com/AlexMickh/shop-backend/pkg/logger"
 	"github.com/AlexMickh/shop-backend/pkg/response"
 	"github.com/AlexMickh/shop-backend/pkg/utils/cookies"
@@ -30,13 +31,20 @@ type AuthRouter struct {
 	authService     AuthService
 	sessionService  SessionService
 	refreshTokenTtl time.Duration
+	guestSecret     string
 }
 
-func New(authService AuthService, sessionService SessionService, refreshTokenTtl time.Duration) *AuthRouter {
+func New(
+	authService AuthService,
+	sessionService SessionService,
+	refreshTokenTtl time.Duration,
+	guestSecret string,
+) *AuthRouter {
 	return &AuthRouter{
 		authService:     authService,
 		sessionService:  sessionService,
 		refreshTokenTtl: refreshTokenTtl,
+		guestSecret:     guestSecret,
 	}
 }
 
@@ -102,7 +110,7 @@ func (a *AuthRouter) Register(w http.ResponseWriter, r *http.Request) error {
 // Login godoc
 //
 //	@Summary		login user
-//	@Description	login user
+//	@Description	login user, guest cart from guest_cart cookie is merged to the user cart
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
@@ -132,6 +140,9 @@ func (a *AuthRouter) Login(w http.ResponseWriter, r *http.Request) error {
 	// 	return response.Error("failed to validate request", http.StatusBadRequest)
 	// }
 
+	// forged or broken cookie just isn't merged
+	req.GuestCartId, _ = cookies.GetSigned(r, middlewares.GuestCartCookie, a.guestSecret)
+
 	accessToken, refreshToken, err := a.authService.Login(ctx, req)
 	if err != nil {
 		if errors.Is(err, errs.ErrUserNotFound) {
@@ -147,6 +158,10 @@ func (a *AuthRouter) Login(w http.ResponseWriter, r *http.Request) error {
 		return response.Error("failed to login user", http.StatusInternalServerError)
 	}
 
+	if req.GuestCartId != "" {
+		cookies.Delete(w, middlewares.GuestCartCookie)
+	}
+
 	cookies.Set(w, "refresh_token", refreshToken, a.refreshTokenTtl)
 	render.Status(r, http.StatusCreated)
 	render.JSON(w, r, dtos.LoginResponse{
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/AlexMickh/shop-backend/internal/dtos"
 	"github.com/AlexMickh/shop-backend/internal/errs"
@@ -20,52 +22,79 @@ import (
 
 type CartService interface {
 	AddToCart(ctx context.Context, req dtos.AddToCartRequest) (uuid.UUID, error)
-	Cart(ctx context.Context, userId string) (models.Cart, error)
-	DeleteItem(ctx context.Context, userId, productId string) error
-	Clear(ctx context.Context, userId string) error
+	Cart(ctx context.Context, owner models.CartOwner) (models.Cart, error)
+	UpdateItem(ctx context.Context, req dtos.UpdateCartItemRequest) error
+	DeleteItem(ctx context.Context, owner models.CartOwner, itemId string) error
+	Clear(ctx context.Context, owner models.CartOwner) error
+	Acknowledge(ctx context.Context, owner models.CartOwner) (models.Cart, error)
+	ApplyCoupon(ctx context.Context, req dtos.ApplyCouponRequest) (models.Cart, error)
+	RemoveCoupon(ctx context.Context, owner models.CartOwner) error
 	Buy(ctx context.Context, userId string) (string, error)
+	TouchGuest(ctx context.Context, guestId string) error
 }
 
 type TokenValidator interface {
-	ValidateJwt(token string) (int64, error)
+	ValidateJwt(token string) (string, error)
 }
 
 type CartRouter struct {
 	cartService    CartService
 	tokenValidator TokenValidator
+	guestSecret    string
+	guestTtl       time.Duration
 }
 
-func New(cartService CartService, tokenValidator TokenValidator) *CartRouter {
+func New(
+	cartService CartService,
+	tokenValidator TokenValidator,
+	guestSecret string,
+	guestTtl time.Duration,
+) *CartRouter {
 	return &CartRouter{
 		cartService:    cartService,
 		tokenValidator: tokenValidator,
+		guestSecret:    guestSecret,
+		guestTtl:       guestTtl,
 	}
 }
 
 func (c *CartRouter) RegisterRoute(r *chi.Mux) {
 	r.Route("/carts", func(r chi.Router) {
-		r.Use(middlewares.Login(c.tokenValidator))
-
-		r.Post("/add/{product_id}", response.ErrorWrapper(c.Add))
-		r.Get("/", response.ErrorWrapper(c.Get))
-		r.Delete("/", response.ErrorWrapper(c.Clear))
-		r.Delete("/{item_id}", response.ErrorWrapper(c.DeleteItem))
-		r.Post("/buy", response.ErrorWrapper(c.Buy))
+		// cart can be filled without account, it's merged to the user cart on login
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.OptionalLogin(c.tokenValidator))
+			r.Use(middlewares.GuestCart(c.guestSecret, c.guestTtl, c.cartService))
+
+			r.Post("/add/{product_id}", response.ErrorWrapper(c.Add))
+			r.Get("/", response.ErrorWrapper(c.Get))
+			r.Delete("/", response.ErrorWrapper(c.Clear))
+			r.Post("/acknowledge", response.ErrorWrapper(c.Acknowledge))
+			r.Post("/coupon", response.ErrorWrapper(c.ApplyCoupon))
+			r.Delete("/coupon", response.ErrorWrapper(c.RemoveCoupon))
+			r.Patch("/{item_id}", response.ErrorWrapper(c.UpdateItem))
+			r.Delete("/{item_id}", response.ErrorWrapper(c.DeleteItem))
+		})
+
+		r.With(middlewares.Login(c.tokenValidator)).Post("/buy", response.ErrorWrapper(c.Buy))
 	})
 }
 
 // Add godoc
 //
 //	@Summary		add product to cart
-//	@Description	add product to cart
+//	@Description	add pieces of product in chosen size to cart, if the size is already in cart its quantity is increased.
+//	@Description	Without token product is added to guest cart kept by guest_cart cookie, it's merged to the user cart on login.
 //	@Tags			carts
 //	@Accept			json
 //	@Produce		json
-//	@Param			product_id	path		int	true	"product id"
+//	@Param			product_id	path		int		true	"product id"
+//	@Param			size		query		string	true	"product size"
+//	@Param			quantity	query		int		false	"pieces to add, 1 by default"
 //	@Success		201			{object}	dtos.AddToCartResponse
 //	@Failure		400			{object}	response.ErrorResponse
 //	@Failure		401			{object}	response.ErrorResponse
 //	@Failure		404			{object}	response.ErrorResponse
+//	@Failure		409			{object}	response.ErrorResponse
 //	@Failure		500			{object}	response.ErrorResponse
 //	@Security		UserAuth
 //	@Router			/carts/add/{product_id} [post]
@@ -74,21 +103,31 @@ func (c *CartRouter) Add(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	log := logger.FromCtx(ctx).With(slog.String("op", op))
 
-	userId, ok := ctx.Value(middlewares.UserIdKey).(string)
-	if !ok {
-		log.Error("user id not found")
-		return response.Error("user id not found", http.StatusUnauthorized)
+	owner, err := cartOwner(ctx)
+	if err != nil {
+		log.Error("cart owner not found", logger.Err(err))
+		return response.Error("cart owner not found", http.StatusUnauthorized)
 	}
 
 	req := dtos.AddToCartRequest{
-		UserId: userId,
+		Owner:    owner,
+		Size:     r.URL.Query().Get("size"),
+		Quantity: 1,
 	}
-	err := httppath.Decode(r, &req)
+	err = httppath.Decode(r, &req)
 	if err != nil {
 		log.Error("failed to decode path value", logger.Err(err))
 		return response.Error("failed to decode path", http.StatusBadRequest)
 	}
 
+	if quantity := r.URL.Query().Get("quantity"); quantity != "" {
+		req.Quantity, err = strconv.Atoi(quantity)
+		if err != nil {
+			log.Error("failed to parse quantity", logger.Err(err))
+			return response.Error("invalid quantity", http.StatusBadRequest)
+		}
+	}
+
 	// productId, err := strconv.ParseInt(r.PathValue("product_id"), 10, 64)
 	// if err != nil || productId < 1 {
 	// 	log.Error("product id is empty")
@@ -97,6 +136,23 @@ func (c *CartRouter) Add(w http.ResponseWriter, r *http.Request) error {
 
 	itemId, err := c.cartService.AddToCart(ctx, req)
 	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrVariantNotFound) {
+			log.Error(errs.ErrVariantNotFound.Error())
+			return response.Error(errs.ErrVariantNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrProductNotFound) {
+			log.Error(errs.ErrProductNotFound.Error())
+			return response.Error(errs.ErrProductNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrNotEnoughStock) {
+			log.Error(errs.ErrNotEnoughStock.Error())
+			return response.Error(errs.ErrNotEnoughStock.Error(), http.StatusConflict)
+		}
+
 		log.Error("failed to add to cart", logger.Err(err))
 		return response.Error("failed to add to cart", http.StatusInternalServerError)
 	}
@@ -112,7 +168,8 @@ func (c *CartRouter) Add(w http.ResponseWriter, r *http.Request) error {
 // Get godoc
 //
 //	@Summary		get users cart
-//	@Description	get users cart
+//	@Description	get users cart with current prices, lines with price or stock changed since they were added are flagged.
+//	@Description	Cart with changes can't be bought until they're acknowledged.
 //	@Tags			carts
 //	@Accept			json
 //	@Produce		json
@@ -127,13 +184,13 @@ func (c *CartRouter) Get(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	log := logger.FromCtx(ctx).With(slog.String("op", op))
 
-	userId, ok := ctx.Value(middlewares.UserIdKey).(string)
-	if !ok {
-		log.Error("user id not found")
-		return response.Error("user id not found", http.StatusUnauthorized)
+	owner, err := cartOwner(ctx)
+	if err != nil {
+		log.Error("cart owner not found", logger.Err(err))
+		return response.Error("cart owner not found", http.StatusUnauthorized)
 	}
 
-	cart, err := c.cartService.Cart(ctx, userId)
+	cart, err := c.cartService.Cart(ctx, owner)
 	if err != nil {
 		if errors.Is(err, errs.ErrCartEmpty) {
 			render.Status(r, http.StatusNoContent)
@@ -144,25 +201,70 @@ func (c *CartRouter) Get(w http.ResponseWriter, r *http.Request) error {
 		return response.Error("failed to get cart", http.StatusInternalServerError)
 	}
 
-	cartItems := make([]*dtos.CartItem, 0, len(cart.Products))
-	finalPrice := 0
-	for _, v := range cart.Products {
-		cartItem := &dtos.CartItem{
-			ID:                v.ID.String(),
-			Name:              v.Name,
-			Price:             v.Price,
-			ImageUrl:          v.ImageUrl,
-			Discount:          v.Discount,
-			DiscountExpiresAt: v.DiscountExpiresAt,
-			Quantity:          v.Quantity,
+	render.JSON(w, r, dtos.ToGetCartResponse(cart))
+
+	return nil
+}
+
+// UpdateItem godoc
+//
+//	@Summary		set cart item quantity
+//	@Description	set quantity of cart item, it can't be greater than stock of the size
+//	@Tags			carts
+//	@Accept			json
+//	@Produce		json
+//	@Param			item_id		path	string	true	"item id"
+//	@Param			quantity	body	int		true	"new quantity"
+//	@Success		204
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		409	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		UserAuth
+//	@Router			/carts/{item_id} [patch]
+func (c *CartRouter) UpdateItem(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.cart.UpdateItem"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	owner, err := cartOwner(ctx)
+	if err != nil {
+		log.Error("cart owner not found", logger.Err(err))
+		return response.Error("cart owner not found", http.StatusUnauthorized)
+	}
+
+	var req dtos.UpdateCartItemRequest
+	err = render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		log.Error("failed to decode request", logger.Err(err))
+		return response.Error("failed to decode request", http.StatusBadRequest)
+	}
+	defer r.Body.Close()
+
+	req.ID = r.PathValue("item_id")
+	req.Owner = owner
+
+	err = c.cartService.UpdateItem(ctx, req)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
 		}
-		cartItems = append(cartItems, cartItem)
+		if errors.Is(err, errs.ErrCartItemNotFound) {
+			log.Error(errs.ErrCartItemNotFound.Error())
+			return response.Error(errs.ErrCartItemNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrNotEnoughStock) {
+			log.Error(errs.ErrNotEnoughStock.Error())
+			return response.Error(errs.ErrNotEnoughStock.Error(), http.StatusConflict)
+		}
+
+		log.Error("failed to update item", logger.Err(err))
+		return response.Error("failed to update item", http.StatusInternalServerError)
 	}
 
-	render.JSON(w, r, dtos.GetCartResponse{
-		Products: cartItems,
-		Price:    finalPrice,
-	})
+	render.NoContent(w, r)
 
 	return nil
 }
@@ -174,7 +276,7 @@ func (c *CartRouter) Get(w http.ResponseWriter, r *http.Request) error {
 //	@Tags			carts
 //	@Accept			json
 //	@Produce		json
-//	@Param			item_id	path	int	true	"item id"
+//	@Param			item_id	path	string	true	"item id"
 //	@Success		204
 //	@Success		400	{object}	response.ErrorResponse
 //	@Success		401	{object}	response.ErrorResponse
@@ -186,10 +288,10 @@ func (c *CartRouter) DeleteItem(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	log := logger.FromCtx(ctx).With(slog.String("op", op))
 
-	userId, ok := ctx.Value(middlewares.UserIdKey).(string)
-	if !ok {
-		log.Error("user id not found")
-		return response.Error("user id not found", http.StatusUnauthorized)
+	owner, err := cartOwner(ctx)
+	if err != nil {
+		log.Error("cart owner not found", logger.Err(err))
+		return response.Error("cart owner not found", http.StatusUnauthorized)
 	}
 
 	// itemId, err := strconv.ParseInt(r.PathValue("item_id"), 10, 64)
@@ -200,7 +302,7 @@ func (c *CartRouter) DeleteItem(w http.ResponseWriter, r *http.Request) error {
 
 	itemId := r.PathValue("item_id")
 
-	err := c.cartService.DeleteItem(ctx, userId, itemId)
+	err = c.cartService.DeleteItem(ctx, owner, itemId)
 	if err != nil {
 		log.Error("failed to delete item", logger.Err(err))
 		return response.Error("failed to delete item", http.StatusInternalServerError)
@@ -229,13 +331,13 @@ func (c *CartRouter) Clear(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	log := logger.FromCtx(ctx).With(slog.String("op", op))
 
-	userId, ok := ctx.Value(middlewares.UserIdKey).(string)
-	if !ok {
-		log.Error("user id not found")
-		return response.Error("user id not found", http.StatusUnauthorized)
+	owner, err := cartOwner(ctx)
+	if err != nil {
+		log.Error("cart owner not found", logger.Err(err))
+		return response.Error("cart owner not found", http.StatusUnauthorized)
 	}
 
-	err := c.cartService.Clear(ctx, userId)
+	err = c.cartService.Clear(ctx, owner)
 	if err != nil {
 		if errors.Is(err, errs.ErrCartEmpty) {
 			log.Error("cart is empty")
@@ -251,16 +353,170 @@ func (c *CartRouter) Clear(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// Acknowledge godoc
+//
+//	@Summary		acknowledge cart changes
+//	@Description	accept current prices of cart items, quantities greater than stock are decreased and sold out items are deleted
+//	@Tags			carts
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	dtos.GetCartResponse
+//	@Success		204
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		UserAuth
+//	@Router			/carts/acknowledge [post]
+func (c *CartRouter) Acknowledge(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.cart.Acknowledge"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	owner, err := cartOwner(ctx)
+	if err != nil {
+		log.Error("cart owner not found", logger.Err(err))
+		return response.Error("cart owner not found", http.StatusUnauthorized)
+	}
+
+	cart, err := c.cartService.Acknowledge(ctx, owner)
+	if err != nil {
+		if errors.Is(err, errs.ErrCartEmpty) {
+			render.Status(r, http.StatusNoContent)
+			return nil
+		}
+
+		log.Error("failed to acknowledge cart changes", logger.Err(err))
+		return response.Error("failed to acknowledge cart changes", http.StatusInternalServerError)
+	}
+
+	render.JSON(w, r, dtos.ToGetCartResponse(cart))
+
+	return nil
+}
+
+// ApplyCoupon godoc
+//
+//	@Summary		apply coupon to cart
+//	@Description	apply promo code to cart, previously applied code is replaced. Returns cart with coupon discount.
+//	@Tags			carts
+//	@Accept			json
+//	@Produce		json
+//	@Param			req	body		dtos.ApplyCouponRequest	true	"promo code"
+//	@Success		200	{object}	dtos.GetCartResponse
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		409	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		UserAuth
+//	@Router			/carts/coupon [post]
+func (c *CartRouter) ApplyCoupon(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.cart.ApplyCoupon"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	owner, err := cartOwner(ctx)
+	if err != nil {
+		log.Error("cart owner not found", logger.Err(err))
+		return response.Error("cart owner not found", http.StatusUnauthorized)
+	}
+
+	var req dtos.ApplyCouponRequest
+	err = render.DecodeJSON(r.Body, &req)
+	if err != nil {
+		log.Error("failed to decode request", logger.Err(err))
+		return response.Error("failed to decode request", http.StatusBadRequest)
+	}
+	defer r.Body.Close()
+
+	req.Owner = owner
+
+	cart, err := c.cartService.ApplyCoupon(ctx, req)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrCouponNotFound) {
+			log.Error(errs.ErrCouponNotFound.Error())
+			return response.Error(errs.ErrCouponNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrCartEmpty) {
+			log.Error(errs.ErrCartEmpty.Error())
+			return response.Error(errs.ErrCartEmpty.Error(), http.StatusNotFound)
+		}
+		for _, couponErr := range []error{
+			errs.ErrCouponNotActive,
+			errs.ErrCouponNotApplicable,
+			errs.ErrCouponMinOrderPrice,
+			errs.ErrCouponUsageLimit,
+		} {
+			if errors.Is(err, couponErr) {
+				log.Error(couponErr.Error())
+				return response.Error(couponErr.Error(), http.StatusConflict)
+			}
+		}
+
+		log.Error("failed to apply coupon", logger.Err(err))
+		return response.Error("failed to apply coupon", http.StatusInternalServerError)
+	}
+
+	render.JSON(w, r, dtos.ToGetCartResponse(cart))
+
+	return nil
+}
+
+// RemoveCoupon godoc
+//
+//	@Summary		remove coupon from cart
+//	@Description	remove applied promo code from cart
+//	@Tags			carts
+//	@Accept			json
+//	@Produce		json
+//	@Success		204
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		UserAuth
+//	@Router			/carts/coupon [delete]
+func (c *CartRouter) RemoveCoupon(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.cart.RemoveCoupon"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	owner, err := cartOwner(ctx)
+	if err != nil {
+		log.Error("cart owner not found", logger.Err(err))
+		return response.Error("cart owner not found", http.StatusUnauthorized)
+	}
+
+	err = c.cartService.RemoveCoupon(ctx, owner)
+	if err != nil {
+		if errors.Is(err, errs.ErrCouponNotFound) {
+			log.Error(errs.ErrCouponNotFound.Error())
+			return response.Error(errs.ErrCouponNotFound.Error(), http.StatusNotFound)
+		}
+
+		log.Error("failed to remove coupon", logger.Err(err))
+		return response.Error("failed to remove coupon", http.StatusInternalServerError)
+	}
+
+	render.NoContent(w, r)
+
+	return nil
+}
+
 // Buy godoc
 //
 //	@Summary		return link to pay
-//	@Description	return link to pay
+//	@Description	return link to pay, if prices or stock of cart items have changed they must be acknowledged first.
+//	@Description	Applied coupon that can't be used anymore must be removed.
 //	@Tags			carts
 //	@Accept			json
 //	@Produce		json
 //	@Success		204	{object}	dtos.BuyResponse
 //	@Success		401	{object}	response.ErrorResponse
 //	@Success		404	{object}	response.ErrorResponse
+//	@Failure		409	{object}	response.ErrorResponse
 //	@Failure		500	{object}	response.ErrorResponse
 //	@Security		UserAuth
 //	@Router			/carts/buy [post]
@@ -281,6 +537,26 @@ func (c *CartRouter) Buy(w http.ResponseWriter, r *http.Request) error {
 			log.Error(errs.ErrCartEmpty.Error())
 			return response.Error(errs.ErrCartEmpty.Error(), http.StatusNotFound)
 		}
+		if errors.Is(err, errs.ErrNotEnoughStock) {
+			log.Error(errs.ErrNotEnoughStock.Error())
+			return response.Error(errs.ErrNotEnoughStock.Error(), http.StatusConflict)
+		}
+		if errors.Is(err, errs.ErrCartChanged) {
+			log.Error(errs.ErrCartChanged.Error())
+			return response.Error(errs.ErrCartChanged.Error(), http.StatusConflict)
+		}
+		for _, couponErr := range []error{
+			errs.ErrCouponNotFound,
+			errs.ErrCouponNotActive,
+			errs.ErrCouponNotApplicable,
+			errs.ErrCouponMinOrderPrice,
+			errs.ErrCouponUsageLimit,
+		} {
+			if errors.Is(err, couponErr) {
+				log.Error(couponErr.Error())
+				return response.Error(couponErr.Error(), http.StatusConflict)
+			}
+		}
 
 		log.Error("failed to buy", logger.Err(err))
 		return response.Error("failed to buy", http.StatusInternalServerError)
@@ -293,3 +569,26 @@ func (c *CartRouter) Buy(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+// cartOwner returns logged in user, or guest if request has no token
+func cartOwner(ctx context.Context) (models.CartOwner, error) {
+	if userId, ok := ctx.Value(middlewares.UserIdKey).(string); ok {
+		id, err := uuid.Parse(userId)
+		if err != nil {
+			return models.CartOwner{}, err
+		}
+
+		return models.CartOwner{UserID: id}, nil
+	}
+
+	if guestId, ok := ctx.Value(middlewares.GuestIdKey).(string); ok {
+		id, err := uuid.Parse(guestId)
+		if err != nil {
+			return models.CartOwner{}, err
+		}
+
+		return models.CartOwner{GuestID: id}, nil
+	}
+
+	return models.CartOwner{}, errors.New("neither user id nor guest id in context")
+}
@@ -2,10 +2,12 @@ package category_router
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 
 	"github.com/AlexMickh/shop-backend/internal/dtos"
+	"github.com/AlexMickh/shop-backend/internal/errs"
 	"github.com/AlexMickh/shop-backend/internal/models"
 	"github.com/AlexMickh/shop-backend/pkg/logger"
 	"github.com/AlexMickh/shop-backend/pkg/response"
@@ -14,7 +16,8 @@ import (
 )
 
 type CategoryService interface {
-	AllCategories(ctx context.Context) ([]models.Category, error)
+	CategoryTree(ctx context.Context) ([]models.Category, error)
+	CategoryById(ctx context.Context, id string) (*models.Category, error)
 }
 
 type CategoryRouter struct {
@@ -29,12 +32,13 @@ func New(categoryService CategoryService) *CategoryRouter {
 
 func (c *CategoryRouter) RegisterRoute(r *chi.Mux) {
 	r.Get("/categories", response.ErrorWrapper(c.All))
+	r.Get("/categories/{id}", response.ErrorWrapper(c.CategoryById))
 }
 
 // All godoc
 //
 //	@Summary		get all categories
-//	@Description	get all categories
+//	@Description	get tree of all categories, children are ordered by position
 //	@Tags			categories
 //	@Accept			json
 //	@Produce		json
@@ -46,7 +50,7 @@ func (c *CategoryRouter) All(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	log := logger.FromCtx(ctx).With(slog.String("op", op))
 
-	categories, err := c.categoryService.AllCategories(ctx)
+	categories, err := c.categoryService.CategoryTree(ctx)
 	if err != nil {
 		log.Error("failed to get categories")
 		return response.Error("failed to get categories", http.StatusInternalServerError)
@@ -56,3 +60,41 @@ func (c *CategoryRouter) All(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+// CategoryById godoc
+//
+//	@Summary		get category by id
+//	@Description	get category with its direct subcategories, product count includes products of all subcategories
+//	@Tags			categories
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"category id"
+//	@Success		200	{object}	dtos.GetCategoryResponse
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Router			/categories/{id} [get]
+func (c *CategoryRouter) CategoryById(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.categories.CategoryById"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	category, err := c.categoryService.CategoryById(ctx, r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrCategoryNotFound) {
+			log.Error(errs.ErrCategoryNotFound.Error())
+			return response.Error(errs.ErrCategoryNotFound.Error(), http.StatusNotFound)
+		}
+
+		log.Error("failed to get category", logger.Err(err))
+		return response.Error("failed to get category", http.StatusInternalServerError)
+	}
+
+	render.JSON(w, r, dtos.ToGetCategoryResponse(category))
+
+	return nil
+}
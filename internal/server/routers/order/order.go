@@ -0,0 +1,184 @@
+package order_router
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/AlexMickh/shop-backend/internal/dtos"
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/internal/server/middlewares"
+	"github.com/AlexMickh/shop-backend/pkg/logger"
+	"github.com/AlexMickh/shop-backend/pkg/response"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+type OrderService interface {
+	Orders(ctx context.Context, userId string) ([]models.Order, error)
+	UserOrderById(ctx context.Context, userId, id string) (*models.Order, error)
+	Cancel(ctx context.Context, userId, id string) error
+}
+
+type TokenValidator interface {
+	ValidateJwt(token string) (string, error)
+}
+
+type OrderRouter struct {
+	orderService   OrderService
+	tokenValidator TokenValidator
+}
+
+func New(orderService OrderService, tokenValidator TokenValidator) *OrderRouter {
+	return &OrderRouter{
+		orderService:   orderService,
+		tokenValidator: tokenValidator,
+	}
+}
+
+func (o *OrderRouter) RegisterRoute(r *chi.Mux) {
+	r.Route("/orders", func(r chi.Router) {
+		r.Use(middlewares.Login(o.tokenValidator))
+
+		r.Get("/", response.ErrorWrapper(o.All))
+		r.Get("/{id}", response.ErrorWrapper(o.OrderById))
+		r.Post("/{id}/cancel", response.ErrorWrapper(o.Cancel))
+	})
+}
+
+// All godoc
+//
+//	@Summary		get users orders
+//	@Description	get users orders, newest first
+//	@Tags			orders
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	dtos.GetOrdersResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		UserAuth
+//	@Router			/orders [get]
+func (o *OrderRouter) All(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.order.All"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	userId, ok := ctx.Value(middlewares.UserIdKey).(string)
+	if !ok {
+		log.Error("user id not found")
+		return response.Error("user id not found", http.StatusUnauthorized)
+	}
+
+	orders, err := o.orderService.Orders(ctx, userId)
+	if err != nil {
+		log.Error("failed to get orders", logger.Err(err))
+		return response.Error("failed to get orders", http.StatusInternalServerError)
+	}
+
+	render.JSON(w, r, dtos.ToGetOrdersResponse(orders))
+
+	return nil
+}
+
+// OrderById godoc
+//
+//	@Summary		get users order by id
+//	@Description	get users order by id
+//	@Tags			orders
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"order id"
+//	@Success		200	{object}	dtos.Order
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		UserAuth
+//	@Router			/orders/{id} [get]
+func (o *OrderRouter) OrderById(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.order.OrderById"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	userId, ok := ctx.Value(middlewares.UserIdKey).(string)
+	if !ok {
+		log.Error("user id not found")
+		return response.Error("user id not found", http.StatusUnauthorized)
+	}
+
+	id := r.PathValue("id")
+
+	order, err := o.orderService.UserOrderById(ctx, userId, id)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error("invalid order id", http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrOrderNotFound) {
+			log.Error(errs.ErrOrderNotFound.Error())
+			return response.Error(errs.ErrOrderNotFound.Error(), http.StatusNotFound)
+		}
+
+		log.Error("failed to get order", logger.Err(err))
+		return response.Error("failed to get order", http.StatusInternalServerError)
+	}
+
+	render.JSON(w, r, dtos.ToOrder(*order))
+
+	return nil
+}
+
+// Cancel godoc
+//
+//	@Summary		cancel order
+//	@Description	cancel order, only orders waiting for payment can be cancelled
+//	@Tags			orders
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	string	true	"order id"
+//	@Success		204
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		401	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		409	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Security		UserAuth
+//	@Router			/orders/{id}/cancel [post]
+func (o *OrderRouter) Cancel(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.order.Cancel"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	userId, ok := ctx.Value(middlewares.UserIdKey).(string)
+	if !ok {
+		log.Error("user id not found")
+		return response.Error("user id not found", http.StatusUnauthorized)
+	}
+
+	id := r.PathValue("id")
+
+	err := o.orderService.Cancel(ctx, userId, id)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error("invalid order id", http.StatusBadRequest)
+		}
+		if errors.Is(err, errs.ErrOrderNotFound) {
+			log.Error(errs.ErrOrderNotFound.Error())
+			return response.Error(errs.ErrOrderNotFound.Error(), http.StatusNotFound)
+		}
+		if errors.Is(err, errs.ErrOrderStatusTransition) {
+			log.Error(errs.ErrOrderStatusTransition.Error())
+			return response.Error(errs.ErrOrderStatusTransition.Error(), http.StatusConflict)
+		}
+
+		log.Error("failed to cancel order", logger.Err(err))
+		return response.Error("failed to cancel order", http.StatusInternalServerError)
+	}
+
+	render.NoContent(w, r)
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package payment_router
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/AlexMickh/shop-backend/internal/dtos"
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/server/middlewares"
+	"github.com/AlexMickh/shop-backend/pkg/logger"
+	"github.com/AlexMickh/shop-backend/pkg/response"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+type OrderService interface {
+	ProcessPayment(ctx context.Context, paymentId string) error
+}
+
+type PaymentRouter struct {
+	orderService    OrderService
+	allowedNetworks []netip.Prefix
+	trustProxy      bool
+}
+
+func New(orderService OrderService, allowedNetworks []netip.Prefix, trustProxy bool) *PaymentRouter {
+	return &PaymentRouter{
+		orderService:    orderService,
+		allowedNetworks: allowedNetworks,
+		trustProxy:      trustProxy,
+	}
+}
+
+func (p *PaymentRouter) RegisterRoute(r *chi.Mux) {
+	r.Route("/payments", func(r chi.Router) {
+		r.With(middlewares.AllowIPs(p.allowedNetworks, p.trustProxy)).
+			Post("/yookassa/webhook", response.ErrorWrapper(p.YookassaWebhook))
+	})
+}
+
+// YookassaWebhook godoc
+//
+//	@Summary		yookassa notifications
+//	@Description	receives payment.waiting_for_capture, payment.succeeded and payment.canceled notifications, payment state is re-fetched from yookassa
+//	@Tags			payments
+//	@Accept			json
+//	@Produce		json
+//	@Param			req	body	dtos.YookassaNotification	true	"notification"
+//	@Success		200
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		403	{object}	response.ErrorResponse
+//	@Failure		404	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Router			/payments/yookassa/webhook [post]
+func (p *PaymentRouter) YookassaWebhook(w http.ResponseWriter, r *http.Request) error {
+	const op = "routers.payment.YookassaWebhook"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	var req dtos.YookassaNotification
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		log.Error("failed to decode request", logger.Err(err))
+		return response.Error("failed to decode request", http.StatusBadRequest)
+	}
+
+	if !strings.HasPrefix(req.Event, "payment.") {
+		log.Info("notification skipped", slog.String("event", req.Event))
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	if req.Object.ID == "" {
+		log.Error("payment id is empty")
+		return response.Error("payment id is empty", http.StatusBadRequest)
+	}
+
+	err := p.orderService.ProcessPayment(ctx, req.Object.ID)
+	if err != nil {
+		if errors.Is(err, errs.ErrPaymentNotFound) || errors.Is(err, errs.ErrOrderNotFound) {
+			log.Error("unknown payment", slog.String("payment_id", req.Object.ID), logger.Err(err))
+			return response.Error(errs.ErrPaymentNotFound.Error(), http.StatusNotFound)
+		}
+
+		// payment is canceled or refunded, repeated notification can't change anything
+		if errors.Is(err, errs.ErrPaymentPriceMismatch) {
+			log.Error("payment price mismatch", slog.String("payment_id", req.Object.ID), logger.Err(err))
+			w.WriteHeader(http.StatusOK)
+			return nil
+		}
+
+		// yookassa repeats notification until it gets 200, so transient errors are retried
+		log.Error("failed to process payment", logger.Err(err))
+		return response.Error("failed to process payment", http.StatusInternalServerError)
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	return nil
+}
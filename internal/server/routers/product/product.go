@@ -3,6 +3,7 @@ package product_router
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -19,22 +20,29 @@ import (
 
 type ProductService interface {
 	ProductById(ctx context.Context, id string) (*models.Product, error)
-	ProductCards(ctx context.Context, req dtos.GetProductsRequest) ([]models.ProductCard, error)
+	ProductCards(ctx context.Context, req dtos.GetProductsRequest) (models.ProductCardsPage, error)
+}
+
+type SuggestService interface {
+	Suggest(ctx context.Context, req dtos.SuggestRequest) (models.Suggestions, error)
 }
 
 type ProductRouter struct {
 	productService ProductService
+	suggestService SuggestService
 }
 
-func New(productService ProductService) *ProductRouter {
+func New(productService ProductService, suggestService SuggestService) *ProductRouter {
 	return &ProductRouter{
 		productService: productService,
+		suggestService: suggestService,
 	}
 }
 
 func (p *ProductRouter) RegisterRoute(r *chi.Mux) {
 	r.Route("/products", func(r chi.Router) {
 		r.Get("/", response.ErrorWrapper(p.Products))
+		r.Get("/suggest", response.ErrorWrapper(p.Suggest))
 		r.Get("/{id}", response.ErrorWrapper(p.ProductById))
 	})
 }
@@ -42,17 +50,22 @@ func (p *ProductRouter) RegisterRoute(r *chi.Mux) {
 // Products godoc
 //
 //	@Summary		get products list
-//	@Description	get products list
+//	@Description	get products page, next page is requested with next_cursor of previous one. First page has facets: products count for every size and category
 //	@Tags			products
 //	@Accept			json
 //	@Produce		json
-//	@Param			page		query		int		true	"page for pagination"
-//	@Param			popularity	query		bool	false	"enable filtering by popularity"
-//	@Param			price		query		int		false	"enable filtering by price"
-//	@Param			category_id	query		int		false	"products category id"
-//	@Param			search		query		string	false	"search patern"
+//	@Param			limit		query		int		false	"products on page, server default if not set"
+//	@Param			cursor		query		string	false	"next_cursor from previous page, sort must be the same"
+//	@Param			sort		query		string	false	"newest (default), popular, price_asc, price_desc, discount or relevance (default with search), price is sorted with discount"
+//	@Param			category_id	query		string	false	"products category id, products of its subcategories are included"
+//	@Param			search		query		string	false	"words to find in name and description, supports quotes, or and minus"
+//	@Param			min_price	query		int		false	"min price with discount in kopecks"
+//	@Param			max_price	query		int		false	"max price with discount in kopecks"
+//	@Param			size		query		string	false	"sizes separated by comma, product has at least one of them"
+//	@Param			in_stock	query		bool	false	"only products in stock, with size only these sizes are checked"
+//	@Param			on_sale		query		bool	false	"only products with discount"
 //	@Success		200			{object}	dtos.GetProductsResponse
-//	@Failure		404			{object}	response.ErrorResponse
+//	@Failure		400			{object}	response.ErrorResponse
 //	@Failure		500			{object}	response.ErrorResponse
 //	@Router			/products [get]
 func (p *ProductRouter) Products(w http.ResponseWriter, r *http.Request) error {
@@ -60,74 +73,70 @@ func (p *ProductRouter) Products(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	log := logger.FromCtx(ctx).With(slog.String("op", op))
 
-	var page int
-	var err error
-
-	pageStr := r.URL.Query().Get("page")
-	if pageStr == "" {
-		page = 0
-	} else {
-		page, err = strconv.Atoi(pageStr)
-		if err != nil || page < 1 {
-			page = 0
-		} else {
-			page -= 1
-		}
-	}
-
-	popularity := r.URL.Query().Has("popularity")
-
-	var price int
-	priceStr := r.URL.Query().Get("price")
-	if priceStr == "" {
-		price = -1
-	} else {
-		price, err = strconv.Atoi(priceStr)
-		if err != nil {
-			price = -1
-		}
-	}
-
-	categoryId := r.URL.Query().Get("category_id")
-
 	search := r.URL.Query().Get("search")
 	search = strings.ReplaceAll(search, "_", " ")
 
 	req := dtos.GetProductsRequest{
-		Page:       page,
-		Popularity: popularity,
-		Price:      price,
-		CategoryID: categoryId,
+		Cursor:     r.URL.Query().Get("cursor"),
+		Sort:       r.URL.Query().Get("sort"),
+		CategoryID: r.URL.Query().Get("category_id"),
 		Search:     search,
 	}
 
-	products, err := p.productService.ProductCards(ctx, req)
+	if err := parseProductsQuery(r, &req); err != nil {
+		log.Error("failed to parse query", logger.Err(err))
+		return response.Error("invalid query", http.StatusBadRequest)
+	}
+
+	page, err := p.productService.ProductCards(ctx, req)
 	if err != nil {
-		if errors.Is(err, errs.ErrProductNotFound) {
-			log.Error(errs.ErrProductNotFound.Error())
-			return response.Error(errs.ErrProductNotFound.Error(), http.StatusNotFound)
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
 		}
 
 		log.Error("failed to get product cards", logger.Err(err))
 		return response.Error("failed to get product cards", http.StatusInternalServerError)
 	}
 
-	resp := dtos.GetProductsResponse{
-		Products: make([]dtos.Product, 0, len(products)),
+	render.JSON(w, r, dtos.ToGetProductsResponse(page))
+
+	return nil
+}
+
+// Suggest godoc
+//
+//	@Summary		get search suggestions
+//	@Description	get the most popular products and categories which names start with typed words, the last word can be not finished
+//	@Tags			products
+//	@Accept			json
+//	@Produce		json
+//	@Param			q	query		string	true	"typed text"
+//	@Success		200	{object}	dtos.SuggestResponse
+//	@Failure		400	{object}	response.ErrorResponse
+//	@Failure		500	{object}	response.ErrorResponse
+//	@Router			/products/suggest [get]
+func (p *ProductRouter) Suggest(w http.ResponseWriter, r *http.Request) error {
+	const op = "router.product.Suggest"
+	ctx := r.Context()
+	log := logger.FromCtx(ctx).With(slog.String("op", op))
+
+	req := dtos.SuggestRequest{
+		Query: r.URL.Query().Get("q"),
 	}
-	for _, v := range products {
-		product := dtos.Product{
-			ID:                v.ID,
-			Name:              v.Name,
-			Price:             v.Price,
-			ImageUrl:          v.ImageUrl,
-			Discount:          v.Discount,
-			DiscountExpiresAt: v.DiscountExpiresAt,
+
+	suggestions, err := p.suggestService.Suggest(ctx, req)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalidRequest) {
+			log.Error(errs.ErrInvalidRequest.Error())
+			return response.Error(errs.ErrInvalidRequest.Error(), http.StatusBadRequest)
 		}
-		resp.Products = append(resp.Products, product)
+
+		log.Error("failed to get suggestions", logger.Err(err))
+		return response.Error("failed to get suggestions", http.StatusInternalServerError)
 	}
 
-	render.JSON(w, r, resp)
+	render.JSON(w, r, dtos.ToSuggestResponse(suggestions))
 
 	return nil
 }
@@ -173,9 +182,12 @@ func (p *ProductRouter) ProductById(w http.ResponseWriter, r *http.Request) erro
 		Name:              product.Name,
 		Description:       product.Description,
 		Price:             product.Price,
+		LowestPrice30d:    product.LowestPrice,
 		Quantity:          product.Quantity,
 		ExistingSizes:     make([]string, 0, len(product.ExistingSizes)),
+		Sizes:             make([]dtos.SizeStock, 0, len(product.Variants)),
 		ImageUrl:          product.ImageUrl,
+		Images:            dtos.ToProductImages(product.Images),
 		Discount:          product.Discount,
 		DiscountExpiresAt: product.DiscountExpiresAt,
 		Category: struct {
@@ -189,8 +201,61 @@ func (p *ProductRouter) ProductById(w http.ResponseWriter, r *http.Request) erro
 	for _, v := range product.ExistingSizes {
 		resp.ExistingSizes = append(resp.ExistingSizes, string(v))
 	}
+	for _, v := range product.Variants {
+		resp.Sizes = append(resp.Sizes, dtos.SizeStock{
+			Size:  string(v.Size),
+			Stock: v.Stock,
+		})
+	}
 
 	render.JSON(w, r, resp)
 
 	return nil
 }
+
+// parseProductsQuery parses numbers, flags and sizes of products filter
+func parseProductsQuery(r *http.Request, req *dtos.GetProductsRequest) error {
+	const op = "router.product.parseProductsQuery"
+
+	var err error
+	query := r.URL.Query()
+
+	for name, value := range map[string]*int{
+		"limit":     &req.Limit,
+		"min_price": &req.MinPrice,
+		"max_price": &req.MaxPrice,
+	} {
+		if query.Get(name) == "" {
+			continue
+		}
+
+		*value, err = strconv.Atoi(query.Get(name))
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", op, name, err)
+		}
+	}
+
+	for name, value := range map[string]*bool{
+		"in_stock": &req.InStock,
+		"on_sale":  &req.OnSale,
+	} {
+		if query.Get(name) == "" {
+			continue
+		}
+
+		*value, err = strconv.ParseBool(query.Get(name))
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", op, name, err)
+		}
+	}
+
+	for _, v := range query["size"] {
+		for size := range strings.SplitSeq(v, ",") {
+			if size != "" {
+				req.Sizes = append(req.Sizes, size)
+			}
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"net/http"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/AlexMickh/shop-backend/internal/dtos"
 	"github.com/AlexMickh/shop-backend/internal/models"
 	"github.com/AlexMickh/shop-backend/internal/server/routers"
+	"github.com/AlexMickh/shop-backend/internal/server/static"
 	"github.com/AlexMickh/shop-backend/pkg/logger"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -45,7 +47,7 @@ type CategoryService interface {
 type ProductService interface {
 	CreateProduct(ctx context.Context, req dtos.CreateProductRequest) (int64, error)
 	ProductById(ctx context.Context, id int64) (*models.Product, error)
-	ProductCards(ctx context.Context, req dtos.GetProductsRequest) ([]models.ProductCard, error)
+	ProductCards(ctx context.Context, req dtos.GetProductsRequest) (models.ProductCardsPage, error)
 	UpdateProduct(ctx context.Context, req *dtos.UpdateProductRequest) error
 	DeleteProduct(ctx context.Context, id int64) error
 }
@@ -57,6 +59,8 @@ type CartService interface {
 	Clear(ctx context.Context, userId int64) error
 }
 
+// New creates api server. If filesPath isn't empty, files from it are served on staticCfg.Addr.
+//
 // @title						Three Api
 // @version					1.0
 // @description				Your API description
@@ -67,6 +71,8 @@ type CartService interface {
 func New(
 	ctx context.Context,
 	cfg config.ServerConfig,
+	staticCfg config.StaticConfig,
+	filesPath string,
 	routers []routers.Router,
 ) (*Server, error) {
 	const op = "server.New"
@@ -86,11 +92,16 @@ func New(
 		httpSwagger.URL(addr), //The url pointing to API definition
 	))
 
+	// metrics of file server and go runtime
+	r.With(middleware.BasicAuth("admin-auth", map[string]string{
+		cfg.AdminLogin: cfg.AdminPassword,
+	})).Get("/debug/vars", expvar.Handler().ServeHTTP)
+
 	for _, router := range routers {
 		router.RegisterRoute(r)
 	}
 
-	return &Server{
+	server := &Server{
 		srv: &http.Server{
 			Addr:         cfg.Addr,
 			Handler:      r,
@@ -98,20 +109,27 @@ func New(
 			WriteTimeout: cfg.Timeout,
 			IdleTimeout:  cfg.IdleTimeout,
 		},
-		fileServer: &http.Server{
-			Addr:         cfg.FileServerAddr,
-			Handler:      http.FileServer(http.Dir("./public")),
-			ReadTimeout:  cfg.Timeout,
-			WriteTimeout: cfg.Timeout,
-			IdleTimeout:  cfg.IdleTimeout,
-		},
-	}, nil
+	}
+
+	if filesPath != "" {
+		server.fileServer = &http.Server{
+			Addr:         staticCfg.Addr,
+			Handler:      static.New(filesPath, staticCfg),
+			ReadTimeout:  staticCfg.ReadTimeout,
+			WriteTimeout: staticCfg.WriteTimeout,
+			IdleTimeout:  staticCfg.IdleTimeout,
+		}
+	}
+
+	return server, nil
 }
 
 func (s *Server) Run(ctx context.Context) error {
 	const op = "server.Run"
 
-	go s.fileServer.ListenAndServe()
+	if s.fileServer != nil {
+		go s.fileServer.ListenAndServe()
+	}
 
 	if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("%s: %w", op, err)
@@ -127,8 +145,10 @@ func (s *Server) GracefulStop(ctx context.Context) error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := s.fileServer.Shutdown(ctx); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+	if s.fileServer != nil {
+		if err := s.fileServer.Shutdown(ctx); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
 	}
 
 	return nil
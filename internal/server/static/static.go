@@ -0,0 +1,245 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/config"
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// immutableCacheControl is sent for files named by hash of content, they never change
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// metrics of file server, they're published on /debug/vars of api server
+var metrics = expvar.NewMap("file_server")
+
+// Handler serves files of one directory. Directories aren't listed and hidden files
+// (temporary files of storage) aren't served.
+type Handler struct {
+	dir             string
+	maxAge          time.Duration
+	compressMinSize int64
+	compressMaxSize int64
+}
+
+func New(dir string, cfg config.StaticConfig) *Handler {
+	return &Handler{
+		dir:             dir,
+		maxAge:          cfg.MaxAge,
+		compressMinSize: cfg.CompressMinSize,
+		compressMaxSize: cfg.CompressMaxSize,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	metrics.Add("requests", 1)
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	defer sw.record()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		sw.Header().Set("Allow", "GET, HEAD")
+		http.Error(sw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// storage keeps all files in root directory, so nested paths are never served
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" || strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+		http.NotFound(sw, r)
+		return
+	}
+
+	file, err := os.Open(filepath.Join(h.dir, name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(sw, r)
+			return
+		}
+		http.Error(sw, "failed to open file", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(sw, "failed to open file", http.StatusInternalServerError)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		http.NotFound(sw, r)
+		return
+	}
+
+	header := sw.Header()
+
+	// etag of hashed file is its hash, other files are identified by time and size like in nginx
+	var etag string
+	if hash, ok := contentHash(name); ok {
+		header.Set("Cache-Control", immutableCacheControl)
+		etag = hash
+	} else {
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(h.maxAge.Seconds())))
+		etag = fmt.Sprintf("%x-%x", info.ModTime().Unix(), info.Size())
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	var content io.ReadSeeker = file
+	if h.compressible(contentType, info.Size()) {
+		header.Add("Vary", "Accept-Encoding")
+
+		if encoding := acceptedEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+			compressed, err := compress(file, encoding)
+			if err != nil {
+				http.Error(sw, "failed to compress file", http.StatusInternalServerError)
+				return
+			}
+
+			// compressed file is another representation, so it has own etag and ranges
+			header.Set("Content-Encoding", encoding)
+			etag += "-" + encoding
+			content = bytes.NewReader(compressed)
+			metrics.Add("compressed_"+encoding, 1)
+		}
+	}
+
+	// ServeContent answers If-None-Match and Range by etag
+	header.Set("ETag", `"`+etag+`"`)
+	http.ServeContent(sw, r, name, info.ModTime(), content)
+}
+
+// compressible reports if file is text which is worth compressing, images are already compressed
+func (h *Handler) compressible(contentType string, size int64) bool {
+	if size < h.compressMinSize || size > h.compressMaxSize {
+		return false
+	}
+
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch mediaType {
+	case "application/json", "application/javascript", "text/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+
+	return strings.HasPrefix(mediaType, "text/")
+}
+
+// contentHash returns hash from name of file saved by storage, it's sha256 in hex
+func contentHash(name string) (string, bool) {
+	hash := strings.TrimSuffix(name, filepath.Ext(name))
+	if len(hash) != 64 {
+		return "", false
+	}
+
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return "", false
+		}
+	}
+
+	return hash, true
+}
+
+// acceptedEncoding returns the best encoding accepted by client, brotli is preferred over gzip
+func acceptedEncoding(header string) string {
+	accepted := make(map[string]bool)
+
+	for _, v := range strings.Split(header, ",") {
+		encoding, params, _ := strings.Cut(v, ";")
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+
+		accepted[strings.ToLower(strings.TrimSpace(encoding))] = quality > 0
+	}
+
+	if accepted[encodingBrotli] {
+		return encodingBrotli
+	}
+	if accepted[encodingGzip] {
+		return encodingGzip
+	}
+
+	return ""
+}
+
+func compress(file io.Reader, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	var writer io.WriteCloser
+	if encoding == encodingBrotli {
+		writer = brotli.NewWriter(&buf)
+	} else {
+		writer = gzip.NewWriter(&buf)
+	}
+
+	if _, err := io.Copy(writer, file); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// statusWriter remembers status and size of response for metrics
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusWriter) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+
+	return n, err
+}
+
+func (s *statusWriter) record() {
+	metrics.Add("bytes_sent", s.bytes)
+
+	switch {
+	case s.status == http.StatusNotModified:
+		metrics.Add("not_modified", 1)
+	case s.status == http.StatusPartialContent:
+		metrics.Add("partial", 1)
+	case s.status == http.StatusNotFound:
+		metrics.Add("not_found", 1)
+	case s.status >= http.StatusInternalServerError:
+		metrics.Add("errors", 1)
+	}
+}
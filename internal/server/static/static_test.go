@@ -0,0 +1,235 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/config"
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/require"
+)
+
+const hashedName = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08.jpg"
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	dir := t.TempDir()
+	text := strings.Repeat("compressible text ", 100)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, hashedName), []byte("hashed image"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.png"), []byte("old image"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte(text), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".tmp-123"), []byte("half written"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "file.png"), []byte("nested"), 0600))
+
+	handler := New(dir, config.StaticConfig{
+		MaxAge:          time.Hour,
+		CompressMinSize: 100,
+		CompressMaxSize: 1 << 20,
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		header     map[string]string
+		wantStatus int
+		wantHeader map[string]string
+		wantBody   string
+	}{
+		{
+			name:       "hashed file case",
+			method:     http.MethodGet,
+			path:       "/" + hashedName,
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"Cache-Control": immutableCacheControl,
+				"ETag":          `"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`,
+				"Content-Type":  "image/jpeg",
+			},
+			wantBody: "hashed image",
+		},
+		{
+			name:       "not hashed file case",
+			method:     http.MethodGet,
+			path:       "/old.png",
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"Cache-Control": "public, max-age=3600",
+			},
+			wantBody: "old image",
+		},
+		{
+			name:   "not modified case",
+			method: http.MethodGet,
+			path:   "/" + hashedName,
+			header: map[string]string{
+				"If-None-Match": `"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`,
+			},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:   "range case",
+			method: http.MethodGet,
+			path:   "/" + hashedName,
+			header: map[string]string{
+				"Range": "bytes=0-5",
+			},
+			wantStatus: http.StatusPartialContent,
+			wantBody:   "hashed",
+		},
+		{
+			name:       "head case",
+			method:     http.MethodHead,
+			path:       "/old.png",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "method not allowed case",
+			method:     http.MethodPost,
+			path:       "/old.png",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantHeader: map[string]string{
+				"Allow": "GET, HEAD",
+			},
+		},
+		{
+			name:       "directory listing case",
+			method:     http.MethodGet,
+			path:       "/",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "subdirectory case",
+			method:     http.MethodGet,
+			path:       "/sub/file.png",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "hidden file case",
+			method:     http.MethodGet,
+			path:       "/.tmp-123",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "path traversal case",
+			method:     http.MethodGet,
+			path:       "/../static.go",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "not found case",
+			method:     http.MethodGet,
+			path:       "/missing.jpg",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "not compressed case",
+			method:     http.MethodGet,
+			path:       "/file.txt",
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"Content-Encoding": "",
+				"Vary":             "Accept-Encoding",
+			},
+			wantBody: text,
+		},
+		{
+			name:   "image isn't compressed case",
+			method: http.MethodGet,
+			path:   "/" + hashedName,
+			header: map[string]string{
+				"Accept-Encoding": "gzip, br",
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: map[string]string{
+				"Content-Encoding": "",
+			},
+			wantBody: "hashed image",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code)
+			for k, v := range tt.wantHeader {
+				require.Equal(t, v, rec.Header().Get(k), k)
+			}
+			if tt.wantBody != "" {
+				require.Equal(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Compression(t *testing.T) {
+	dir := t.TempDir()
+	text := strings.Repeat("compressible text ", 100)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte(text), 0600))
+
+	handler := New(dir, config.StaticConfig{
+		MaxAge:          time.Hour,
+		CompressMinSize: 100,
+		CompressMaxSize: 1 << 20,
+	})
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantEncoding   string
+		decode         func(r io.Reader) (io.Reader, error)
+	}{
+		{
+			name:           "brotli case",
+			acceptEncoding: "gzip, deflate, br",
+			wantEncoding:   "br",
+			decode: func(r io.Reader) (io.Reader, error) {
+				return brotli.NewReader(r), nil
+			},
+		},
+		{
+			name:           "gzip case",
+			acceptEncoding: "gzip, br;q=0",
+			wantEncoding:   "gzip",
+			decode: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, tt.wantEncoding, rec.Header().Get("Content-Encoding"))
+			require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+			require.True(t, strings.HasSuffix(rec.Header().Get("ETag"), "-"+tt.wantEncoding+`"`))
+			require.Less(t, rec.Body.Len(), len(text))
+
+			reader, err := tt.decode(bytes.NewReader(rec.Body.Bytes()))
+			require.NoError(t, err)
+			body, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			require.Equal(t, text, string(body))
+		})
+	}
+}
@@ -3,10 +3,12 @@ package auth_service
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/AlexMickh/shop-backend/internal/dtos"
 	"github.com/AlexMickh/shop-backend/internal/errs"
 	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/logger"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -25,11 +27,16 @@ type SessionService interface {
 	CreateSession(userID uuid.UUID) (string, string, error)
 }
 
+type CartService interface {
+	MergeGuestCart(ctx context.Context, guestId string, userId uuid.UUID) error
+}
+
 type AuthService struct {
 	userService    UserService
 	tokenService   TokenService
 	emailQueue     chan [2]string
 	sessionService SessionService
+	cartService    CartService
 	validator      *validator.Validate
 }
 
@@ -38,6 +45,7 @@ func New(
 	tokenService TokenService,
 	emailQueue chan [2]string,
 	sessionService SessionService,
+	cartService CartService,
 	validator *validator.Validate,
 ) *AuthService {
 	return &AuthService{
@@ -45,6 +53,7 @@ func New(
 		tokenService:   tokenService,
 		emailQueue:     emailQueue,
 		sessionService: sessionService,
+		cartService:    cartService,
 		validator:      validator,
 	}
 }
@@ -93,6 +102,19 @@ func (a *AuthService) Login(ctx context.Context, req dtos.LoginRequest) (string,
 		return "", "", fmt.Errorf("%s: %w", op, errs.ErrUserNotFound)
 	}
 
+	// guest cart is an extra, user can log in even if it isn't merged
+	if req.GuestCartId != "" {
+		err = a.cartService.MergeGuestCart(ctx, req.GuestCartId, user.ID)
+		if err != nil {
+			logger.FromCtx(ctx).Warn(
+				"failed to merge guest cart",
+				slog.String("op", op),
+				slog.String("guest_id", req.GuestCartId),
+				logger.Err(err),
+			)
+		}
+	}
+
 	accessToken, refreshToken, err := a.sessionService.CreateSession(user.ID)
 	if err != nil {
 		return "", "", fmt.Errorf("%s: %w", op, err)
@@ -2,43 +2,87 @@ package cart_service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/AlexMickh/shop-backend/internal/dtos"
 	"github.com/AlexMickh/shop-backend/internal/errs"
 	"github.com/AlexMickh/shop-backend/internal/models"
-	"github.com/AlexMickh/shop-backend/pkg/utils/slice"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/AlexMickh/shop-backend/pkg/utils/retry"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
 type CartRepository interface {
-	AddProduct(ctx context.Context, userId, productId uuid.UUID) (uuid.UUID, error)
-	Cart(ctx context.Context, userId uuid.UUID) ([]*models.CartItem, error)
-	DeleteItem(ctx context.Context, userId, productId uuid.UUID) error
-	Clear(ctx context.Context, userId uuid.UUID) error
+	AddProduct(
+		ctx context.Context,
+		owner models.CartOwner,
+		productId uuid.UUID,
+		size models.ProductSize,
+		quantity int,
+	) (uuid.UUID, error)
+	Cart(ctx context.Context, owner models.CartOwner) ([]*models.CartItem, error)
+	SetQuantity(ctx context.Context, owner models.CartOwner, id uuid.UUID, quantity int) error
+	DeleteItem(ctx context.Context, owner models.CartOwner, id uuid.UUID) error
+	Clear(ctx context.Context, owner models.CartOwner) error
+	UpdateLines(ctx context.Context, owner models.CartOwner, items []*models.CartItem) error
+	SetCoupon(ctx context.Context, owner models.CartOwner, couponId uuid.UUID) error
+	CouponId(ctx context.Context, owner models.CartOwner) (*uuid.UUID, error)
+	DeleteCoupon(ctx context.Context, owner models.CartOwner) error
+	Merge(ctx context.Context, guestId, userId uuid.UUID) error
+	TouchGuest(ctx context.Context, guestId uuid.UUID, now time.Time) error
+	DeleteOldGuestCarts(ctx context.Context, before time.Time) (int64, error)
 }
 
 type UserService interface {
 	CanBuy(ctx context.Context, userId uuid.UUID) error
 }
 
+type OrderService interface {
+	CreateOrder(ctx context.Context, userId uuid.UUID, cart models.Cart) (*models.Order, error)
+	CancelOrder(ctx context.Context, id uuid.UUID) error
+	SetPayment(ctx context.Context, id uuid.UUID, paymentId string) error
+}
+
+type CouponService interface {
+	CouponByCode(ctx context.Context, code string) (*models.Coupon, error)
+	CouponById(ctx context.Context, id uuid.UUID) (*models.Coupon, error)
+	Apply(ctx context.Context, coupon *models.Coupon, userId uuid.UUID, cart *models.Cart) error
+}
+
 type PaymentService interface {
-	CreatePayment(userId uuid.UUID, price float32) (string, error)
+	CreatePayment(ctx context.Context, orderId uuid.UUID, price money.Money) (models.Payment, error)
 }
 
 type CartService struct {
 	cartRepository CartRepository
 	userService    UserService
+	orderService   OrderService
 	paymentService PaymentService
+	couponService  CouponService
 	validator      *validator.Validate
+	guestCartTtl   time.Duration
 }
 
-func New(cartRepository CartRepository /*userService UserService, paymentService PaymentService*/) *CartService {
+func New(
+	cartRepository CartRepository,
+	userService UserService,
+	orderService OrderService,
+	paymentService PaymentService,
+	couponService CouponService,
+	validator *validator.Validate,
+	guestCartTtl time.Duration,
+) *CartService {
 	return &CartService{
 		cartRepository: cartRepository,
-		// userService:    userService,
-		// paymentService: paymentService,
+		userService:    userService,
+		orderService:   orderService,
+		paymentService: paymentService,
+		couponService:  couponService,
+		validator:      validator,
+		guestCartTtl:   guestCartTtl,
 	}
 }
 
@@ -49,7 +93,13 @@ func (c *CartService) AddToCart(ctx context.Context, req dtos.AddToCartRequest)
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
 	}
 
-	id, err := c.cartRepository.AddProduct(ctx, uuid.MustParse(req.UserId), uuid.MustParse(req.ProductId))
+	id, err := c.cartRepository.AddProduct(
+		ctx,
+		req.Owner,
+		uuid.MustParse(req.ProductId),
+		models.ProductSize(req.Size),
+		req.Quantity,
+	)
 	if err != nil {
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
 	}
@@ -57,54 +107,167 @@ func (c *CartService) AddToCart(ctx context.Context, req dtos.AddToCartRequest)
 	return id, nil
 }
 
-func (c *CartService) Cart(ctx context.Context, userId string) (models.Cart, error) {
+// Cart returns cart with current prices and applied coupon. If coupon can't be used anymore
+// cart is returned without its discount and with CouponError set.
+func (c *CartService) Cart(ctx context.Context, owner models.CartOwner) (models.Cart, error) {
 	const op = "services.cart.Cart"
 
-	// if userId < 1 {
-	// 	return models.Cart{}, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
-	// }
-	id, err := uuid.Parse(userId)
+	cart, err := c.cart(ctx, owner)
 	if err != nil {
-		return models.Cart{}, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+		return models.Cart{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	cartItems, err := c.cartRepository.Cart(ctx, id)
+	couponId, err := c.cartRepository.CouponId(ctx, owner)
 	if err != nil {
 		return models.Cart{}, fmt.Errorf("%s: %w", op, err)
 	}
+	if couponId == nil {
+		return cart, nil
+	}
 
-	len := len(cartItems)
-	previousId := uuid.UUID{}
-	var cart models.Cart
+	coupon, err := c.couponService.CouponById(ctx, *couponId)
+	if err != nil {
+		return models.Cart{}, fmt.Errorf("%s: %w", op, err)
+	}
 
-	for i := range len {
-		if cartItems[i].ID == previousId {
-			cartItems[i].Quantity += cartItems[i-1].Quantity
-			slice.RemoveElement(cartItems, i-1)
-			len--
-			i--
+	err = c.couponService.Apply(ctx, coupon, owner.UserID, &cart)
+	if err != nil {
+		couponErr := couponError(err)
+		if couponErr == nil {
+			return models.Cart{}, fmt.Errorf("%s: %w", op, err)
 		}
-		previousId = cartItems[i].ID
-		cart.Price += (cartItems[i].Price - cartItems[i].Price/100*cartItems[i].Discount)
+
+		cart.Coupon = coupon
+		cart.CouponError = couponErr
 	}
 
 	return cart, nil
 }
 
-func (c *CartService) DeleteItem(ctx context.Context, userId, productId string) error {
-	const op = "services.cart.DeleteItem"
+// cart returns cart lines with current prices, without coupon
+func (c *CartService) cart(ctx context.Context, owner models.CartOwner) (models.Cart, error) {
+	const op = "services.cart.cart"
 
-	userUUID, err := uuid.Parse(userId)
+	cartItems, err := c.cartRepository.Cart(ctx, owner)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+		return models.Cart{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+
+	cart := models.Cart{
+		Products: cartItems,
+	}
+	for _, v := range cartItems {
+		v.Discount = models.EffectiveDiscount(v.Discount, v.DiscountStartsAt, v.DiscountExpiresAt, now)
+		if v.Discount == 0 {
+			v.DiscountStartsAt = nil
+			v.DiscountExpiresAt = nil
+		}
+
+		v.PriceChanged = v.UnitPrice() != v.SnapshotUnitPrice()
+		v.StockChanged = v.Quantity > v.Stock
+		if v.PriceChanged || v.StockChanged {
+			cart.HasChanges = true
+		}
+
+		cart.Subtotal = cart.Subtotal.Add(v.UnitPrice().Mul(v.Quantity))
+	}
+	cart.Price = cart.Subtotal
+
+	return cart, nil
+}
+
+// ApplyCoupon applies coupon to the cart if it can be used now, previous coupon is replaced
+func (c *CartService) ApplyCoupon(ctx context.Context, req dtos.ApplyCouponRequest) (models.Cart, error) {
+	const op = "services.cart.ApplyCoupon"
+
+	if err := c.validator.Struct(&req); err != nil {
+		return models.Cart{}, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	coupon, err := c.couponService.CouponByCode(ctx, req.Code)
+	if err != nil {
+		return models.Cart{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	cart, err := c.cart(ctx, req.Owner)
+	if err != nil {
+		return models.Cart{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = c.couponService.Apply(ctx, coupon, req.Owner.UserID, &cart)
+	if err != nil {
+		return models.Cart{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = c.cartRepository.SetCoupon(ctx, req.Owner, coupon.ID)
+	if err != nil {
+		return models.Cart{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return cart, nil
+}
+
+func (c *CartService) RemoveCoupon(ctx context.Context, owner models.CartOwner) error {
+	const op = "services.cart.RemoveCoupon"
+
+	err := c.cartRepository.DeleteCoupon(ctx, owner)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Acknowledge accepts current prices and stock of cart lines, so cart can be bought again
+func (c *CartService) Acknowledge(ctx context.Context, owner models.CartOwner) (models.Cart, error) {
+	const op = "services.cart.Acknowledge"
+
+	cart, err := c.Cart(ctx, owner)
+	if err != nil {
+		return models.Cart{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !cart.HasChanges {
+		return cart, nil
+	}
+
+	changed := make([]*models.CartItem, 0, len(cart.Products))
+	for _, v := range cart.Products {
+		if !v.PriceChanged && !v.StockChanged {
+			continue
+		}
+
+		v.PriceSnapshot = v.Price
+		v.DiscountSnapshot = v.Discount
+		// sold out lines get zero quantity and are deleted
+		v.Quantity = min(v.Quantity, v.Stock)
+
+		changed = append(changed, v)
+	}
+
+	err = c.cartRepository.UpdateLines(ctx, owner, changed)
+	if err != nil {
+		return models.Cart{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	productUUID, err := uuid.Parse(productId)
+	cart, err = c.Cart(ctx, owner)
 	if err != nil {
+		return models.Cart{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return cart, nil
+}
+
+func (c *CartService) UpdateItem(ctx context.Context, req dtos.UpdateCartItemRequest) error {
+	const op = "services.cart.UpdateItem"
+
+	if err := c.validator.Struct(&req); err != nil {
 		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
 	}
 
-	err = c.cartRepository.DeleteItem(ctx, userUUID, productUUID)
+	err := c.cartRepository.SetQuantity(ctx, req.Owner, uuid.MustParse(req.ID), req.Quantity)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -112,15 +275,26 @@ func (c *CartService) DeleteItem(ctx context.Context, userId, productId string)
 	return nil
 }
 
-func (c *CartService) Clear(ctx context.Context, userId string) error {
-	const op = "services.cart.Clear"
+func (c *CartService) DeleteItem(ctx context.Context, owner models.CartOwner, itemId string) error {
+	const op = "services.cart.DeleteItem"
 
-	userUUID, err := uuid.Parse(userId)
+	itemUUID, err := uuid.Parse(itemId)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
 	}
 
-	err = c.cartRepository.Clear(ctx, userUUID)
+	err = c.cartRepository.DeleteItem(ctx, owner, itemUUID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (c *CartService) Clear(ctx context.Context, owner models.CartOwner) error {
+	const op = "services.cart.Clear"
+
+	err := c.cartRepository.Clear(ctx, owner)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -141,17 +315,112 @@ func (c *CartService) Buy(ctx context.Context, userId string) (string, error) {
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	cart, err := c.Cart(ctx, userId)
+	owner := models.CartOwner{UserID: userUUID}
+
+	cart, err := c.Cart(ctx, owner)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// user must see new prices before paying them
+	if cart.HasChanges {
+		return "", fmt.Errorf("%s: %w", op, errs.ErrCartChanged)
+	}
+	if cart.CouponError != nil {
+		return "", fmt.Errorf("%s: %w", op, cart.CouponError)
+	}
+
+	order, err := c.orderService.CreateOrder(ctx, userUUID, cart)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	payment, err := c.paymentService.CreatePayment(ctx, order.ID, order.Price)
 	if err != nil {
+		// nobody can pay for this order anymore, so it must not hang in pending
+		if cancelErr := c.orderService.CancelOrder(ctx, order.ID); cancelErr != nil {
+			return "", fmt.Errorf("%s: %w", op, errors.Join(err, cancelErr))
+		}
+
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	cartPrice := float32(cart.Price) / 100
+	// order isn't cancelled if it fails: payment keeps order id, so it's matched with the order
+	// when provider notifies about it, and not paid order is cancelled by reservation ttl
+	err = retry.WithDelay(3, 100*time.Millisecond, func() error {
+		return c.orderService.SetPayment(ctx, order.ID, payment.ID)
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
 
-	redirectUrl, err := c.paymentService.CreatePayment(userUUID, cartPrice)
+	err = c.cartRepository.Clear(ctx, owner)
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	return redirectUrl, nil
+	return payment.ConfirmationUrl, nil
+}
+
+// TouchGuest remembers that guest has come back, so the guest cart isn't deleted while the cookie is valid
+func (c *CartService) TouchGuest(ctx context.Context, guestId string) error {
+	const op = "services.cart.TouchGuest"
+
+	guestUUID, err := uuid.Parse(guestId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	err = c.cartRepository.TouchGuest(ctx, guestUUID, time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MergeGuestCart moves cart of not logged in visitor to the user cart
+func (c *CartService) MergeGuestCart(ctx context.Context, guestId string, userId uuid.UUID) error {
+	const op = "services.cart.MergeGuestCart"
+
+	guestUUID, err := uuid.Parse(guestId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	err = c.cartRepository.Merge(ctx, guestUUID, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteOldGuestCarts deletes carts of guests who haven't visited for guestCartTtl,
+// visits are saved by TouchGuest like cookie is prolonged
+func (c *CartService) DeleteOldGuestCarts(ctx context.Context) (int64, error) {
+	const op = "services.cart.DeleteOldGuestCarts"
+
+	count, err := c.cartRepository.DeleteOldGuestCarts(ctx, time.Now().Add(-c.guestCartTtl))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// couponError returns reason why coupon can't be applied, nil if err isn't about coupon
+func couponError(err error) error {
+	for _, couponErr := range []error{
+		errs.ErrCouponNotActive,
+		errs.ErrCouponNotApplicable,
+		errs.ErrCouponMinOrderPrice,
+		errs.ErrCouponUsageLimit,
+	} {
+		if errors.Is(err, couponErr) {
+			return couponErr
+		}
+	}
+
+	return nil
 }
@@ -1,62 +1,232 @@
 package category_service
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 
 	"github.com/AlexMickh/shop-backend/internal/dtos"
 	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/lib/images"
 	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/slug"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
 type CategoryRepository interface {
 	SaveCategory(ctx context.Context, category models.Category) (uuid.UUID, error)
-	DeleteCategory(ctx context.Context, id uuid.UUID) error
+	DeleteCategory(ctx context.Context, id, moveTo uuid.UUID) error
 	AllCategories(ctx context.Context) ([]models.Category, error)
+	CategoryById(ctx context.Context, id uuid.UUID) (*models.Category, error)
+	UpdateCategory(ctx context.Context, category *models.Category) error
+}
+
+type FileStorage interface {
+	SaveImage(ctx context.Context, image []byte) (string, error)
+}
+
+// Files deletes image files, file is kept while other image with the same content uses it
+type Files interface {
+	DeleteUnused(ctx context.Context, urls ...string) error
+}
+
+// ImageProcessor checks uploaded image and makes its sizes
+type ImageProcessor interface {
+	Process(data []byte) ([]images.Image, error)
+}
+
+// Suggestions is index of category names for search box, it's updated with categories
+type Suggestions interface {
+	PutCategory(id uuid.UUID, name string)
+	DeleteCategory(id uuid.UUID)
 }
 
 type CategoryService struct {
 	categoryRepository CategoryRepository
+	fileStorage        FileStorage
+	imageProcessor     ImageProcessor
+	files              Files
+	suggestions        Suggestions
 	validator          *validator.Validate
 }
 
-func New(categoryRepository CategoryRepository, validator *validator.Validate) *CategoryService {
+func New(
+	categoryRepository CategoryRepository,
+	fileStorage FileStorage,
+	imageProcessor ImageProcessor,
+	files Files,
+	suggestions Suggestions,
+	validator *validator.Validate,
+) *CategoryService {
 	return &CategoryService{
 		categoryRepository: categoryRepository,
+		fileStorage:        fileStorage,
+		imageProcessor:     imageProcessor,
+		files:              files,
+		suggestions:        suggestions,
 		validator:          validator,
 	}
 }
 
-func (c *CategoryService) CreateCategory(ctx context.Context, req dtos.CreateCategoryRequest) (uuid.UUID, error) {
+func (c *CategoryService) CreateCategory(ctx context.Context, req dtos.CreateCategoryRequest) (*models.Category, error) {
 	const op = "services.category.CreateCategory"
 
 	if err := c.validator.Struct(&req); err != nil {
-		return uuid.UUID{}, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
 	}
 
-	category := models.Category{
-		Name: req.Name,
+	category := &models.Category{
+		Name:     req.Name,
+		Slug:     req.Slug,
+		Position: req.Position,
+	}
+	if category.Slug == "" {
+		category.Slug = slug.Make(req.Name)
+	}
+	if !slug.Valid(category.Slug) {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
 	}
 
-	id, err := c.categoryRepository.SaveCategory(ctx, category)
+	if req.ParentID != "" {
+		parentId := uuid.MustParse(req.ParentID)
+		category.ParentID = &parentId
+	}
+
+	id, err := c.categoryRepository.SaveCategory(ctx, *category)
 	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	category.ID = id
 
-	return id, nil
+	c.suggestions.PutCategory(id, category.Name)
+
+	return category, nil
 }
 
-func (c *CategoryService) DeleteCategory(ctx context.Context, id string) error {
+// CategoryById returns category with its children, both have count of products including subcategories
+func (c *CategoryService) CategoryById(ctx context.Context, id string) (*models.Category, error) {
+	const op = "services.category.CategoryById"
+
+	categoryId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	category, err := c.categoryRepository.CategoryById(ctx, categoryId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return category, nil
+}
+
+func (c *CategoryService) UpdateCategory(ctx context.Context, req *dtos.UpdateCategoryRequest) error {
+	const op = "services.category.UpdateCategory"
+
+	if err := c.validator.Struct(req); err != nil {
+		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	if req.Slug != "" && !slug.Valid(req.Slug) {
+		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	category := &models.Category{
+		ID:          uuid.MustParse(req.ID),
+		Name:        req.Name,
+		Slug:        req.Slug,
+		Description: req.Description,
+		Position:    -1,
+	}
+	if req.Position != nil {
+		category.Position = *req.Position
+	}
+
+	// old image is deleted after new one is saved
+	var oldImageUrl string
+	if req.Image != nil {
+		old, err := c.categoryRepository.CategoryById(ctx, category.ID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		oldImageUrl = old.ImageUrl
+
+		buf := bytes.NewBuffer(nil)
+
+		if _, err := io.Copy(buf, req.Image); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		sizes, err := c.imageProcessor.Process(buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// category image is shown as a tile, so only medium size is kept
+		for _, v := range sizes {
+			if v.Size != images.SizeMedium {
+				continue
+			}
+
+			category.ImageUrl, err = c.fileStorage.SaveImage(ctx, v.Data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	err := c.categoryRepository.UpdateCategory(ctx, category)
+	if err != nil {
+		_ = c.files.DeleteUnused(ctx, category.ImageUrl)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if category.Name != "" {
+		c.suggestions.PutCategory(category.ID, category.Name)
+	}
+
+	if oldImageUrl != "" && oldImageUrl != category.ImageUrl {
+		if err = c.files.DeleteUnused(ctx, oldImageUrl); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteCategory deletes category without subcategories, its products are moved to moveTo category.
+// If moveTo is empty, category with products isn't deleted.
+func (c *CategoryService) DeleteCategory(ctx context.Context, id, moveTo string) error {
 	const op = "services.category.DeleteCategory"
 
-	userId, err := uuid.Parse(id)
+	categoryId, err := uuid.Parse(id)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
 	}
 
-	err = c.categoryRepository.DeleteCategory(ctx, userId)
+	var moveToId uuid.UUID
+	if moveTo != "" {
+		moveToId, err = uuid.Parse(moveTo)
+		if err != nil || moveToId == categoryId {
+			return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+		}
+	}
+
+	category, err := c.categoryRepository.CategoryById(ctx, categoryId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = c.categoryRepository.DeleteCategory(ctx, categoryId, moveToId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	c.suggestions.DeleteCategory(categoryId)
+
+	err = c.files.DeleteUnused(ctx, category.ImageUrl)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -64,13 +234,39 @@ func (c *CategoryService) DeleteCategory(ctx context.Context, id string) error {
 	return nil
 }
 
-func (c *CategoryService) AllCategories(ctx context.Context) ([]models.Category, error) {
-	const op = "services.category.AllCategories"
+// CategoryTree returns top level categories with their subcategories
+func (c *CategoryService) CategoryTree(ctx context.Context) ([]models.Category, error) {
+	const op = "services.category.CategoryTree"
 
 	categories, err := c.categoryRepository.AllCategories(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return categories, nil
+	return buildTree(categories), nil
+}
+
+// buildTree puts categories to children of their parents keeping their order
+func buildTree(categories []models.Category) []models.Category {
+	roots := make([]models.Category, 0)
+	children := make(map[uuid.UUID][]models.Category)
+
+	for _, v := range categories {
+		if v.ParentID == nil {
+			roots = append(roots, v)
+			continue
+		}
+
+		children[*v.ParentID] = append(children[*v.ParentID], v)
+	}
+
+	return withChildren(roots, children)
+}
+
+func withChildren(categories []models.Category, children map[uuid.UUID][]models.Category) []models.Category {
+	for i := range categories {
+		categories[i].Children = withChildren(children[categories[i].ID], children)
+	}
+
+	return categories
 }
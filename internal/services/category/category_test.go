@@ -0,0 +1,41 @@
+package category_service
+
+import (
+	"testing"
+
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildTree(t *testing.T) {
+	men := uuid.New()
+	outerwear := uuid.New()
+	jackets := uuid.New()
+	coats := uuid.New()
+	women := uuid.New()
+
+	// ordered by position like repository returns them
+	categories := []models.Category{
+		{ID: men, Name: "Men"},
+		{ID: jackets, ParentID: &outerwear, Name: "Jackets"},
+		{ID: outerwear, ParentID: &men, Name: "Outerwear"},
+		{ID: women, Name: "Women", Position: 1},
+		{ID: coats, ParentID: &outerwear, Name: "Coats", Position: 1},
+	}
+
+	tree := buildTree(categories)
+
+	require.Len(t, tree, 2)
+	require.Equal(t, men, tree[0].ID)
+	require.Equal(t, women, tree[1].ID)
+	require.Empty(t, tree[1].Children)
+
+	require.Len(t, tree[0].Children, 1)
+	require.Equal(t, outerwear, tree[0].Children[0].ID)
+
+	subcategories := tree[0].Children[0].Children
+	require.Len(t, subcategories, 2)
+	require.Equal(t, jackets, subcategories[0].ID)
+	require.Equal(t, coats, subcategories[1].ID)
+}
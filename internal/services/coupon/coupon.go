@@ -0,0 +1,275 @@
+package coupon_service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/dtos"
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type CouponRepository interface {
+	SaveCoupon(ctx context.Context, coupon *models.Coupon) error
+	CouponById(ctx context.Context, id uuid.UUID) (*models.Coupon, error)
+	CouponByCode(ctx context.Context, code string) (*models.Coupon, error)
+	Coupons(ctx context.Context) ([]models.Coupon, error)
+	UpdateCoupon(ctx context.Context, coupon *models.Coupon) error
+	DeleteCoupon(ctx context.Context, id uuid.UUID) error
+	CouponUsage(ctx context.Context, id, userId uuid.UUID) (int, int, error)
+}
+
+type CouponService struct {
+	couponRepository CouponRepository
+	validator        *validator.Validate
+}
+
+func New(couponRepository CouponRepository, validator *validator.Validate) *CouponService {
+	return &CouponService{
+		couponRepository: couponRepository,
+		validator:        validator,
+	}
+}
+
+func (c *CouponService) CreateCoupon(ctx context.Context, req dtos.CreateCouponRequest) (*models.Coupon, error) {
+	const op = "services.coupon.CreateCoupon"
+
+	if err := c.validator.Struct(&req); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	coupon := &models.Coupon{
+		Code:              strings.ToUpper(req.Code),
+		Type:              models.CouponType(req.Type),
+		Value:             req.Value,
+		MinOrderPrice:     money.Rub(int64(req.MinOrderPrice)),
+		ProductIDs:        parseIds(req.ProductIDs),
+		CategoryIDs:       parseIds(req.CategoryIDs),
+		UsageLimit:        req.UsageLimit,
+		UsageLimitPerUser: req.UsageLimitPerUser,
+		StartsAt:          req.StartsAt,
+		ExpiresAt:         req.ExpiresAt,
+	}
+
+	if err := validateCoupon(coupon); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err := c.couponRepository.SaveCoupon(ctx, coupon)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return coupon, nil
+}
+
+func (c *CouponService) Coupons(ctx context.Context) ([]models.Coupon, error) {
+	const op = "services.coupon.Coupons"
+
+	coupons, err := c.couponRepository.Coupons(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return coupons, nil
+}
+
+func (c *CouponService) UpdateCoupon(ctx context.Context, req dtos.UpdateCouponRequest) (*models.Coupon, error) {
+	const op = "services.coupon.UpdateCoupon"
+
+	if err := c.validator.Struct(&req); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	coupon, err := c.couponRepository.CouponById(ctx, uuid.MustParse(req.ID))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if req.Code != nil {
+		coupon.Code = strings.ToUpper(*req.Code)
+	}
+	if req.Type != nil {
+		coupon.Type = models.CouponType(*req.Type)
+	}
+	if req.Value != nil {
+		coupon.Value = *req.Value
+	}
+	if req.MinOrderPrice != nil {
+		coupon.MinOrderPrice = money.Rub(int64(*req.MinOrderPrice))
+	}
+	if req.ProductIDs != nil {
+		coupon.ProductIDs = parseIds(*req.ProductIDs)
+	}
+	if req.CategoryIDs != nil {
+		coupon.CategoryIDs = parseIds(*req.CategoryIDs)
+	}
+	if req.UsageLimit != nil {
+		coupon.UsageLimit = req.UsageLimit
+	}
+	if req.UsageLimitPerUser != nil {
+		coupon.UsageLimitPerUser = req.UsageLimitPerUser
+	}
+	if req.StartsAt != nil {
+		coupon.StartsAt = req.StartsAt
+	}
+	if req.ExpiresAt != nil {
+		coupon.ExpiresAt = req.ExpiresAt
+	}
+
+	if err = validateCoupon(coupon); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = c.couponRepository.UpdateCoupon(ctx, coupon)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return coupon, nil
+}
+
+func (c *CouponService) DeleteCoupon(ctx context.Context, id string) error {
+	const op = "services.coupon.DeleteCoupon"
+
+	couponId, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	err = c.couponRepository.DeleteCoupon(ctx, couponId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CouponByCode finds coupon by code entered by user, code is case insensitive
+func (c *CouponService) CouponByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	const op = "services.coupon.CouponByCode"
+
+	coupon, err := c.couponRepository.CouponByCode(ctx, strings.ToUpper(code))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return coupon, nil
+}
+
+func (c *CouponService) CouponById(ctx context.Context, id uuid.UUID) (*models.Coupon, error) {
+	const op = "services.coupon.CouponById"
+
+	coupon, err := c.couponRepository.CouponById(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return coupon, nil
+}
+
+// Apply checks that coupon can be used for the cart and subtracts its discount from cart price.
+// Per user limit is checked only if userId is set, guests are checked when they buy.
+func (c *CouponService) Apply(ctx context.Context, coupon *models.Coupon, userId uuid.UUID, cart *models.Cart) error {
+	const op = "services.coupon.Apply"
+
+	if coupon.UsageLimit != nil || (coupon.UsageLimitPerUser != nil && userId != uuid.Nil) {
+		total, byUser, err := c.couponRepository.CouponUsage(ctx, coupon.ID, userId)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if coupon.UsageLimit != nil && total >= *coupon.UsageLimit {
+			return fmt.Errorf("%s: %w", op, errs.ErrCouponUsageLimit)
+		}
+		if coupon.UsageLimitPerUser != nil && userId != uuid.Nil && byUser >= *coupon.UsageLimitPerUser {
+			return fmt.Errorf("%s: %w", op, errs.ErrCouponUsageLimit)
+		}
+	}
+
+	if err := applyCoupon(coupon, cart, time.Now()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// applyCoupon splits coupon discount between covered cart lines proportionally to their price,
+// the last covered line gets what is left after rounding
+func applyCoupon(coupon *models.Coupon, cart *models.Cart, now time.Time) error {
+	if !coupon.IsActive(now) {
+		return errs.ErrCouponNotActive
+	}
+
+	if cart.Subtotal.Less(coupon.MinOrderPrice) {
+		return errs.ErrCouponMinOrderPrice
+	}
+
+	covered := make([]*models.CartItem, 0, len(cart.Products))
+	var coveredPrice money.Money
+	for _, v := range cart.Products {
+		if coupon.Covers(v) {
+			covered = append(covered, v)
+			coveredPrice = coveredPrice.Add(v.UnitPrice().Mul(v.Quantity))
+		}
+	}
+
+	if !coveredPrice.IsPositive() {
+		return errs.ErrCouponNotApplicable
+	}
+
+	var discount money.Money
+	switch coupon.Type {
+	case models.CouponTypePercent:
+		discount = coveredPrice.Sub(coveredPrice.ApplyDiscount(coupon.Value))
+	case models.CouponTypeFixed:
+		discount = money.Rub(int64(coupon.Value))
+		if coveredPrice.Less(discount) {
+			discount = coveredPrice
+		}
+	}
+
+	left := discount
+	for i, v := range covered {
+		share := left
+		if i != len(covered)-1 {
+			share.Amount = discount.Amount * v.UnitPrice().Mul(v.Quantity).Amount / coveredPrice.Amount
+		}
+
+		v.CouponDiscount = share
+		left = left.Sub(share)
+	}
+
+	cart.Coupon = coupon
+	cart.CouponDiscount = discount
+	cart.Price = cart.Subtotal.Sub(discount)
+
+	return nil
+}
+
+func validateCoupon(coupon *models.Coupon) error {
+	if coupon.Type == models.CouponTypePercent && coupon.Value > 100 {
+		return errs.ErrInvalidRequest
+	}
+
+	if coupon.StartsAt != nil && coupon.ExpiresAt != nil && !coupon.ExpiresAt.After(*coupon.StartsAt) {
+		return errs.ErrInvalidRequest
+	}
+
+	return nil
+}
+
+// parseIds parses ids already checked by validator
+func parseIds(ids []string) []uuid.UUID {
+	res := make([]uuid.UUID, 0, len(ids))
+	for _, v := range ids {
+		res = append(res, uuid.MustParse(v))
+	}
+
+	return res
+}
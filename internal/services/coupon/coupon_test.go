@@ -0,0 +1,103 @@
+package coupon_service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyCoupon(t *testing.T) {
+	now := time.Now()
+	yesterday := now.Add(-24 * time.Hour)
+	shirtsCategory := uuid.New()
+	jeansId := uuid.New()
+
+	newCart := func() *models.Cart {
+		cart := &models.Cart{
+			Products: []*models.CartItem{
+				{ProductID: uuid.New(), CategoryID: shirtsCategory, Price: money.Rub(10000), Quantity: 2},
+				{ProductID: uuid.New(), CategoryID: shirtsCategory, Price: money.Rub(3333), Discount: 10, Quantity: 1},
+				{ProductID: jeansId, CategoryID: uuid.New(), Price: money.Rub(50000), Quantity: 1},
+			},
+		}
+		for _, v := range cart.Products {
+			cart.Subtotal = cart.Subtotal.Add(v.UnitPrice().Mul(v.Quantity))
+		}
+		cart.Price = cart.Subtotal
+
+		return cart
+	}
+
+	tests := []struct {
+		name      string
+		coupon    models.Coupon
+		want      money.Money
+		wantLines []money.Money
+		wantErr   error
+	}{
+		{
+			name:      "percent case",
+			coupon:    models.Coupon{Type: models.CouponTypePercent, Value: 10},
+			want:      money.Rub(7300),
+			wantLines: []money.Money{money.Rub(2000), money.Rub(300), money.Rub(5000)},
+		},
+		{
+			name:      "fixed case",
+			coupon:    models.Coupon{Type: models.CouponTypeFixed, Value: 1000},
+			want:      money.Rub(1000),
+			wantLines: []money.Money{money.Rub(273), money.Rub(41), money.Rub(686)},
+		},
+		{
+			name:      "fixed greater than covered price case",
+			coupon:    models.Coupon{Type: models.CouponTypeFixed, Value: 100000, CategoryIDs: []uuid.UUID{shirtsCategory}},
+			want:      money.Rub(23000),
+			wantLines: []money.Money{money.Rub(20000), money.Rub(3000), {}},
+		},
+		{
+			name:      "product scope case",
+			coupon:    models.Coupon{Type: models.CouponTypePercent, Value: 50, ProductIDs: []uuid.UUID{jeansId}},
+			want:      money.Rub(25000),
+			wantLines: []money.Money{{}, {}, money.Rub(25000)},
+		},
+		{
+			name:    "not applicable case",
+			coupon:  models.Coupon{Type: models.CouponTypePercent, Value: 10, ProductIDs: []uuid.UUID{uuid.New()}},
+			wantErr: errs.ErrCouponNotApplicable,
+		},
+		{
+			name:    "min order price case",
+			coupon:  models.Coupon{Type: models.CouponTypePercent, Value: 10, MinOrderPrice: money.Rub(100000)},
+			wantErr: errs.ErrCouponMinOrderPrice,
+		},
+		{
+			name:    "expired case",
+			coupon:  models.Coupon{Type: models.CouponTypePercent, Value: 10, ExpiresAt: &yesterday},
+			wantErr: errs.ErrCouponNotActive,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cart := newCart()
+
+			err := applyCoupon(&tt.coupon, cart, now)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				require.Nil(t, cart.Coupon)
+				require.Equal(t, cart.Subtotal, cart.Price)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, cart.CouponDiscount)
+			require.Equal(t, cart.Subtotal.Sub(tt.want), cart.Price)
+			for i, v := range cart.Products {
+				require.Equal(t, tt.wantLines[i].Amount, v.CouponDiscount.Amount)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package files_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+)
+
+// deleteMinAge is like minAge of CollectGarbage for DeleteUnused. The same content can be uploaded
+// again between reference check and deletion, upload touches the file, so it isn't deleted.
+const deleteMinAge = 10 * time.Minute
+
+type FileRepository interface {
+	ImageUrls(ctx context.Context) ([]string, error)
+	ImagesInUse(ctx context.Context, urls []string) ([]string, error)
+}
+
+type FileStorage interface {
+	DeleteImage(ctx context.Context, url string) error
+	File(ctx context.Context, url string) (models.StoredFile, error)
+	Files(ctx context.Context) ([]models.StoredFile, error)
+}
+
+// FileService deletes files of images. Files are named by content, so one file can be shared
+// by several images and it's deleted only when no image references it.
+type FileService struct {
+	fileRepository FileRepository
+	fileStorage    FileStorage
+}
+
+func New(fileRepository FileRepository, fileStorage FileStorage) *FileService {
+	return &FileService{
+		fileRepository: fileRepository,
+		fileStorage:    fileStorage,
+	}
+}
+
+// DeleteUnused removes files of urls which aren't referenced anymore, it's called after images are deleted.
+// Files modified later than deleteMinAge are kept, CollectGarbage deletes them if they stay unused.
+func (f *FileService) DeleteUnused(ctx context.Context, urls ...string) error {
+	const op = "services.files.DeleteUnused"
+
+	urls = slices.DeleteFunc(slices.Compact(slices.Sorted(slices.Values(urls))), func(url string) bool {
+		return url == ""
+	})
+	if len(urls) == 0 {
+		return nil
+	}
+
+	used, err := f.fileRepository.ImagesInUse(ctx, urls)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// every file is tried even if some fail
+	var deleteErrs []error
+	for _, url := range urls {
+		if slices.Contains(used, url) {
+			continue
+		}
+
+		// file is checked right before deletion, so upload after it is the only one which isn't seen
+		file, err := f.fileStorage.File(ctx, url)
+		if errors.Is(err, errs.ErrFileNotFound) {
+			continue
+		}
+		if err != nil {
+			deleteErrs = append(deleteErrs, err)
+			continue
+		}
+		if time.Since(file.ModifiedAt) < deleteMinAge {
+			continue
+		}
+
+		if err = f.fileStorage.DeleteImage(ctx, url); err != nil {
+			deleteErrs = append(deleteErrs, err)
+		}
+	}
+	if err = errors.Join(deleteErrs...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CollectGarbage deletes files which aren't referenced by any image, they're left when upload or
+// deletion fails halfway. Files newer than minAge are kept, so image being uploaded now isn't deleted
+// before it's saved to database. If dryRun is set, files are only returned.
+func (f *FileService) CollectGarbage(ctx context.Context, minAge time.Duration, dryRun bool) ([]models.StoredFile, error) {
+	const op = "services.files.CollectGarbage"
+
+	// files are listed before references, so file uploaded in between is either new or referenced
+	files, err := f.fileStorage.Files(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	urls, err := f.fileRepository.ImageUrls(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// urls are compared by file name, so files are kept if server address in urls changes
+	used := make(map[string]struct{}, len(urls))
+	for _, url := range urls {
+		used[path.Base(url)] = struct{}{}
+	}
+
+	deleted := make([]models.StoredFile, 0)
+	for _, v := range files {
+		if _, ok := used[v.Name]; ok || time.Since(v.ModifiedAt) < minAge {
+			continue
+		}
+
+		if !dryRun {
+			if err = f.fileStorage.DeleteImage(ctx, v.Url); err != nil {
+				return deleted, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		deleted = append(deleted, v)
+	}
+
+	return deleted, nil
+}
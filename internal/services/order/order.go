@@ -0,0 +1,617 @@
+package order_service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/dtos"
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type OrderRepository interface {
+	SaveOrder(ctx context.Context, order *models.Order) error
+	OrderById(ctx context.Context, id uuid.UUID) (*models.Order, error)
+	Orders(ctx context.Context, userId uuid.UUID) ([]models.Order, error)
+	UpdateStatus(ctx context.Context, id uuid.UUID, from, to models.OrderStatus) error
+	CancelExpired(ctx context.Context, now time.Time) (int64, error)
+	SetPaymentId(ctx context.Context, id uuid.UUID, paymentId string) error
+	SaveRefund(ctx context.Context, refund *models.OrderRefund) error
+	CompleteRefund(ctx context.Context, refund *models.OrderRefund) error
+	SavePaymentRefundId(ctx context.Context, id uuid.UUID, paymentRefundId string) error
+	CancelRefund(ctx context.Context, id uuid.UUID) error
+	PendingRefunds(ctx context.Context, before time.Time) ([]models.OrderRefund, error)
+	Refunds(ctx context.Context, orderId uuid.UUID) ([]models.OrderRefund, error)
+}
+
+type StockService interface {
+	ReserveStock(ctx context.Context, order *models.Order) error
+	ReleaseStock(ctx context.Context, orderId uuid.UUID) error
+	CommitStock(ctx context.Context, orderId uuid.UUID) error
+}
+
+type PaymentService interface {
+	Payment(ctx context.Context, id string) (models.Payment, error)
+	Capture(ctx context.Context, id string) (models.Payment, error)
+	Cancel(ctx context.Context, id string) (models.Payment, error)
+	Refund(ctx context.Context, refundId uuid.UUID, paymentId string, price money.Money) (models.Refund, error)
+	RefundById(ctx context.Context, id string) (models.Refund, error)
+}
+
+type OrderService struct {
+	orderRepository OrderRepository
+	stockService    StockService
+	paymentService  PaymentService
+	validator       *validator.Validate
+	// pending refund is sent again if provider hasn't answered for this time
+	refundRetryDelay time.Duration
+}
+
+func New(
+	orderRepository OrderRepository,
+	stockService StockService,
+	paymentService PaymentService,
+	validator *validator.Validate,
+	refundRetryDelay time.Duration,
+) *OrderService {
+	return &OrderService{
+		orderRepository:  orderRepository,
+		stockService:     stockService,
+		paymentService:   paymentService,
+		validator:        validator,
+		refundRetryDelay: refundRetryDelay,
+	}
+}
+
+// CreateOrder snapshots cart items into new order waiting for payment
+// and reserves stock for it until it's paid or cancelled.
+// Coupon usage is counted by the order until it's cancelled.
+func (o *OrderService) CreateOrder(ctx context.Context, userId uuid.UUID, cart models.Cart) (*models.Order, error) {
+	const op = "services.order.CreateOrder"
+
+	if len(cart.Products) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrCartEmpty)
+	}
+
+	order := &models.Order{
+		UserID:         userId,
+		Status:         models.OrderStatusPendingPayment,
+		Price:          cart.Price,
+		CouponDiscount: cart.CouponDiscount,
+		Items:          make([]models.OrderItem, 0, len(cart.Products)),
+	}
+	if cart.Coupon != nil && cart.CouponError == nil {
+		order.CouponID = &cart.Coupon.ID
+	}
+
+	for _, v := range cart.Products {
+		order.Items = append(order.Items, models.OrderItem{
+			ProductID:      v.ProductID,
+			VariantID:      &v.VariantID,
+			Name:           v.Name,
+			Price:          v.Price,
+			Discount:       v.Discount,
+			Size:           &v.Size,
+			Quantity:       v.Quantity,
+			CouponDiscount: v.CouponDiscount,
+		})
+	}
+
+	err := o.orderRepository.SaveOrder(ctx, order)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = o.stockService.ReserveStock(ctx, order)
+	if err != nil {
+		cancelErr := o.orderRepository.UpdateStatus(ctx, order.ID, order.Status, models.OrderStatusCancelled)
+		return nil, fmt.Errorf("%s: %w", op, errors.Join(err, cancelErr))
+	}
+
+	return order, nil
+}
+
+func (o *OrderService) Orders(ctx context.Context, userId string) ([]models.Order, error) {
+	const op = "services.order.Orders"
+
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	orders, err := o.orderRepository.Orders(ctx, userUUID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return orders, nil
+}
+
+func (o *OrderService) OrderById(ctx context.Context, id string) (*models.Order, error) {
+	const op = "services.order.OrderById"
+
+	orderId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	order, err := o.orderRepository.OrderById(ctx, orderId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return order, nil
+}
+
+// UserOrderById returns order only if it belongs to user
+func (o *OrderService) UserOrderById(ctx context.Context, userId, id string) (*models.Order, error) {
+	const op = "services.order.UserOrderById"
+
+	order, err := o.OrderById(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if order.UserID.String() != userId {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrOrderNotFound)
+	}
+
+	return order, nil
+}
+
+// Cancel cancels users order, user can cancel only orders that are not paid yet
+func (o *OrderService) Cancel(ctx context.Context, userId, id string) error {
+	const op = "services.order.Cancel"
+
+	order, err := o.UserOrderById(ctx, userId, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if order.Status != models.OrderStatusPendingPayment {
+		return fmt.Errorf("%s: %w", op, errs.ErrOrderStatusTransition)
+	}
+
+	err = o.CancelOrder(ctx, order.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CancelOrder cancels order waiting for payment and releases its stock
+func (o *OrderService) CancelOrder(ctx context.Context, id uuid.UUID) error {
+	const op = "services.order.CancelOrder"
+
+	err := o.orderRepository.UpdateStatus(ctx, id, models.OrderStatusPendingPayment, models.OrderStatusCancelled)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// if it fails, reservation is released later by ttl
+	err = o.stockService.ReleaseStock(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CancelExpiredOrders cancels orders which weren't paid while their stock was reserved.
+// Payment made after it isn't captured, so money isn't taken for cancelled order.
+func (o *OrderService) CancelExpiredOrders(ctx context.Context) (int64, error) {
+	const op = "services.order.CancelExpiredOrders"
+
+	count, err := o.orderRepository.CancelExpired(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// markPaid writes off reserved stock and moves order to paid status.
+// Stock goes first, it can be committed several times, so a failed call can be repeated.
+// ErrReservationExpired is returned if order was cancelled and its stock was returned.
+func (o *OrderService) markPaid(ctx context.Context, id uuid.UUID) error {
+	const op = "services.order.markPaid"
+
+	err := o.stockService.CommitStock(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = o.orderRepository.UpdateStatus(ctx, id, models.OrderStatusPendingPayment, models.OrderStatusPaid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (o *OrderService) SetPayment(ctx context.Context, id uuid.UUID, paymentId string) error {
+	const op = "services.order.SetPayment"
+
+	err := o.orderRepository.SetPaymentId(ctx, id, paymentId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ProcessPayment syncs order with the payment state. Payment is always fetched from
+// the provider, so forged notification can't mark order as paid.
+// Money held by the payment is captured only while order waits for payment, otherwise payment is canceled.
+// Payment with price other than order price isn't accepted, ErrPaymentPriceMismatch is returned.
+// It's safe to call it several times for the same payment.
+func (o *OrderService) ProcessPayment(ctx context.Context, paymentId string) error {
+	const op = "services.order.ProcessPayment"
+
+	payment, err := o.paymentService.Payment(ctx, paymentId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	order, err := o.orderRepository.OrderById(ctx, payment.OrderID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// payment id wasn't saved by checkout, but order id of the payment comes from the provider
+	if order.PaymentID == nil {
+		err = o.SetPayment(ctx, order.ID, payment.ID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		order.PaymentID = &payment.ID
+	}
+
+	if *order.PaymentID != payment.ID {
+		return fmt.Errorf("%s: %w", op, errs.ErrPaymentNotFound)
+	}
+
+	if payment.Status == models.PaymentStatusWaitingForCapture {
+		if order.Status != models.OrderStatusPendingPayment {
+			_, err = o.paymentService.Cancel(ctx, payment.ID)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			return nil
+		}
+
+		// money isn't taken, so held payment is canceled and buyer can pay again
+		if payment.Price != order.Price {
+			_, err = o.paymentService.Cancel(ctx, payment.ID)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			return fmt.Errorf("%s: %w: paid %s instead of %s", op, errs.ErrPaymentPriceMismatch, payment.Price, order.Price)
+		}
+
+		payment, err = o.paymentService.Capture(ctx, payment.ID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if order.Status == models.OrderStatusCancelled && payment.Status == models.PaymentStatusSucceeded {
+		err = o.refundCancelled(ctx, payment)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		return nil
+	}
+
+	if order.Status != models.OrderStatusPendingPayment {
+		return nil
+	}
+
+	switch payment.Status {
+	case models.PaymentStatusSucceeded:
+		// payment was captured not by us, it can't pay the order, so money is given back
+		if payment.Price != order.Price {
+			err = o.refundCancelled(ctx, payment)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
+			return fmt.Errorf("%s: %w: paid %s instead of %s", op, errs.ErrPaymentPriceMismatch, payment.Price, order.Price)
+		}
+
+		err = o.markPaid(ctx, order.ID)
+		// order is cancelled by reservation ttl after money was captured
+		if errors.Is(err, errs.ErrReservationExpired) {
+			err = o.refundCancelled(ctx, payment)
+		}
+	case models.PaymentStatusCanceled:
+		err = o.CancelOrder(ctx, order.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// refundCancelled gives back money of payment which succeeded for cancelled order or with wrong price.
+// Idempotence key is made from order id, so repeated notifications refund it only once.
+func (o *OrderService) refundCancelled(ctx context.Context, payment models.Payment) error {
+	const op = "services.order.refundCancelled"
+
+	refundId := uuid.NewSHA1(payment.OrderID, []byte("cancelled order refund"))
+
+	refund, err := o.paymentService.Refund(ctx, refundId, payment.ID, payment.Price)
+	if err == nil && refund.Status == models.RefundStatusCanceled {
+		err = errs.ErrRefundPayment
+	}
+	if err != nil {
+		return fmt.Errorf("%s: order %s is cancelled, but paid: %w", op, payment.OrderID, err)
+	}
+
+	return nil
+}
+
+func (o *OrderService) UpdateStatus(ctx context.Context, req dtos.UpdateOrderStatusRequest) error {
+	const op = "services.order.UpdateStatus"
+
+	if err := o.validator.Struct(&req); err != nil {
+		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	order, err := o.OrderById(ctx, req.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	status := models.OrderStatus(req.Status)
+	if !order.Status.CanTransitionTo(status) {
+		return fmt.Errorf("%s: %w", op, errs.ErrOrderStatusTransition)
+	}
+
+	switch {
+	case status == models.OrderStatusPaid:
+		err = o.markPaid(ctx, order.ID)
+	case order.Status == models.OrderStatusPendingPayment && status == models.OrderStatusCancelled:
+		err = o.CancelOrder(ctx, order.ID)
+	default:
+		err = o.orderRepository.UpdateStatus(ctx, order.ID, order.Status, status)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Refund gives money back for the whole order or for some of its items.
+// Refund is saved before calling the provider, its id is used as idempotence key,
+// so retry after a failure can't refund the same items twice.
+// If provider doesn't give a definite answer, refund is returned in pending status and sent again by RetryRefunds.
+func (o *OrderService) Refund(ctx context.Context, req dtos.RefundOrderRequest) (*models.OrderRefund, error) {
+	const op = "services.order.Refund"
+
+	if err := o.validator.Struct(&req); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	order, err := o.OrderById(ctx, req.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if order.PaymentID == nil || !order.Status.CanTransitionTo(models.OrderStatusRefunded) {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrOrderStatusTransition)
+	}
+
+	items, err := refundItems(order, req.Items)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	refund := &models.OrderRefund{
+		OrderID: order.ID,
+		Status:  models.RefundStatusPending,
+		Reason:  req.Reason,
+		Items:   items,
+	}
+	for _, v := range items {
+		refund.Price = refund.Price.Add(v.Price)
+	}
+
+	err = o.orderRepository.SaveRefund(ctx, refund)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = o.sendRefund(ctx, refund, *order.PaymentID)
+	if err != nil && !errors.Is(err, errs.ErrRefundPending) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return refund, nil
+}
+
+// RetryRefunds sends again refunds which provider hasn't answered for refundRetryDelay and checks
+// refunds which provider has left pending, returns number of completed refunds
+func (o *OrderService) RetryRefunds(ctx context.Context) (int64, error) {
+	const op = "services.order.RetryRefunds"
+
+	refunds, err := o.orderRepository.PendingRefunds(ctx, time.Now().Add(-o.refundRetryDelay))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var count int64
+	var retryErr error
+	for _, v := range refunds {
+		if v.PaymentRefundID != nil {
+			err = o.checkRefund(ctx, &v)
+		} else {
+			var order *models.Order
+			order, err = o.orderRepository.OrderById(ctx, v.OrderID)
+			if err == nil {
+				err = o.sendRefund(ctx, &v, *order.PaymentID)
+			}
+		}
+		if err != nil {
+			retryErr = errors.Join(retryErr, err)
+			continue
+		}
+
+		if v.Status != models.RefundStatusPending {
+			count++
+		}
+	}
+	if retryErr != nil {
+		return count, fmt.Errorf("%s: %w", op, retryErr)
+	}
+
+	return count, nil
+}
+
+// sendRefund asks provider to refund saved refund. Refund is canceled only if provider has rejected it,
+// after timeout or provider failure money could be returned, so refund stays pending and ErrRefundPending is returned.
+// If provider has accepted refund but hasn't finished it, refund stays pending without error.
+func (o *OrderService) sendRefund(ctx context.Context, refund *models.OrderRefund, paymentId string) error {
+	const op = "services.order.sendRefund"
+
+	paymentRefund, err := o.paymentService.Refund(ctx, refund.ID, paymentId, refund.Price)
+	if err != nil {
+		if !errors.Is(err, errs.ErrPaymentRejected) && !errors.Is(err, errs.ErrPaymentNotFound) {
+			return fmt.Errorf("%s: %w: %w", op, errs.ErrRefundPending, err)
+		}
+
+		if cancelErr := o.orderRepository.CancelRefund(ctx, refund.ID); cancelErr != nil {
+			return fmt.Errorf("%s: %w", op, errors.Join(err, cancelErr))
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = o.applyRefund(ctx, refund, paymentRefund); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// checkRefund gets state of refund which provider has left pending
+func (o *OrderService) checkRefund(ctx context.Context, refund *models.OrderRefund) error {
+	const op = "services.order.checkRefund"
+
+	paymentRefund, err := o.paymentService.RefundById(ctx, *refund.PaymentRefundID)
+	if err != nil {
+		return fmt.Errorf("%s: %w: %w", op, errs.ErrRefundPending, err)
+	}
+
+	if err = o.applyRefund(ctx, refund, paymentRefund); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// applyRefund saves provider answer. Stock and order status are changed only after money is returned,
+// canceled refund releases its quantity and pending one is saved to be checked later.
+func (o *OrderService) applyRefund(ctx context.Context, refund *models.OrderRefund, paymentRefund models.Refund) error {
+	const op = "services.order.applyRefund"
+
+	refund.PaymentRefundID = &paymentRefund.ID
+
+	switch paymentRefund.Status {
+	case models.RefundStatusSucceeded:
+		refund.Status = models.RefundStatusSucceeded
+
+		if err := o.orderRepository.CompleteRefund(ctx, refund); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	case models.RefundStatusCanceled:
+		if err := o.orderRepository.CancelRefund(ctx, refund.ID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		refund.Status = models.RefundStatusCanceled
+
+		return fmt.Errorf("%s: %w: %w", op, errs.ErrRefundPayment, errs.ErrPaymentRejected)
+	default:
+		if err := o.orderRepository.SavePaymentRefundId(ctx, refund.ID, paymentRefund.ID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+func (o *OrderService) Refunds(ctx context.Context, orderId string) ([]models.OrderRefund, error) {
+	const op = "services.order.Refunds"
+
+	order, err := o.OrderById(ctx, orderId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	refunds, err := o.orderRepository.Refunds(ctx, order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return refunds, nil
+}
+
+// refundItems matches requested items with order items, empty request means everything that is left
+func refundItems(order *models.Order, req []dtos.RefundOrderItem) ([]models.OrderRefundItem, error) {
+	const op = "services.order.refundItems"
+
+	requested := make(map[uuid.UUID]int, len(req))
+	for _, v := range req {
+		requested[uuid.MustParse(v.ItemID)] = v.Quantity
+	}
+
+	items := make([]models.OrderRefundItem, 0, len(order.Items))
+	for _, v := range order.Items {
+		left := v.Quantity - v.RefundedQuantity
+
+		quantity := left
+		if len(req) != 0 {
+			var ok bool
+			if quantity, ok = requested[v.ID]; !ok {
+				continue
+			}
+			delete(requested, v.ID)
+		}
+
+		if quantity > left {
+			return nil, fmt.Errorf("%s: %w", op, errs.ErrRefundQuantity)
+		}
+		if quantity == 0 {
+			continue
+		}
+
+		items = append(items, models.OrderRefundItem{
+			OrderItemID: v.ID,
+			Quantity:    quantity,
+			Price:       v.RefundPrice(quantity),
+		})
+	}
+
+	if len(requested) != 0 {
+		return nil, fmt.Errorf("%s: item is not in the order: %w", op, errs.ErrInvalidRequest)
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrRefundQuantity)
+	}
+
+	return items, nil
+}
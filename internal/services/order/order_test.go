@@ -0,0 +1,98 @@
+package order_service
+
+import (
+	"testing"
+
+	"github.com/AlexMickh/shop-backend/internal/dtos"
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefundItems(t *testing.T) {
+	shirt := models.OrderItem{
+		ID:       uuid.New(),
+		Price:    money.Rub(199),
+		Discount: 33,
+		Quantity: 3,
+	}
+	jeans := models.OrderItem{
+		ID:               uuid.New(),
+		Price:            money.Rub(500000),
+		Quantity:         2,
+		RefundedQuantity: 1,
+	}
+	coat := models.OrderItem{
+		ID:               uuid.New(),
+		Price:            money.Rub(1000),
+		Quantity:         3,
+		RefundedQuantity: 1,
+		CouponDiscount:   money.Rub(100),
+	}
+	order := &models.Order{
+		Items: []models.OrderItem{shirt, jeans, coat},
+	}
+
+	tests := []struct {
+		name    string
+		req     []dtos.RefundOrderItem
+		want    []models.OrderRefundItem
+		wantErr error
+	}{
+		{
+			name: "full refund case",
+			req:  nil,
+			want: []models.OrderRefundItem{
+				{OrderItemID: shirt.ID, Quantity: 3, Price: money.Rub(399)},
+				{OrderItemID: jeans.ID, Quantity: 1, Price: money.Rub(500000)},
+				{OrderItemID: coat.ID, Quantity: 2, Price: money.Rub(1933)},
+			},
+		},
+		{
+			name: "partial refund case",
+			req: []dtos.RefundOrderItem{
+				{ItemID: shirt.ID.String(), Quantity: 2},
+			},
+			want: []models.OrderRefundItem{
+				{OrderItemID: shirt.ID, Quantity: 2, Price: money.Rub(266)},
+			},
+		},
+		{
+			name: "coupon discount case",
+			req: []dtos.RefundOrderItem{
+				{ItemID: coat.ID.String(), Quantity: 1},
+			},
+			want: []models.OrderRefundItem{
+				{OrderItemID: coat.ID, Quantity: 1, Price: money.Rub(967)},
+			},
+		},
+		{
+			name: "already refunded case",
+			req: []dtos.RefundOrderItem{
+				{ItemID: jeans.ID.String(), Quantity: 2},
+			},
+			wantErr: errs.ErrRefundQuantity,
+		},
+		{
+			name: "unknown item case",
+			req: []dtos.RefundOrderItem{
+				{ItemID: uuid.NewString(), Quantity: 1},
+			},
+			wantErr: errs.ErrInvalidRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := refundItems(order, tt.req)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
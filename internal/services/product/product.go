@@ -5,10 +5,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"slices"
+	"time"
 
 	"github.com/AlexMickh/shop-backend/internal/dtos"
 	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/lib/images"
 	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/money"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
@@ -16,34 +20,84 @@ import (
 type ProductRepository interface {
 	SaveProduct(ctx context.Context, product *models.Product) error
 	ProductById(ctx context.Context, id uuid.UUID) (*models.Product, error)
-	ProductCards(
-		ctx context.Context,
-		page int,
-		popularity bool,
-		price int,
-		categoryId uuid.UUID,
-		search string,
-	) ([]models.ProductCard, error)
+	ProductCards(ctx context.Context, q models.ProductCardsQuery) (models.ProductCardsPage, error)
+	ProductFacets(ctx context.Context, f models.ProductFilter) (*models.ProductFacets, error)
 	UpdateProduct(ctx context.Context, productToUpdate *models.Product) error
 	DeleteProduct(ctx context.Context, id uuid.UUID) error
+	ExpireDiscounts(ctx context.Context, now time.Time) (int64, error)
+	StartDiscounts(ctx context.Context, now time.Time) (int64, error)
+	LowestPrice(ctx context.Context, productId uuid.UUID, period time.Duration) (money.Money, error)
+	SaveScheduledPrice(ctx context.Context, price *models.ScheduledPrice) error
+	ApplyScheduledPrices(ctx context.Context, now time.Time) (int64, error)
+	ReserveStock(ctx context.Context, orderId uuid.UUID, items []models.StockReservation, expiresAt time.Time) error
+	ReleaseStock(ctx context.Context, orderId uuid.UUID) error
+	CommitStock(ctx context.Context, orderId uuid.UUID) error
+	SaveVariant(ctx context.Context, variant *models.ProductVariant) error
+	VariantById(ctx context.Context, productId, id uuid.UUID) (*models.ProductVariant, error)
+	UpdateVariant(ctx context.Context, variant *models.ProductVariant) error
+	DeleteVariant(ctx context.Context, productId, id uuid.UUID) error
+	ProductImages(ctx context.Context, productId uuid.UUID) ([]models.ProductImage, error)
+	SaveImage(ctx context.Context, image *models.ProductImage, main bool) error
+	ReorderImages(ctx context.Context, productId uuid.UUID, ids []uuid.UUID) error
+	DeleteImage(ctx context.Context, productId, id uuid.UUID) (*models.ProductImage, error)
 }
 
+// lowestPricePeriod is period for lowest price shown next to discounted price
+const lowestPricePeriod = 30 * 24 * time.Hour
+
 type FileStorage interface {
-	SaveImage(id uuid.UUID, image []byte) (string, error)
-	DeleteImage(id uuid.UUID) error
+	SaveImage(ctx context.Context, image []byte) (string, error)
+}
+
+// Files deletes image files, file is kept while other image with the same content uses it
+type Files interface {
+	DeleteUnused(ctx context.Context, urls ...string) error
+}
+
+// ImageProcessor checks uploaded image and makes its sizes
+type ImageProcessor interface {
+	Process(data []byte) ([]images.Image, error)
+}
+
+// Suggestions is index of product names for search box, it's updated with products
+type Suggestions interface {
+	PutProduct(id uuid.UUID, name string)
+	RenameProduct(id uuid.UUID, name string)
+	DeleteProduct(id uuid.UUID)
 }
 
 type ProductService struct {
 	productRepository ProductRepository
 	fileStorage       FileStorage
+	imageProcessor    ImageProcessor
+	files             Files
+	suggestions       Suggestions
 	validator         *validator.Validate
+	reservationTtl    time.Duration
+	pageSize          int
+	maxPageSize       int
 }
 
-func New(productRepository ProductRepository, fileStorage FileStorage, validator *validator.Validate) *ProductService {
+func New(
+	productRepository ProductRepository,
+	fileStorage FileStorage,
+	imageProcessor ImageProcessor,
+	files Files,
+	suggestions Suggestions,
+	validator *validator.Validate,
+	reservationTtl time.Duration,
+	pageSize, maxPageSize int,
+) *ProductService {
 	return &ProductService{
 		productRepository: productRepository,
 		fileStorage:       fileStorage,
+		imageProcessor:    imageProcessor,
+		files:             files,
+		suggestions:       suggestions,
 		validator:         validator,
+		reservationTtl:    reservationTtl,
+		pageSize:          pageSize,
+		maxPageSize:       maxPageSize,
 	}
 }
 
@@ -66,35 +120,49 @@ func (p *ProductService) CreateProduct(ctx context.Context, req dtos.CreateProdu
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	buf := bytes.NewBuffer(nil)
-
-	if _, err = io.Copy(buf, req.Image); err != nil {
+	imageId, err := uuid.NewV7()
+	if err != nil {
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	imageUrl, err := p.fileStorage.SaveImage(userId, buf.Bytes())
+	image, err := p.storeImage(ctx, req.Image)
 	if err != nil {
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
 	}
+	image.ID = imageId
 
 	product := models.Product{
 		ID:          userId,
 		Name:        req.Name,
 		Description: req.Description,
-		Price:       req.Price,
+		Price:       money.Rub(int64(req.Price)),
 		Category: models.Category{
 			ID: categoryId,
 		},
-		Quantity:      req.Quantity,
-		ExistingSizes: convertSizes(req.ExistingSizes),
-		ImageUrl:      imageUrl,
+		Variants: make([]models.ProductVariant, 0, len(req.ExistingSizes)),
+		ImageUrl: image.Url,
+		ThumbUrl: image.ThumbUrl,
+		Images:   []models.ProductImage{*image},
+	}
+
+	// quantity is split between sizes like in migration of old products, admin can change it for each size later
+	sizes := convertSizes(req.ExistingSizes)
+	stock := splitStock(req.Quantity, len(sizes))
+	for i, v := range sizes {
+		product.Variants = append(product.Variants, models.ProductVariant{
+			Size:  v,
+			Stock: stock[i],
+		})
 	}
 
 	err = p.productRepository.SaveProduct(ctx, &product)
 	if err != nil {
+		_ = p.files.DeleteUnused(ctx, imageUrls(*image)...)
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	p.suggestions.PutProduct(userId, product.Name)
+
 	return userId, nil
 }
 
@@ -111,82 +179,214 @@ func (p *ProductService) ProductById(ctx context.Context, id string) (*models.Pr
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	now := time.Now()
+
+	product.Discount = models.EffectiveDiscount(product.Discount, product.DiscountStartsAt, product.DiscountExpiresAt, now)
+	if product.Discount == 0 {
+		product.DiscountStartsAt = nil
+		product.DiscountExpiresAt = nil
+	}
+
+	product.LowestPrice, err = p.productRepository.LowestPrice(ctx, productId, lowestPricePeriod)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return product, nil
 }
 
-func (p *ProductService) ProductCards(ctx context.Context, req dtos.GetProductsRequest) ([]models.ProductCard, error) {
+// ProductCards returns page of catalogue, limit is pageSize if not set and can't be greater than maxPageSize
+func (p *ProductService) ProductCards(ctx context.Context, req dtos.GetProductsRequest) (models.ProductCardsPage, error) {
 	const op = "services.product.ProductCards"
 
 	err := p.validator.Struct(&req)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+		return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	if req.MaxPrice != 0 && req.MaxPrice < req.MinPrice {
+		return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	q := models.ProductCardsQuery{
+		ProductFilter: models.ProductFilter{
+			Search:   req.Search,
+			MinPrice: money.Rub(int64(req.MinPrice)),
+			MaxPrice: money.Rub(int64(req.MaxPrice)),
+			Sizes:    convertSizes(req.Sizes),
+			InStock:  req.InStock,
+			OnSale:   req.OnSale,
+			Now:      time.Now(),
+		},
+		Sort:  models.ProductSort(req.Sort),
+		Limit: min(req.Limit, p.maxPageSize),
+	}
+	switch {
+	case q.Sort == "" && q.Search != "":
+		q.Sort = models.SortRelevance
+	case q.Sort == "":
+		q.Sort = models.SortNewest
+	case q.Sort == models.SortRelevance && q.Search == "":
+		return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+	if q.Limit == 0 {
+		q.Limit = p.pageSize
 	}
 
-	categoryId := uuid.Max // magic value for null id, because in this lib we don't have null value
 	if req.CategoryID != "" {
-		categoryId, err = uuid.Parse(req.CategoryID)
-		if err != nil {
-			return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+		q.CategoryID = uuid.MustParse(req.CategoryID)
+	}
+
+	if req.Cursor != "" {
+		q.After, err = models.DecodeProductCursor(req.Cursor)
+		if err != nil || q.After.Sort != q.Sort {
+			return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
 		}
 	}
 
-	products, err := p.productRepository.ProductCards(
-		ctx,
-		req.Page,
-		req.Popularity,
-		req.Price,
-		categoryId,
-		req.Search,
-	)
+	page, err := p.productRepository.ProductCards(ctx, q)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if page.HasMore {
+		next := models.NewProductCursor(page.Cards[len(page.Cards)-1], q.Sort, q.Now)
+		page.Next = &next
+	}
+
+	// facets don't depend on page, so they're counted once for the first one
+	if q.After == nil {
+		page.Facets, err = p.productRepository.ProductFacets(ctx, q.ProductFilter)
+		if err != nil {
+			return models.ProductCardsPage{}, fmt.Errorf("%s: %w", op, err)
+		}
 	}
 
-	return products, nil
+	now := q.Now
+	for i := range page.Cards {
+		page.Cards[i].Discount = models.EffectiveDiscount(
+			page.Cards[i].Discount,
+			page.Cards[i].DiscountStartsAt,
+			page.Cards[i].DiscountExpiresAt,
+			now,
+		)
+		if page.Cards[i].Discount == 0 {
+			page.Cards[i].DiscountStartsAt = nil
+			page.Cards[i].DiscountExpiresAt = nil
+		}
+	}
+
+	return page, nil
 }
 
 func (p *ProductService) UpdateProduct(ctx context.Context, req *dtos.UpdateProductRequest) error {
 	const op = "services.product.UpdateProduct"
 
-	if err := p.validator.Struct(&req); err != nil {
+	if err := p.validator.Struct(req); err != nil {
 		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
 	}
 
-	productId, err := uuid.Parse(req.ID)
-	if err != nil {
+	if req.DiscountStartsAt != nil && req.DiscountExpiresAt != nil && !req.DiscountExpiresAt.After(*req.DiscountStartsAt) {
 		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
 	}
 
 	productToUpdate := &models.Product{
-		ID:                productId,
+		ID:                uuid.MustParse(req.ID),
 		Name:              req.Name,
 		Description:       req.Description,
-		Price:             req.Price,
-		Quantity:          req.Quantity,
-		ExistingSizes:     convertSizes(req.ExistingSizes),
-		Discount:          req.Discount,
+		Price:             money.Rub(-1),
+		Quantity:          -1, // stock is changed through variants
+		Discount:          -1,
+		DiscountStartsAt:  req.DiscountStartsAt,
 		DiscountExpiresAt: req.DiscountExpiresAt,
 	}
+	if req.Price != 0 {
+		productToUpdate.Price = money.Rub(int64(req.Price))
+	}
+	if req.Discount != nil {
+		productToUpdate.Discount = *req.Discount
+	}
 
-	if req.Image != nil {
-		buf := bytes.NewBuffer(nil)
+	err := p.productRepository.UpdateProduct(ctx, productToUpdate)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
-		if _, err = io.Copy(buf, req.Image); err != nil {
+	// new image is added to gallery as main, old ones are kept
+	if req.Image != nil {
+		if _, err = p.saveImage(ctx, productToUpdate.ID, req.Image, true); err != nil {
 			return fmt.Errorf("%s: %w", op, err)
 		}
+	}
 
-		productToUpdate.ImageUrl, err = p.fileStorage.SaveImage(productId, buf.Bytes())
-		if err != nil {
-			return fmt.Errorf("%s: %w", op, err)
-		}
+	if productToUpdate.Name != "" {
+		p.suggestions.RenameProduct(productToUpdate.ID, productToUpdate.Name)
+	}
+
+	return nil
+}
+
+// SchedulePrice plans price change of product, price is changed by scheduler when StartsAt comes
+func (p *ProductService) SchedulePrice(ctx context.Context, req dtos.SchedulePriceRequest) (*models.ScheduledPrice, error) {
+	const op = "services.product.SchedulePrice"
+
+	if err := p.validator.Struct(&req); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
 	}
 
-	err = p.productRepository.UpdateProduct(ctx, productToUpdate)
+	if !req.StartsAt.After(time.Now()) {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	price := &models.ScheduledPrice{
+		ProductID: uuid.MustParse(req.ProductID),
+		Price:     money.Rub(int64(req.Price)),
+		StartsAt:  req.StartsAt,
+	}
+
+	err := p.productRepository.SaveScheduledPrice(ctx, price)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return nil
+	return price, nil
+}
+
+// ApplyScheduledPrices changes prices which start time has come, returns number of changed products
+func (p *ProductService) ApplyScheduledPrices(ctx context.Context) (int64, error) {
+	const op = "services.product.ApplyScheduledPrices"
+
+	count, err := p.productRepository.ApplyScheduledPrices(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// ExpireDiscounts removes discounts which are expired. They aren't applied after
+// expiration anyway, so it only keeps data clean.
+func (p *ProductService) ExpireDiscounts(ctx context.Context) (int64, error) {
+	const op = "services.product.ExpireDiscounts"
+
+	count, err := p.productRepository.ExpireDiscounts(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// StartDiscounts marks scheduled discounts as started when their start time has come
+func (p *ProductService) StartDiscounts(ctx context.Context) (int64, error) {
+	const op = "services.product.StartDiscounts"
+
+	count, err := p.productRepository.StartDiscounts(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
 }
 
 func (p *ProductService) DeleteProduct(ctx context.Context, id string) error {
@@ -197,12 +397,261 @@ func (p *ProductService) DeleteProduct(ctx context.Context, id string) error {
 		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
 	}
 
+	images, err := p.productRepository.ProductImages(ctx, productId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	err = p.productRepository.DeleteProduct(ctx, productId)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = p.fileStorage.DeleteImage(productId)
+	p.suggestions.DeleteProduct(productId)
+
+	err = p.files.DeleteUnused(ctx, imageUrls(images...)...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AddImage adds image to product gallery
+func (p *ProductService) AddImage(ctx context.Context, req dtos.AddProductImageRequest) (*models.ProductImage, error) {
+	const op = "services.product.AddImage"
+
+	if err := p.validator.Struct(&req); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	image, err := p.saveImage(ctx, uuid.MustParse(req.ProductID), req.Image, req.Main)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return image, nil
+}
+
+// saveImage writes image files and adds image to gallery, not used files are deleted if it isn't added
+func (p *ProductService) saveImage(ctx context.Context, productId uuid.UUID, file io.Reader, main bool) (*models.ProductImage, error) {
+	const op = "services.product.saveImage"
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	image, err := p.storeImage(ctx, file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	image.ID = id
+	image.ProductID = productId
+
+	err = p.productRepository.SaveImage(ctx, image, main)
+	if err != nil {
+		_ = p.files.DeleteUnused(ctx, imageUrls(*image)...)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return image, nil
+}
+
+// storeImage checks image and writes file of every its size, returned image has only urls.
+// Already written files are deleted on error if they aren't used.
+func (p *ProductService) storeImage(ctx context.Context, file io.Reader) (*models.ProductImage, error) {
+	const op = "services.product.storeImage"
+
+	buf := bytes.NewBuffer(nil)
+
+	if _, err := io.Copy(buf, file); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sizes, err := p.imageProcessor.Process(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	image := &models.ProductImage{}
+	for _, v := range sizes {
+		url, err := p.fileStorage.SaveImage(ctx, v.Data)
+		if err != nil {
+			_ = p.files.DeleteUnused(ctx, imageUrls(*image)...)
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		switch v.Size {
+		case images.SizeThumb:
+			image.ThumbUrl = url
+		case images.SizeMedium:
+			image.MediumUrl = url
+		case images.SizeFull:
+			image.Url = url
+		}
+	}
+
+	return image, nil
+}
+
+// imageUrls returns urls of all sizes of images
+func imageUrls(images ...models.ProductImage) []string {
+	urls := make([]string, 0, len(images)*3)
+
+	for _, v := range images {
+		urls = append(urls, v.Url, v.MediumUrl, v.ThumbUrl)
+	}
+
+	return urls
+}
+
+// ReorderImages sets order of product gallery, request must have every image of product once
+func (p *ProductService) ReorderImages(ctx context.Context, req dtos.ReorderImagesRequest) error {
+	const op = "services.product.ReorderImages"
+
+	if err := p.validator.Struct(&req); err != nil {
+		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	productId := uuid.MustParse(req.ProductID)
+
+	ids := make([]uuid.UUID, 0, len(req.ImageIDs))
+	for _, v := range req.ImageIDs {
+		id := uuid.MustParse(v)
+		if slices.Contains(ids, id) {
+			return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+		}
+
+		ids = append(ids, id)
+	}
+
+	images, err := p.productRepository.ProductImages(ctx, productId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(images) != len(ids) {
+		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	err = p.productRepository.ReorderImages(ctx, productId, ids)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteImage removes image from gallery, the next one becomes main if main is deleted
+func (p *ProductService) DeleteImage(ctx context.Context, productId, id string) error {
+	const op = "services.product.DeleteImage"
+
+	productUUID, err := uuid.Parse(productId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	imageUUID, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	image, err := p.productRepository.DeleteImage(ctx, productUUID, imageUUID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = p.files.DeleteUnused(ctx, imageUrls(*image)...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Variants returns all sizes of product with their stock
+func (p *ProductService) Variants(ctx context.Context, productId string) ([]models.ProductVariant, error) {
+	const op = "services.product.Variants"
+
+	product, err := p.ProductById(ctx, productId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return product.Variants, nil
+}
+
+func (p *ProductService) CreateVariant(ctx context.Context, req dtos.CreateVariantRequest) (*models.ProductVariant, error) {
+	const op = "services.product.CreateVariant"
+
+	if err := p.validator.Struct(&req); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	variant := &models.ProductVariant{
+		ProductID: uuid.MustParse(req.ProductID),
+		Size:      models.ProductSize(req.Size),
+		Stock:     req.Stock,
+		Sku:       req.Sku,
+		Barcode:   req.Barcode,
+	}
+
+	err := p.productRepository.SaveVariant(ctx, variant)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return variant, nil
+}
+
+func (p *ProductService) UpdateVariant(ctx context.Context, req dtos.UpdateVariantRequest) (*models.ProductVariant, error) {
+	const op = "services.product.UpdateVariant"
+
+	if err := p.validator.Struct(&req); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	variant, err := p.productRepository.VariantById(ctx, uuid.MustParse(req.ProductID), uuid.MustParse(req.ID))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if req.Size != nil {
+		variant.Size = models.ProductSize(*req.Size)
+	}
+	if req.Stock != nil {
+		variant.Stock = *req.Stock
+	}
+	if req.Sku != nil {
+		variant.Sku = req.Sku
+	}
+	if req.Barcode != nil {
+		variant.Barcode = req.Barcode
+	}
+
+	err = p.productRepository.UpdateVariant(ctx, variant)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return variant, nil
+}
+
+func (p *ProductService) DeleteVariant(ctx context.Context, productId, id string) error {
+	const op = "services.product.DeleteVariant"
+
+	productUUID, err := uuid.Parse(productId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	variantUUID, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	err = p.productRepository.DeleteVariant(ctx, productUUID, variantUUID)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -219,3 +668,67 @@ func convertSizes(sizes []string) []models.ProductSize {
 
 	return modelSizes
 }
+
+// splitStock splits quantity between sizes evenly, first sizes get one piece more if it isn't divided
+func splitStock(quantity, sizes int) []int {
+	stock := make([]int, sizes)
+
+	for i := range stock {
+		stock[i] = quantity / sizes
+		if i < quantity%sizes {
+			stock[i]++
+		}
+	}
+
+	return stock
+}
+
+// ReserveStock holds order items for reservationTtl, the whole order is reserved or nothing
+func (p *ProductService) ReserveStock(ctx context.Context, order *models.Order) error {
+	const op = "services.product.ReserveStock"
+
+	quantities := make(map[uuid.UUID]int, len(order.Items))
+	items := make([]models.StockReservation, 0, len(order.Items))
+	for _, v := range order.Items {
+		if v.VariantID == nil {
+			return fmt.Errorf("%s: order item %s has no size: %w", op, v.ID, errs.ErrVariantNotFound)
+		}
+
+		if _, ok := quantities[*v.VariantID]; !ok {
+			items = append(items, models.StockReservation{ProductID: v.ProductID, VariantID: *v.VariantID})
+		}
+		quantities[*v.VariantID] += v.Quantity
+	}
+	for i := range items {
+		items[i].Quantity = quantities[items[i].VariantID]
+	}
+
+	err := p.productRepository.ReserveStock(ctx, order.ID, items, time.Now().Add(p.reservationTtl))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *ProductService) ReleaseStock(ctx context.Context, orderId uuid.UUID) error {
+	const op = "services.product.ReleaseStock"
+
+	err := p.productRepository.ReleaseStock(ctx, orderId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *ProductService) CommitStock(ctx context.Context, orderId uuid.UUID) error {
+	const op = "services.product.CommitStock"
+
+	err := p.productRepository.CommitStock(ctx, orderId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
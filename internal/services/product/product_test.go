@@ -0,0 +1,46 @@
+package product_service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStock(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity int
+		sizes    int
+		want     []int
+	}{
+		{
+			name:     "even case",
+			quantity: 10,
+			sizes:    5,
+			want:     []int{2, 2, 2, 2, 2},
+		},
+		{
+			name:     "remainder case",
+			quantity: 11,
+			sizes:    3,
+			want:     []int{4, 4, 3},
+		},
+		{
+			name:     "less than sizes case",
+			quantity: 2,
+			sizes:    3,
+			want:     []int{1, 1, 0},
+		},
+		{
+			name:     "zero case",
+			quantity: 0,
+			sizes:    2,
+			want:     []int{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, splitStock(tt.quantity, tt.sizes))
+		})
+	}
+}
@@ -19,7 +19,7 @@ type SessionRepository interface {
 type JwtManager interface {
 	NewJwt(userID string) (string, error)
 	NewRefresh() (string, error)
-	Validate(token string) (int64, error)
+	Validate(token string) (string, error)
 }
 
 type SessionService struct {
@@ -98,16 +98,16 @@ func (s *SessionService) Refresh(req dtos.RefreshRequest) (string, string, error
 	return accessToken, refreshToken, nil
 }
 
-func (s *SessionService) ValidateJwt(token string) (int64, error) {
+func (s *SessionService) ValidateJwt(token string) (string, error) {
 	const op = "services.session.ValidateJwt"
 
 	if token == "" {
-		return 0, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+		return "", fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
 	}
 
 	userID, err := s.jwtManager.Validate(token)
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	return userID, nil
@@ -0,0 +1,152 @@
+package suggest_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AlexMickh/shop-backend/internal/dtos"
+	"github.com/AlexMickh/shop-backend/internal/errs"
+	"github.com/AlexMickh/shop-backend/internal/models"
+	"github.com/AlexMickh/shop-backend/pkg/suggest"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+type ProductRepository interface {
+	ProductNames(ctx context.Context) ([]models.ProductCard, error)
+}
+
+type CategoryRepository interface {
+	AllCategories(ctx context.Context) ([]models.Category, error)
+}
+
+// SuggestService finds products and categories by prefix of their names. Names are kept
+// in memory, so suggestions are found without database while user types.
+type SuggestService struct {
+	productRepository  ProductRepository
+	categoryRepository CategoryRepository
+	products           *suggest.Index[uuid.UUID]
+	categories         *suggest.Index[uuid.UUID]
+	validator          *validator.Validate
+	limit              int
+	budget             time.Duration
+}
+
+func New(
+	productRepository ProductRepository,
+	categoryRepository CategoryRepository,
+	validator *validator.Validate,
+	limit int,
+	budget time.Duration,
+) *SuggestService {
+	return &SuggestService{
+		productRepository:  productRepository,
+		categoryRepository: categoryRepository,
+		products:           suggest.New[uuid.UUID](),
+		categories:         suggest.New[uuid.UUID](),
+		validator:          validator,
+		limit:              limit,
+		budget:             budget,
+	}
+}
+
+// Load fills index with all products and categories, it's called once at startup
+func (s *SuggestService) Load(ctx context.Context) error {
+	const op = "services.suggest.Load"
+
+	products, err := s.productRepository.ProductNames(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	entries := make([]suggest.Entry[uuid.UUID], 0, len(products))
+	for _, v := range products {
+		entries = append(entries, suggest.Entry[uuid.UUID]{Key: v.ID, Text: v.Name, Weight: v.PiecesSold})
+	}
+	s.products.Load(entries)
+
+	categories, err := s.categoryRepository.AllCategories(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	entries = make([]suggest.Entry[uuid.UUID], 0, len(categories))
+	for _, v := range categories {
+		entries = append(entries, suggest.Entry[uuid.UUID]{Key: v.ID, Text: v.Name})
+	}
+	s.categories.Load(entries)
+
+	return nil
+}
+
+// UpdatePopularity sets sold pieces of products as their weights, so products which are
+// sold more go up in suggestions. Returns number of changed products.
+func (s *SuggestService) UpdatePopularity(ctx context.Context) (int64, error) {
+	const op = "services.suggest.UpdatePopularity"
+
+	products, err := s.productRepository.ProductNames(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	weights := make(map[uuid.UUID]int, len(products))
+	for _, v := range products {
+		weights[v.ID] = v.PiecesSold
+	}
+
+	return int64(s.products.SetWeights(weights)), nil
+}
+
+// Suggest returns the most popular products and categories which names start with query words.
+// Search stops when budget is over, then only already found names are returned.
+func (s *SuggestService) Suggest(ctx context.Context, req dtos.SuggestRequest) (models.Suggestions, error) {
+	const op = "services.suggest.Suggest"
+
+	if err := s.validator.Struct(&req); err != nil {
+		return models.Suggestions{}, fmt.Errorf("%s: %w", op, errs.ErrInvalidRequest)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.budget)
+	defer cancel()
+
+	return models.Suggestions{
+		Products:   toSuggestions(s.products.Search(ctx, req.Query, s.limit)),
+		Categories: toSuggestions(s.categories.Search(ctx, req.Query, s.limit)),
+	}, nil
+}
+
+// PutProduct adds new product to index
+func (s *SuggestService) PutProduct(id uuid.UUID, name string) {
+	s.products.Put(id, name, 0)
+}
+
+// RenameProduct changes name of product keeping its popularity
+func (s *SuggestService) RenameProduct(id uuid.UUID, name string) {
+	s.products.Rename(id, name)
+}
+
+func (s *SuggestService) DeleteProduct(id uuid.UUID) {
+	s.products.Delete(id)
+}
+
+func (s *SuggestService) PutCategory(id uuid.UUID, name string) {
+	s.categories.Put(id, name, 0)
+}
+
+func (s *SuggestService) DeleteCategory(id uuid.UUID) {
+	s.categories.Delete(id)
+}
+
+func toSuggestions(entries []suggest.Entry[uuid.UUID]) []models.Suggestion {
+	suggestions := make([]models.Suggestion, 0, len(entries))
+
+	for _, v := range entries {
+		suggestions = append(suggestions, models.Suggestion{
+			ID:   v.Key,
+			Name: v.Text,
+		})
+	}
+
+	return suggestions
+}
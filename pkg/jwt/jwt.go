@@ -53,7 +53,8 @@ func (j *JwtManager) NewRefresh() (string, error) {
 	return fmt.Sprintf("%x", b), nil
 }
 
-func (j *JwtManager) Validate(token string) (int64, error) {
+// Validate checks token signature and expiration time, returns user id from it
+func (j *JwtManager) Validate(token string) (string, error) {
 	const op = "pkg.jwt.Validate"
 
 	claims := jwt.MapClaims{}
@@ -65,21 +66,21 @@ func (j *JwtManager) Validate(token string) (int64, error) {
 		return struct{}{}, errors.New("invalid token")
 	})
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	exp, err := claims.GetExpirationTime()
 	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	if exp.Compare(time.Now()) == 1 {
-		return 0, fmt.Errorf("%s: %w", op, err)
+	if exp == nil || exp.Before(time.Now()) {
+		return "", fmt.Errorf("%s: %w", op, jwt.ErrTokenExpired)
 	}
 
-	id, ok := claims["sub"].(int64)
+	id, ok := claims["sub"].(string)
 	if !ok {
-		return 0, fmt.Errorf("%s: failed to get id", op)
+		return "", fmt.Errorf("%s: failed to get id", op)
 	}
 
 	return id, nil
@@ -14,10 +14,10 @@ func TestJwtManager_NewJwt(t *testing.T) {
 		jwtTtl time.Duration
 	}
 	type args struct {
-		userID int64
+		userID string
 	}
 
-	var id int64 = 1
+	id := "0198a1b2-c3d4-7e5f-8a9b-0c1d2e3f4a5b"
 
 	tests := []struct {
 		name    string
@@ -0,0 +1,223 @@
+// Package money keeps prices as integer amount of minor units (kopecks for RUB),
+// so no precision is lost on arithmetic and formatting.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Currency string
+
+const (
+	RUB Currency = "RUB"
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+)
+
+// DefaultCurrency is used when currency isn't stored with amount, e.g. in db columns
+const DefaultCurrency = RUB
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// number of digits after the decimal point, ISO 4217
+var exponents = map[Currency]int{
+	RUB: 2,
+	USD: 2,
+	EUR: 2,
+}
+
+type Money struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+func New(amount int64, currency Currency) Money {
+	return Money{
+		Amount:   amount,
+		Currency: currency,
+	}
+}
+
+// Rub creates money from kopecks
+func Rub(kopecks int64) Money {
+	return New(kopecks, RUB)
+}
+
+// Parse parses exact decimal string like "1234.57" into minor units
+func Parse(value string, currency Currency) (Money, error) {
+	const op = "pkg.money.Parse"
+
+	exp := currency.exponent()
+
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" || len(fraction) > exp || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("%s: %w: %q", op, ErrInvalidAmount, value)
+	}
+	fraction += strings.Repeat("0", exp-len(fraction))
+
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%s: %w: %q", op, ErrInvalidAmount, value)
+	}
+
+	if negative {
+		amount = -amount
+	}
+
+	return New(amount, currency), nil
+}
+
+// Add sums money, zero value can be used as RUB accumulator
+func (m Money) Add(other Money) Money {
+	m.mustMatch(other)
+	m.Currency = m.currency()
+	m.Amount += other.Amount
+	return m
+}
+
+func (m Money) Sub(other Money) Money {
+	m.mustMatch(other)
+	m.Currency = m.currency()
+	m.Amount -= other.Amount
+	return m
+}
+
+func (m Money) Mul(n int) Money {
+	m.Amount *= int64(n)
+	return m
+}
+
+// ApplyDiscount returns price reduced by percent. Result is rounded
+// to the nearest minor unit, halves are rounded up.
+// Discount is applied to unit price, multiply after it, not before.
+func (m Money) ApplyDiscount(percent int) Money {
+	if percent <= 0 {
+		return m
+	}
+	if percent >= 100 {
+		m.Amount = 0
+		return m
+	}
+
+	rest := m.Amount * int64(100-percent)
+	m.Amount = rest / 100
+	if rem := rest % 100; rem >= 50 {
+		m.Amount++
+	} else if rem <= -50 {
+		m.Amount--
+	}
+
+	return m
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) Less(other Money) bool {
+	m.mustMatch(other)
+	return m.Amount < other.Amount
+}
+
+// Decimal formats amount in major units without rounding, e.g. "1234.57"
+func (m Money) Decimal() string {
+	exp := m.currency().exponent()
+
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.currency())
+}
+
+// Scan reads amount from integer column, currency is set to DefaultCurrency
+func (m *Money) Scan(src any) error {
+	const op = "pkg.money.Scan"
+
+	switch v := src.(type) {
+	case int64:
+		m.Amount = v
+	case int32:
+		m.Amount = int64(v)
+	case int:
+		m.Amount = int64(v)
+	case []byte:
+		amount, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		m.Amount = amount
+	case string:
+		amount, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		m.Amount = amount
+	default:
+		return fmt.Errorf("%s: unsupported type %T", op, src)
+	}
+
+	m.Currency = DefaultCurrency
+
+	return nil
+}
+
+// Value stores only amount, all columns are in DefaultCurrency
+func (m Money) Value() (driver.Value, error) {
+	return m.Amount, nil
+}
+
+func (m Money) currency() Currency {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+func (m Money) mustMatch(other Money) {
+	if m.currency() != other.currency() {
+		panic(fmt.Sprintf("money: currency mismatch %s and %s", m.currency(), other.currency()))
+	}
+}
+
+func (c Currency) exponent() int {
+	if exp, ok := exponents[c]; ok {
+		return exp
+	}
+	return 2
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoney_ApplyDiscount(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   int64
+		discount int
+		want     int64
+	}{
+		{
+			name:     "no discount case",
+			amount:   123456,
+			discount: 0,
+			want:     123456,
+		},
+		{
+			name:     "exact case",
+			amount:   10000,
+			discount: 15,
+			want:     8500,
+		},
+		{
+			name:     "round down case",
+			amount:   199,
+			discount: 33,
+			want:     133,
+		},
+		{
+			name:     "round half up case",
+			amount:   150,
+			discount: 1,
+			want:     149,
+		},
+		{
+			name:     "small price case",
+			amount:   99,
+			discount: 50,
+			want:     50,
+		},
+		{
+			name:     "full discount case",
+			amount:   99,
+			discount: 100,
+			want:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Rub(tt.amount).ApplyDiscount(tt.discount)
+			require.Equal(t, Rub(tt.want), got)
+		})
+	}
+}
+
+func TestMoney_Decimal(t *testing.T) {
+	tests := []struct {
+		name  string
+		money Money
+		want  string
+	}{
+		{
+			name:  "good case",
+			money: Rub(123457),
+			want:  "1234.57",
+		},
+		{
+			name:  "kopecks only case",
+			money: Rub(5),
+			want:  "0.05",
+		},
+		{
+			name:  "zero case",
+			money: Money{},
+			want:  "0.00",
+		},
+		{
+			name:  "negative case",
+			money: Rub(-1050),
+			want:  "-10.50",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.money.Decimal())
+
+			parsed, err := Parse(tt.want, RUB)
+			require.NoError(t, err)
+			require.Equal(t, tt.money.Amount, parsed.Amount)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int64
+		wantErr error
+	}{
+		{
+			name:  "good case",
+			value: "1234.5",
+			want:  123450,
+		},
+		{
+			name:  "whole case",
+			value: "12",
+			want:  1200,
+		},
+		{
+			name:    "too precise case",
+			value:   "1234.5699",
+			wantErr: ErrInvalidAmount,
+		},
+		{
+			name:    "not a number case",
+			value:   "1e3",
+			wantErr: ErrInvalidAmount,
+		},
+		{
+			name:    "double sign case",
+			value:   "--1",
+			wantErr: ErrInvalidAmount,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value, RUB)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, Rub(tt.want), got)
+		})
+	}
+}
+
+func TestMoney_Add(t *testing.T) {
+	var total Money
+	total = total.Add(Rub(100)).Add(Rub(250).Mul(3))
+	require.Equal(t, Rub(850), total)
+
+	require.Panics(t, func() {
+		Rub(100).Add(New(100, USD))
+	})
+}
+
+func TestMoney_Scan(t *testing.T) {
+	var m Money
+	require.NoError(t, m.Scan(int64(1999)))
+	require.Equal(t, Rub(1999), m)
+
+	value, err := m.Value()
+	require.NoError(t, err)
+	require.Equal(t, int64(1999), value)
+
+	require.Error(t, m.Scan(1.5))
+}
+
+func TestMoney_MarshalJSON(t *testing.T) {
+	b, err := json.Marshal(Rub(1999))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"amount":1999,"currency":"RUB"}`, string(b))
+}
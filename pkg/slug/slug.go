@@ -0,0 +1,43 @@
+package slug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// translit is transliteration of russian letters used in urls, ъ and ь are dropped
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "h", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// Make returns lower case latin words and numbers of s joined with hyphen,
+// russian letters are transliterated and other symbols are dropped
+func Make(s string) string {
+	var b strings.Builder
+	hyphen := false
+
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			b.WriteRune(r)
+			hyphen = false
+		case translit[r] != "" || unicode.Is(unicode.Cyrillic, r):
+			b.WriteString(translit[r])
+			hyphen = false
+		case !hyphen && b.Len() != 0:
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// Valid checks that s could be returned by Make
+func Valid(s string) bool {
+	return s != "" && Make(s) == s
+}
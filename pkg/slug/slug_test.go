@@ -0,0 +1,54 @@
+package slug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{
+			name: "latin case",
+			s:    "Men's Jackets",
+			want: "men-s-jackets",
+		},
+		{
+			name: "russian case",
+			s:    "Верхняя одежда",
+			want: "verhnyaya-odezhda",
+		},
+		{
+			name: "soft sign case",
+			s:    "Объёмные пальто",
+			want: "obemnye-palto",
+		},
+		{
+			name: "symbols case",
+			s:    "  -- Костюмы / 2025 --  ",
+			want: "kostyumy-2025",
+		},
+		{
+			name: "empty case",
+			s:    "!!!",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, Make(tt.s))
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	require.True(t, Valid("verhnyaya-odezhda"))
+	require.False(t, Valid("Verhnyaya-odezhda"))
+	require.False(t, Valid("verhnyaya--odezhda"))
+	require.False(t, Valid("-palto"))
+	require.False(t, Valid(""))
+}
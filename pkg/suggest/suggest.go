@@ -0,0 +1,214 @@
+package suggest
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// checkEvery is how many words are scanned between context checks
+const checkEvery = 256
+
+type Entry[K comparable] struct {
+	Key    K
+	Text   string
+	Weight int // entries with bigger weight are found first
+}
+
+type word[K comparable] struct {
+	word string
+	key  K
+}
+
+// Index finds entries by prefixes of words of their text. Words are kept sorted,
+// so words with the same prefix are found by binary search.
+type Index[K comparable] struct {
+	entries map[K]Entry[K]
+	words   []word[K]
+	mu      sync.RWMutex
+}
+
+func New[K comparable]() *Index[K] {
+	return &Index[K]{
+		entries: make(map[K]Entry[K]),
+	}
+}
+
+// Load replaces all entries of index. Words are sorted once, so it's much faster
+// than Put of every entry when index is filled.
+func (i *Index[K]) Load(entries []Entry[K]) {
+	byKey := make(map[K]Entry[K], len(entries))
+	for _, v := range entries {
+		byKey[v.Key] = v
+	}
+
+	words := make([]word[K], 0, len(byKey))
+	for _, v := range byKey {
+		for _, w := range split(v.Text) {
+			words = append(words, word[K]{word: w, key: v.Key})
+		}
+	}
+
+	slices.SortFunc(words, func(a, b word[K]) int {
+		return strings.Compare(a.word, b.word)
+	})
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.entries = byKey
+	i.words = words
+}
+
+// Put adds entry or replaces entry with the same key
+func (i *Index[K]) Put(key K, text string, weight int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.put(key, text, weight)
+}
+
+// Rename changes text of entry keeping its weight, entry is added with zero weight if it doesn't exist
+func (i *Index[K]) Rename(key K, text string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.put(key, text, i.entries[key].Weight)
+}
+
+// SetWeights changes weights of existing entries, keys which aren't in index are skipped.
+// Returns number of changed entries.
+func (i *Index[K]) SetWeights(weights map[K]int) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	changed := 0
+	for key, weight := range weights {
+		entry, ok := i.entries[key]
+		if !ok || entry.Weight == weight {
+			continue
+		}
+
+		entry.Weight = weight
+		i.entries[key] = entry
+		changed++
+	}
+
+	return changed
+}
+
+func (i *Index[K]) put(key K, text string, weight int) {
+	i.delete(key)
+
+	i.entries[key] = Entry[K]{Key: key, Text: text, Weight: weight}
+	for _, v := range split(text) {
+		w := word[K]{word: v, key: key}
+		pos, _ := slices.BinarySearchFunc(i.words, v, compareWord)
+		i.words = slices.Insert(i.words, pos, w)
+	}
+}
+
+func (i *Index[K]) Delete(key K) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.delete(key)
+}
+
+func (i *Index[K]) delete(key K) {
+	if _, ok := i.entries[key]; !ok {
+		return
+	}
+
+	delete(i.entries, key)
+	i.words = slices.DeleteFunc(i.words, func(w word[K]) bool {
+		return w.key == key
+	})
+}
+
+// Search returns at most limit entries which have words starting with every word of query,
+// the last word of query can be not finished. If ctx is done before all words are scanned,
+// the best of already found entries are returned.
+func (i *Index[K]) Search(ctx context.Context, query string, limit int) []Entry[K] {
+	words := split(query)
+	if len(words) == 0 || limit <= 0 {
+		return []Entry[K]{}
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	// the longest word gives the least candidates
+	longest := slices.MaxFunc(words, func(a, b string) int {
+		return cmp.Compare(len(a), len(b))
+	})
+
+	found := make(map[K]struct{})
+	res := make([]Entry[K], 0, limit)
+
+	start, _ := slices.BinarySearchFunc(i.words, longest, compareWord)
+	for n, w := range i.words[start:] {
+		if !strings.HasPrefix(w.word, longest) {
+			break
+		}
+
+		if n%checkEvery == 0 && ctx.Err() != nil {
+			break
+		}
+
+		if _, ok := found[w.key]; ok {
+			continue
+		}
+
+		entry := i.entries[w.key]
+		if !matches(split(entry.Text), words) {
+			continue
+		}
+
+		found[w.key] = struct{}{}
+		res = append(res, entry)
+	}
+
+	slices.SortFunc(res, func(a, b Entry[K]) int {
+		return cmp.Or(
+			cmp.Compare(b.Weight, a.Weight),
+			cmp.Compare(len(a.Text), len(b.Text)),
+			strings.Compare(a.Text, b.Text),
+		)
+	})
+
+	if len(res) > limit {
+		res = res[:limit]
+	}
+
+	return res
+}
+
+// matches checks that every query word is prefix of some text word
+func matches(text, query []string) bool {
+	for _, q := range query {
+		if !slices.ContainsFunc(text, func(t string) bool {
+			return strings.HasPrefix(t, q)
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// split returns lower case words of text, ё is replaced with е because it's often typed as е
+func split(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func compareWord[K comparable](w word[K], target string) int {
+	return strings.Compare(w.word, target)
+}
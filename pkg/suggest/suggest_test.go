@@ -0,0 +1,143 @@
+package suggest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex_Search(t *testing.T) {
+	index := New[int]()
+	index.Put(1, "Пальто шерстяное", 3)
+	index.Put(2, "Пальто-кокон", 10)
+	index.Put(3, "Костюм шерстяной", 5)
+	index.Put(4, "Тёплая куртка", 0)
+	index.Put(5, "Шапка", 0)
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []int
+	}{
+		{
+			name:  "prefix case",
+			query: "паль",
+			limit: 10,
+			want:  []int{2, 1},
+		},
+		{
+			name:  "not first word case",
+			query: "шерст",
+			limit: 10,
+			want:  []int{3, 1},
+		},
+		{
+			name:  "several words case",
+			query: "ПАЛЬТО шер",
+			limit: 10,
+			want:  []int{1},
+		},
+		{
+			name:  "ё case",
+			query: "тепл",
+			limit: 10,
+			want:  []int{4},
+		},
+		{
+			name:  "limit case",
+			query: "ш",
+			limit: 2,
+			want:  []int{3, 1},
+		},
+		{
+			name:  "not found case",
+			query: "брюки",
+			limit: 10,
+			want:  []int{},
+		},
+		{
+			name:  "empty query case",
+			query: " - ",
+			limit: 10,
+			want:  []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := index.Search(context.Background(), tt.query, tt.limit)
+
+			keys := make([]int, 0, len(entries))
+			for _, v := range entries {
+				keys = append(keys, v.Key)
+			}
+			require.Equal(t, tt.want, keys)
+		})
+	}
+}
+
+func TestIndex_Update(t *testing.T) {
+	index := New[int]()
+	index.Put(1, "Пальто", 7)
+	index.Put(2, "Куртка", 1)
+
+	index.Rename(1, "Плащ")
+	entries := index.Search(context.Background(), "пл", 10)
+	require.Equal(t, []Entry[int]{{Key: 1, Text: "Плащ", Weight: 7}}, entries)
+	require.Empty(t, index.Search(context.Background(), "паль", 10))
+
+	index.Delete(2)
+	require.Empty(t, index.Search(context.Background(), "курт", 10))
+}
+
+func TestIndex_SearchCanceled(t *testing.T) {
+	index := New[int]()
+	index.Put(1, "Пальто", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.Empty(t, index.Search(ctx, "паль", 10))
+}
+
+func TestIndex_Load(t *testing.T) {
+	index := New[int]()
+	index.Put(1, "Шапка", 0)
+
+	index.Load([]Entry[int]{
+		{Key: 2, Text: "Пальто шерстяное", Weight: 3},
+		{Key: 3, Text: "Костюм шерстяной", Weight: 5},
+		{Key: 4, Text: "Пальто-кокон", Weight: 10},
+	})
+
+	keys := func(entries []Entry[int]) []int {
+		res := make([]int, 0, len(entries))
+		for _, v := range entries {
+			res = append(res, v.Key)
+		}
+		return res
+	}
+
+	require.Empty(t, index.Search(context.Background(), "шап", 10))
+	require.Equal(t, []int{4, 2}, keys(index.Search(context.Background(), "паль", 10)))
+	require.Equal(t, []int{3, 2}, keys(index.Search(context.Background(), "шерст", 10)))
+
+	index.Put(5, "Пальто", 7)
+	require.Equal(t, []int{4, 5, 2}, keys(index.Search(context.Background(), "паль", 10)))
+}
+
+func TestIndex_SetWeights(t *testing.T) {
+	index := New[int]()
+	index.Put(1, "Пальто шерстяное", 3)
+	index.Put(2, "Пальто-кокон", 10)
+
+	changed := index.SetWeights(map[int]int{1: 20, 2: 10, 3: 5})
+	require.Equal(t, 1, changed)
+
+	entries := index.Search(context.Background(), "паль", 10)
+	require.Equal(t, []Entry[int]{
+		{Key: 1, Text: "Пальто шерстяное", Weight: 20},
+		{Key: 2, Text: "Пальто-кокон", Weight: 10},
+	}, entries)
+}
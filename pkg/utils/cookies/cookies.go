@@ -1,10 +1,18 @@
 package cookies
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
+var ErrInvalidSignature = errors.New("invalid cookie signature")
+
 func Set(w http.ResponseWriter, name string, value string, ttl time.Duration) {
 	cookie := http.Cookie{
 		Name:     name,
@@ -17,3 +25,50 @@ func Set(w http.ResponseWriter, name string, value string, ttl time.Duration) {
 
 	http.SetCookie(w, &cookie)
 }
+
+// SetSigned sets cookie with value signed by secret, so client can't forge it
+func SetSigned(w http.ResponseWriter, name string, value string, secret string, ttl time.Duration) {
+	Set(w, name, value+"."+sign(name, value, secret), ttl)
+}
+
+// GetSigned returns value of cookie set by SetSigned
+func GetSigned(r *http.Request, name string, secret string) (string, error) {
+	const op = "pkg.utils.cookies.GetSigned"
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	value, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidSignature)
+	}
+
+	// name is signed too, so value of one cookie can't be used as another
+	if !hmac.Equal([]byte(signature), []byte(sign(name, value, secret))) {
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidSignature)
+	}
+
+	return value, nil
+}
+
+func Delete(w http.ResponseWriter, name string) {
+	cookie := http.Cookie{
+		Name:     name,
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+	}
+
+	http.SetCookie(w, &cookie)
+}
+
+func sign(name, value, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(name + "=" + value))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,73 @@
+package cookies
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSigned(t *testing.T) {
+	const secret = "some secret"
+
+	tests := []struct {
+		name    string
+		cookie  func(signed string) *http.Cookie
+		want    string
+		wantErr error
+	}{
+		{
+			name: "good case",
+			cookie: func(signed string) *http.Cookie {
+				return &http.Cookie{Name: "guest_cart", Value: signed}
+			},
+			want: "some value",
+		},
+		{
+			name: "forged value",
+			cookie: func(signed string) *http.Cookie {
+				return &http.Cookie{Name: "guest_cart", Value: "other value" + signed[len("some value"):]}
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "signature of other cookie",
+			cookie: func(signed string) *http.Cookie {
+				return &http.Cookie{Name: "guest_cart", Value: "some value." + sign("other", "some value", secret)}
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "no signature",
+			cookie: func(signed string) *http.Cookie {
+				return &http.Cookie{Name: "guest_cart", Value: "some value"}
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "no cookie",
+			cookie: func(signed string) *http.Cookie {
+				return &http.Cookie{Name: "other", Value: signed}
+			},
+			wantErr: http.ErrNoCookie,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			SetSigned(w, "guest_cart", "some value", secret, time.Hour)
+
+			cookies := w.Result().Cookies()
+			require.Len(t, cookies, 1)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(tt.cookie(cookies[0].Value))
+
+			got, err := GetSigned(r, "guest_cart", secret)
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -9,11 +9,14 @@ import (
 
 func Decode[T any](r *http.Request, v T) error {
 	val := reflect.ValueOf(v)
-	t := reflect.TypeFor[T]()
+	if val.Kind() != reflect.Pointer || val.Elem().Kind() != reflect.Struct {
+		return errors.New("v must be a pointer to struct")
+	}
 
 	structValue := val.Elem()
+	t := structValue.Type()
 
-	for i := range val.NumField() {
+	for i := range structValue.NumField() {
 		field := structValue.Field(i)
 		fieldType := t.Field(i)
 